@@ -30,10 +30,23 @@ func init() {
 	pfxlog.GlobalInit(logrus.InfoLevel, pfxlog.DefaultOptions().SetTrimPrefix("github.com/openziti/"))
 }
 
+// localCommands don't need an instance, so they run in this binary instead of being delegated to
+// the default instance's executable
+var localCommands = map[string]bool{
+	"completion": true,
+	"clean":      true,
+	"serve":      true,
+	"schema":     true,
+	"validate":   true,
+	"config":     true,
+	"vault":      true,
+	"plugins":    true,
+}
+
 func main() {
 	if len(os.Args) > 1 {
 		runLocalBinary := false
-		if os.Args[1] == "completion" || os.Args[1] == "clean" || os.Args[1] == "serve" {
+		if localCommands[os.Args[1]] {
 			runLocalBinary = true
 		} else if len(os.Args) > 2 {
 			if os.Args[1] == "list" && os.Args[2] == "instances" {
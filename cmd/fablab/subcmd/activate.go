@@ -24,6 +24,7 @@ import (
 
 func init() {
 	RootCmd.AddCommand(activateCmd)
+	addForceFlag(activateCmd)
 }
 
 var activateCmd = &cobra.Command{
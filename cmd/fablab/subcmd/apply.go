@@ -18,6 +18,8 @@ package subcmd
 
 import (
 	"fmt"
+	"io"
+	"sort"
 
 	"github.com/openziti/fablab/kernel/engine"
 	"github.com/openziti/fablab/kernel/loader"
@@ -40,7 +42,7 @@ func NewApplyCommand() *cobra.Command {
 		RunE:  applyCmd.apply,
 	}
 
-	cmd.Flags().StringVarP(&applyCmd.ConfigPath, "config", "c", "", "path to YAML configuration file")
+	cmd.Flags().StringArrayVarP(&applyCmd.ConfigPaths, "config", "c", nil, "path to YAML configuration file, repeat to overlay files in order")
 	cmd.Flags().BoolVar(&applyCmd.DryRun, "dry-run", false, "validate configuration without applying")
 	cmd.MarkFlagRequired("config")
 
@@ -48,24 +50,18 @@ func NewApplyCommand() *cobra.Command {
 }
 
 type ApplyCommand struct {
-	ConfigPath string
-	DryRun     bool
+	ConfigPaths []string
+	DryRun      bool
 }
 
 func (a *ApplyCommand) apply(cmd *cobra.Command, args []string) error {
-	m, err := loader.LoadModel(a.ConfigPath)
+	m, err := loader.LoadModel(a.ConfigPaths...)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
 	if a.DryRun {
-		logrus.Infof("dry-run: loaded model '%s' with %d region(s)", m.Id, len(m.Regions))
-		for regionId, region := range m.Regions {
-			logrus.Infof("  region '%s': %d host(s)", regionId, len(region.Hosts))
-			for hostId, host := range region.Hosts {
-				logrus.Infof("    host '%s': %d component(s)", hostId, len(host.Components))
-			}
-		}
+		a.describe(cmd.OutOrStdout(), m)
 		return nil
 	}
 
@@ -88,3 +84,28 @@ func (a *ApplyCommand) apply(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// describe prints the structure of the loaded model, for --dry-run
+func (a *ApplyCommand) describe(out io.Writer, m *model.Model) {
+	_, _ = fmt.Fprintf(out, "model '%s': %d region(s)\n", m.Id, len(m.Regions))
+	for _, regionId := range sortedKeys(m.Regions) {
+		region := m.Regions[regionId]
+		_, _ = fmt.Fprintf(out, "  region '%s': %d host(s)\n", regionId, len(region.Hosts))
+		for _, hostId := range sortedKeys(region.Hosts) {
+			host := region.Hosts[hostId]
+			_, _ = fmt.Fprintf(out, "    host '%s' [%s]: %d component(s)\n", hostId, host.InstanceType, len(host.Components))
+			for _, componentId := range sortedKeys(host.Components) {
+				_, _ = fmt.Fprintf(out, "      component '%s' [%s]\n", componentId, host.Components[componentId].Type.Label())
+			}
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
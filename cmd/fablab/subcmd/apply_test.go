@@ -1,8 +1,10 @@
 package subcmd
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -87,3 +89,41 @@ func writeTempYaml(t *testing.T, content string) string {
 	}
 	return path
 }
+
+func TestApplyCommand_Overlay(t *testing.T) {
+	base := writeTempYaml(t, `
+model:
+  id: test-overlay
+
+regions:
+  us-east-1:
+    hosts:
+      controller:
+        components:
+          - type: ziti-controller
+`)
+	overlay := writeTempYaml(t, `
+regions:
+  us-east-1:
+    hosts:
+      controller:
+        instanceType: c5.large
+`)
+
+	out := &bytes.Buffer{}
+	cmd := NewApplyCommand()
+	cmd.SetOut(out)
+	cmd.SetArgs([]string{"-c", base, "-c", overlay, "--dry-run"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("apply command failed: %v", err)
+	}
+
+	// the overlay sets the instance type, the base component is kept
+	if !strings.Contains(out.String(), "    host 'controller' [c5.large]: 1 component(s)\n") {
+		t.Errorf("expected the overlay's instance type on us-east-1.controller, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "[ziti-controller]\n") {
+		t.Errorf("expected the base's ziti-controller component, got:\n%s", out.String())
+	}
+}
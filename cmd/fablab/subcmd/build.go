@@ -24,6 +24,7 @@ import (
 
 func init() {
 	RootCmd.AddCommand(buildCmd)
+	addForceFlag(buildCmd)
 }
 
 var buildCmd = &cobra.Command{
@@ -0,0 +1,63 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package subcmd
+
+import (
+	"fmt"
+
+	"github.com/openziti/fablab/kernel/loader"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	configCmd.AddCommand(NewConfigRenderCommand())
+	RootCmd.AddCommand(configCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "work with YAML model configurations",
+}
+
+func NewConfigRenderCommand() *cobra.Command {
+	renderCmd := &ConfigRenderCommand{}
+
+	cmd := &cobra.Command{
+		Use:   "render",
+		Short: "print the merged configuration after resolving bases, includes and overlays",
+		Args:  cobra.ExactArgs(0),
+		RunE:  renderCmd.render,
+	}
+
+	cmd.Flags().StringArrayVarP(&renderCmd.ConfigPaths, "config", "c", nil, "path to YAML configuration file, repeat to overlay files in order")
+	cmd.MarkFlagRequired("config")
+
+	return cmd
+}
+
+type ConfigRenderCommand struct {
+	ConfigPaths []string
+}
+
+func (r *ConfigRenderCommand) render(cmd *cobra.Command, _ []string) error {
+	data, err := loader.RenderConfig(r.ConfigPaths...)
+	if err != nil {
+		return fmt.Errorf("failed to render config: %w", err)
+	}
+	_, err = cmd.OutOrStdout().Write(data)
+	return err
+}
@@ -24,6 +24,7 @@ import (
 
 func init() {
 	RootCmd.AddCommand(disposeCmd)
+	addForceFlag(disposeCmd)
 }
 
 var disposeCmd = &cobra.Command{
@@ -0,0 +1,77 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package subcmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/openziti/fablab/kernel/loader"
+	"github.com/openziti/fablab/kernel/model"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.AddCommand(NewExportYamlCommand())
+}
+
+func NewExportYamlCommand() *cobra.Command {
+	exportYamlCmd := &ExportYamlCommand{}
+
+	cmd := &cobra.Command{
+		Use:   "export-yaml",
+		Short: "export the instance's model as a YAML configuration",
+		Args:  cobra.ExactArgs(0),
+		RunE:  exportYamlCmd.export,
+	}
+
+	cmd.Flags().StringVarP(&exportYamlCmd.Output, "output", "o", "", "file to write the configuration to, defaults to stdout")
+
+	return cmd
+}
+
+type ExportYamlCommand struct {
+	Output string
+}
+
+func (e *ExportYamlCommand) export(cmd *cobra.Command, _ []string) error {
+	ctx, err := model.MustBootstrapContext()
+	if err != nil {
+		return fmt.Errorf("unable to bootstrap: %w", err)
+	}
+
+	config, warnings := loader.ExportModel(ctx.GetModel())
+	for _, warning := range warnings {
+		logrus.Warnf("not exported: %v", warning)
+	}
+
+	data, err := loader.MarshalConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
+	}
+
+	if e.Output == "" {
+		_, err = cmd.OutOrStdout().Write(data)
+		return err
+	}
+	if err := os.WriteFile(e.Output, data, 0644); err != nil {
+		return fmt.Errorf("failed to write '%s': %w", e.Output, err)
+	}
+	logrus.Infof("exported model '%s' to '%s'", config.Model.Id, e.Output)
+	return nil
+}
@@ -24,6 +24,7 @@ import (
 
 func init() {
 	RootCmd.AddCommand(expressCmd)
+	addForceFlag(expressCmd)
 }
 
 var expressCmd = &cobra.Command{
@@ -0,0 +1,105 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package subcmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/openziti/fablab/kernel/model"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.AddCommand(NewHealthCommand())
+}
+
+func NewHealthCommand() *cobra.Command {
+	healthCmd := &HealthCommand{}
+
+	cmd := &cobra.Command{
+		Use:   "health <component-spec>",
+		Short: "run the health checks of the selected components",
+		Args:  cobra.ExactArgs(1),
+		RunE:  healthCmd.run,
+	}
+
+	cmd.Flags().IntVarP(&healthCmd.Concurrency, "concurrency", "c", 10, "number of components to check in parallel")
+
+	return cmd
+}
+
+type HealthCommand struct {
+	Concurrency int
+}
+
+func (h *HealthCommand) run(cmd *cobra.Command, args []string) error {
+	ctx, err := model.MustBootstrapContext()
+	if err != nil {
+		return fmt.Errorf("unable to bootstrap (%w)", err)
+	}
+
+	run, err := ctx.MustRun()
+	if err != nil {
+		return fmt.Errorf("error initializing run (%w)", err)
+	}
+
+	m := ctx.GetModel()
+	components := m.SelectComponents(args[0])
+	if len(components) == 0 {
+		return fmt.Errorf("'%s' matched no components", args[0])
+	}
+
+	indexes := map[*model.Component]int{}
+	for i, c := range components {
+		indexes[c] = i
+	}
+	results := make([][]model.HealthResult, len(components))
+	err = m.ForEachComponentIn(components, h.Concurrency, func(c *model.Component) error {
+		results[indexes[c]] = c.CheckHealth(run)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var ordered []model.HealthResult
+	for _, componentResults := range results {
+		ordered = append(ordered, componentResults...)
+	}
+
+	if unhealthy := printHealthResults(cmd.OutOrStdout(), ordered); unhealthy > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d health check(s) failed", unhealthy)
+	}
+	return nil
+}
+
+// printHealthResults writes a line per check, returning the number of failed checks.
+func printHealthResults(out io.Writer, results []model.HealthResult) int {
+	unhealthy := 0
+	for _, result := range results {
+		path := result.Component.GetPathId()
+		if result.Healthy {
+			_, _ = fmt.Fprintf(out, "%-40s %-40s healthy\n", path, result.Check)
+			continue
+		}
+		unhealthy++
+		_, _ = fmt.Fprintf(out, "%-40s %-40s unhealthy after %d attempt(s): %v\n", path, result.Check, result.Attempts, result.Err)
+	}
+	return unhealthy
+}
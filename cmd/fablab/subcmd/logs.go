@@ -0,0 +1,88 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package subcmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/openziti/fablab/kernel/model"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.AddCommand(NewLogsCommand())
+}
+
+func NewLogsCommand() *cobra.Command {
+	logsCmd := &LogsCommand{}
+
+	cmd := &cobra.Command{
+		Use:   "logs <component-spec>",
+		Short: "show the logs of the selected components, prefixing lines with the component path",
+		Args:  cobra.ExactArgs(1),
+		RunE:  logsCmd.run,
+	}
+
+	cmd.Flags().BoolVarP(&logsCmd.Follow, "follow", "f", false, "keep printing lines as they are written")
+	cmd.Flags().IntVarP(&logsCmd.Lines, "lines", "n", model.DefaultLogLines, "number of lines to read from the end of each log, 0 for all")
+	cmd.Flags().StringVarP(&logsCmd.Grep, "grep", "g", "", "only show lines matching this regular expression")
+	cmd.Flags().StringVar(&logsCmd.Since, "since", "", "only show lines logged after this duration ago (e.g. 10m) or RFC 3339 time")
+
+	return cmd
+}
+
+type LogsCommand struct {
+	Follow bool
+	Lines  int
+	Grep   string
+	Since  string
+}
+
+func (l *LogsCommand) run(cmd *cobra.Command, args []string) error {
+	since, err := model.ParseLogSince(l.Since, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, err := model.MustBootstrapContext()
+	if err != nil {
+		return fmt.Errorf("unable to bootstrap (%w)", err)
+	}
+
+	run, err := ctx.MustRun()
+	if err != nil {
+		return fmt.Errorf("error initializing run (%w)", err)
+	}
+
+	components := ctx.GetModel().SelectComponents(args[0])
+	if len(components) == 0 {
+		return fmt.Errorf("'%s' matched no components", args[0])
+	}
+
+	streamCtx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	options := model.LogOptions{Lines: l.Lines, Follow: l.Follow, Grep: l.Grep, Since: since}
+	out := cmd.OutOrStdout()
+	return model.StreamLogs(streamCtx, run, components, options, func(line model.LogLine) {
+		_, _ = fmt.Fprintln(out, model.FormatLogLine(run, line))
+	})
+}
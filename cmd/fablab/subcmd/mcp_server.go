@@ -46,10 +46,16 @@ The server provides tools for:
   - apply_config: Apply YAML configuration to create/update infrastructure
   - get_resources: Get all resources for an instance
   - create_network: Create a new network instance
+  - get_schema: Get the JSON Schema for YAML model configurations
+  - get_logs: Get recent log lines of components of the active instance
 
 And resources:
   - fablab://status: Current status of all instances
-  - fablab://instances/{id}: Details of a specific instance`,
+  - fablab://instances/{id}: Details of a specific instance
+  - fablab://schema: JSON Schema for YAML model configurations
+
+Resource changes in the store are pushed to clients as
+notifications/resources/updated on fablab://instances/{id}.`,
 		RunE: mcpCmd.run,
 	}
 
@@ -0,0 +1,60 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package subcmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openziti/fablab/kernel/plugin"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.AddCommand(NewPluginsCommand())
+}
+
+func NewPluginsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "plugins",
+		Short: "list the component type plugins, starting each to check it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := plugin.PluginsDir()
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			_, _ = fmt.Fprintf(out, "plugins directory: %s\n", dir)
+			for _, p := range plugin.Loaded() {
+				handshake, err := p.Handshake()
+				if err != nil {
+					_, _ = fmt.Fprintf(out, "%-20s %s\n    error: %v\n", p.Name, p.Path, err)
+					continue
+				}
+				_, _ = fmt.Fprintf(out, "%-20s %s\n", p.Name, p.Path)
+				if handshake.Label != "" {
+					_, _ = fmt.Fprintf(out, "    label: %s\n", handshake.Label)
+				}
+				if len(handshake.Actions) > 0 {
+					_, _ = fmt.Fprintf(out, "    actions: %s\n", strings.Join(handshake.Actions, ", "))
+				}
+			}
+			return nil
+		},
+	}
+}
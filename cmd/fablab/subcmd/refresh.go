@@ -25,6 +25,7 @@ import (
 
 func init() {
 	RootCmd.AddCommand(refreshCmd)
+	addForceFlag(refreshCmd)
 }
 
 var refreshCmd = &cobra.Command{
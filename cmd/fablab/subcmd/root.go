@@ -19,6 +19,7 @@ package subcmd
 import (
 	"github.com/michaelquigley/pfxlog"
 	"github.com/openziti/fablab/kernel/model"
+	"github.com/openziti/fablab/kernel/plugin"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"os"
@@ -26,6 +27,7 @@ import (
 )
 
 func Execute() error {
+	defer plugin.Shutdown()
 	return RootCmd.Execute()
 }
 
@@ -41,7 +43,9 @@ func init() {
 var RootCmd = &cobra.Command{
 	Use:   filepath.Base(os.Args[0]),
 	Short: "The Fabulous Laboratory",
-	PersistentPreRun: func(_ *cobra.Command, _ []string) {
+	PersistentPreRun: func(cmd *cobra.Command, _ []string) {
+		model.LifecycleCommand = cmd.CommandPath()
+
 		if verbose {
 			logrus.SetLevel(logrus.DebugLevel)
 		}
@@ -57,8 +61,18 @@ var RootCmd = &cobra.Command{
 		default:
 			// let logrus do its own thing
 		}
+
+		if _, err := plugin.LoadDefault(); err != nil {
+			logrus.WithError(err).Warn("unable to load component type plugins")
+		}
 	},
 }
 
+// addForceFlag lets a command running lifecycle stages override the instance's state, see
+// model.Label.Transition.
+func addForceFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&model.ForceTransitions, "force", false, "run even if the instance's lifecycle state does not allow it")
+}
+
 var verbose bool
 var logFormatter string
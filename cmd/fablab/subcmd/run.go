@@ -24,6 +24,7 @@ import (
 
 func init() {
 	RootCmd.AddCommand(runCmd)
+	addForceFlag(runCmd)
 }
 
 var runCmd = &cobra.Command{
@@ -0,0 +1,110 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package subcmd
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/openziti/fablab/kernel/engine"
+	"github.com/openziti/fablab/kernel/model"
+	"github.com/openziti/fablab/kernel/store"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.AddCommand(NewScaleCommand())
+}
+
+func NewScaleCommand() *cobra.Command {
+	scaleCmd := &ScaleCommand{}
+
+	cmd := &cobra.Command{
+		Use:   "scale [<spec> <count>]",
+		Short: "change the number of entities in scale groups of the running instance, or list the groups",
+		Long: "change the number of entities in scale groups of the running instance. The spec is either the key\n" +
+			"of a scale group, as listed when run without arguments, or a selector matching members of the groups",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 && len(args) != 2 {
+				return fmt.Errorf("expected a spec and a count, or no arguments to list the scale groups")
+			}
+			return nil
+		},
+		RunE: scaleCmd.run,
+	}
+
+	cmd.Flags().DurationVar(&scaleCmd.ReadyTimeout, "ready-timeout", model.DefaultReadyTimeout, "how long to wait for each new component to become ready")
+
+	return cmd
+}
+
+type ScaleCommand struct {
+	ReadyTimeout time.Duration
+}
+
+func (s *ScaleCommand) run(cmd *cobra.Command, args []string) error {
+	var count uint32
+	if len(args) == 2 {
+		parsed, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid count '%s', must be a non-negative number", args[1])
+		}
+		count = uint32(parsed)
+	}
+
+	ctx, err := model.MustBootstrapContext()
+	if err != nil {
+		return fmt.Errorf("unable to bootstrap (%w)", err)
+	}
+
+	out := cmd.OutOrStdout()
+	if len(args) == 0 {
+		for _, group := range ctx.GetModel().GetScaleGroups() {
+			_, _ = fmt.Fprintf(out, "%-50s %-10s %d\n", group.Key, group.EntityType, group.Count())
+		}
+		return nil
+	}
+
+	run, err := ctx.MustRun()
+	if err != nil {
+		return fmt.Errorf("error initializing run (%w)", err)
+	}
+
+	scaler := engine.NewScaler(store.NewFileStore(model.GetConfig()))
+	result, err := scaler.Scale(run, args[0], count, engine.ScaleOptions{ReadyTimeout: s.ReadyTimeout})
+	if result != nil {
+		for _, change := range result.Changes {
+			_, _ = fmt.Fprintf(out, "%s: %d -> %d\n", change.Group.Key, change.From, change.To)
+			for _, entity := range change.Added {
+				_, _ = fmt.Fprintf(out, "  + %s\n", describeEntity(entity))
+			}
+			for _, entity := range change.Removed {
+				_, _ = fmt.Fprintf(out, "  - %s\n", describeEntity(entity))
+			}
+		}
+	}
+	if err != nil {
+		cmd.SilenceUsage = true
+		return err
+	}
+
+	if result.IsEmpty() {
+		_, _ = fmt.Fprintln(out, "nothing to do")
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package subcmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/invopop/jsonschema"
+	"github.com/openziti/fablab/kernel/loader"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.AddCommand(NewSchemaCommand())
+}
+
+func NewSchemaCommand() *cobra.Command {
+	schemaCmd := &SchemaCommand{}
+
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "print the JSON Schema for YAML model configurations",
+		Long: `Print the JSON Schema for YAML model configurations, including the config
+block of every registered component type. Point your editor's YAML language
+server at the output for validation and autocompletion.`,
+		Args: cobra.ExactArgs(0),
+		RunE: schemaCmd.run,
+	}
+
+	cmd.Flags().StringVarP(&schemaCmd.ComponentType, "type", "t", "", "only print the config schema of this component type")
+
+	return cmd
+}
+
+type SchemaCommand struct {
+	ComponentType string
+}
+
+func (s *SchemaCommand) run(cmd *cobra.Command, _ []string) error {
+	var schema *jsonschema.Schema
+	if s.ComponentType != "" {
+		var err error
+		if schema, err = loader.ComponentTypeSchema(s.ComponentType); err != nil {
+			return err
+		}
+	} else {
+		schema = loader.GenerateSchema()
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schema: %w", err)
+	}
+	_, err = fmt.Fprintln(cmd.OutOrStdout(), string(data))
+	return err
+}
@@ -0,0 +1,141 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package subcmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/openziti/fablab/kernel/loader"
+	"github.com/openziti/fablab/kernel/model"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.AddCommand(NewSelectCommand())
+}
+
+func NewSelectCommand() *cobra.Command {
+	selectCmd := &SelectCommand{}
+
+	cmd := &cobra.Command{
+		Use:   "select <spec>",
+		Short: "show the entities a selector matches, and why each matched",
+		Args:  cobra.ExactArgs(1),
+		RunE:  selectCmd.run,
+	}
+
+	cmd.Flags().StringVarP(&selectCmd.Type, "type", "t", model.EntityTypeComponent, "type of entity to select: host, component or region")
+	cmd.Flags().StringArrayVarP(&selectCmd.ConfigPaths, "config", "c", nil, "path to YAML configuration file, repeat to overlay files in order. Defaults to the active instance")
+
+	return cmd
+}
+
+type SelectCommand struct {
+	Type        string
+	ConfigPaths []string
+}
+
+func (s *SelectCommand) run(cmd *cobra.Command, args []string) error {
+	m, err := loadModel(s.ConfigPaths)
+	if err != nil {
+		return err
+	}
+
+	spec := args[0]
+	matches, err := m.ExplainSelector(spec, s.Type)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if len(matches) == 0 {
+		_, _ = fmt.Fprintf(out, "warning: '%s' matched no %ss\n", spec, s.Type)
+		for _, other := range []string{model.EntityTypeRegion, model.EntityTypeHost, model.EntityTypeComponent} {
+			if other == s.Type {
+				continue
+			}
+			if otherMatches, _ := m.ExplainSelector(spec, other); len(otherMatches) > 0 {
+				_, _ = fmt.Fprintf(out, "hint: it matches %d %s(s), try --type %s\n", len(otherMatches), other, other)
+			}
+		}
+		return nil
+	}
+
+	for _, match := range matches {
+		s.print(out, match)
+	}
+	_, _ = fmt.Fprintf(out, "%d %s(s) matched\n", len(matches), s.Type)
+	return nil
+}
+
+// loadModel loads the model from the YAML configuration files if any are given, or else
+// bootstraps the active instance.
+func loadModel(configPaths []string) (*model.Model, error) {
+	if len(configPaths) > 0 {
+		m, err := loader.LoadModel(configPaths...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config: %w", err)
+		}
+		return m, nil
+	}
+	ctx, err := model.MustBootstrapContext()
+	if err != nil {
+		return nil, fmt.Errorf("unable to bootstrap (%w)", err)
+	}
+	return ctx.GetModel(), nil
+}
+
+// print writes the entity path and tags, followed by the entity and terms each level matched.
+func (s *SelectCommand) print(out io.Writer, match model.SelectorMatch) {
+	_, _ = fmt.Fprintf(out, "%s", describeEntity(match.Entity))
+	if tags := match.Entity.GetScope().Tags; len(tags) > 0 {
+		_, _ = fmt.Fprintf(out, " [%s]", strings.Join(tags, ", "))
+	}
+	_, _ = fmt.Fprintln(out)
+
+	for _, reason := range match.Reasons {
+		var terms []string
+		for _, term := range reason.Terms {
+			terms = append(terms, describeTermMatch(reason.Entity, term))
+		}
+		_, _ = fmt.Fprintf(out, "    %s matched %s: %s\n", reason.Level, describeEntity(reason.Entity), strings.Join(terms, ", "))
+	}
+}
+
+// describeTermMatch names the term with the tags it matched on, and the related entity which
+// satisfied it if that isn't the entity the level was evaluated against.
+func describeTermMatch(entity model.Entity, term model.SelectorTermMatch) string {
+	var details []string
+	if len(term.Tags) > 0 {
+		details = append(details, "tag "+strings.Join(term.Tags, ", "))
+	}
+	if term.Entity == nil {
+		details = append(details, "negated")
+	} else if term.Entity != entity {
+		details = append(details, "via "+describeEntity(term.Entity))
+	}
+	if len(details) == 0 {
+		return term.Term
+	}
+	return fmt.Sprintf("%s (%s)", term.Term, strings.Join(details, "; "))
+}
+
+func describeEntity(entity model.Entity) string {
+	return fmt.Sprintf("%s %s", entity.GetType(), strings.Join(model.GetScopedEntityPath(entity), "."))
+}
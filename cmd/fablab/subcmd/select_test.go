@@ -0,0 +1,82 @@
+package subcmd
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+const selectTestYaml = `model:
+  id: test-select
+regions:
+  us-east-1:
+    hosts:
+      ctrl:
+        components:
+          - type: ziti-controller
+            id: ctrl
+      router-east:
+        tags: [edge-router]
+        components:
+          - type: ziti-router
+            id: router-east
+  us-west-2:
+    hosts:
+      router-west:
+        tags: [edge-router]
+        components:
+          - type: ziti-router
+            id: router-west
+`
+
+func runSelect(t *testing.T, args ...string) (string, error) {
+	path := writeTempYaml(t, selectTestYaml)
+	t.Cleanup(func() { _ = os.Remove(path) })
+
+	out := &bytes.Buffer{}
+	cmd := NewSelectCommand()
+	cmd.SetOut(out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs(append([]string{"--config", path}, args...))
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestSelectCommand_ExplainsMatches(t *testing.T) {
+	out, err := runSelect(t, "region#us-east-1 > host.edge-*")
+	if err != nil {
+		t.Fatalf("select command failed: %v", err)
+	}
+
+	for _, expected := range []string{
+		"component us-east-1.router-east.router-east",
+		"    region#us-east-1 matched host us-east-1.router-east: region#us-east-1 (via region us-east-1)",
+		"    host.edge-* matched component us-east-1.router-east.router-east: host.edge-* (tag edge-router; via host us-east-1.router-east)",
+		"1 component(s) matched",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected output to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
+
+func TestSelectCommand_WarnsOnNoMatches(t *testing.T) {
+	out, err := runSelect(t, "--type", "region", ".edge-router")
+	if err != nil {
+		t.Fatalf("select command failed: %v", err)
+	}
+	if !strings.Contains(out, "warning: '.edge-router' matched no regions") {
+		t.Fatalf("expected warning, got:\n%s", out)
+	}
+	if !strings.Contains(out, "hint: it matches 2 host(s), try --type host") {
+		t.Fatalf("expected hint, got:\n%s", out)
+	}
+}
+
+func TestSelectCommand_InvalidSelector(t *testing.T) {
+	_, err := runSelect(t, "hots.edge")
+	if err == nil || !strings.Contains(err.Error(), "unknown entity type 'hots'") {
+		t.Fatalf("expected selector error, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package subcmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/openziti/fablab/kernel/model"
+	"github.com/openziti/fablab/kernel/store"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	stateCmd.AddCommand(NewStateWatchCommand())
+	RootCmd.AddCommand(stateCmd)
+}
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "inspect instance resource state",
+}
+
+func NewStateWatchCommand() *cobra.Command {
+	watchCmd := &StateWatchCommand{}
+
+	cmd := &cobra.Command{
+		Use:   "watch [instanceId]",
+		Short: "stream resource changes for an instance as they happen",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  watchCmd.run,
+	}
+
+	cmd.Flags().BoolVar(&watchCmd.All, "all", false, "watch every configured instance")
+	cmd.Flags().BoolVar(&watchCmd.Json, "json", false, "print events as JSON, one per line")
+	cmd.Flags().DurationVar(&watchCmd.Interval, "interval", store.DefaultWatchInterval, "how often to look for new instances with --all, and to poll resource files which can't be watched")
+
+	return cmd
+}
+
+type StateWatchCommand struct {
+	All      bool
+	Json     bool
+	Interval time.Duration
+}
+
+func (w *StateWatchCommand) run(cmd *cobra.Command, args []string) error {
+	cfg := model.GetConfig()
+
+	instanceId := cfg.GetSelectedInstanceId()
+	if len(args) > 0 {
+		instanceId = args[0]
+	}
+	if w.All {
+		instanceId = ""
+	} else if _, found := cfg.Instances[instanceId]; !found {
+		return fmt.Errorf("instance [%s] not found in config", instanceId)
+	}
+
+	fileStore := store.NewFileStore(cfg)
+	fileStore.WatchInterval = w.Interval
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	events, err := fileStore.Watch(ctx, instanceId)
+	if err != nil {
+		return fmt.Errorf("unable to watch instance [%s]: %w", instanceId, err)
+	}
+
+	for event := range events {
+		if err := w.print(cmd.OutOrStdout(), event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *StateWatchCommand) print(out io.Writer, event store.ResourceEvent) error {
+	if w.Json {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+
+	oldStatus, newStatus := "-", "-"
+	if event.Old != nil {
+		oldStatus = string(event.Old.Status)
+	}
+	if event.New != nil {
+		newStatus = string(event.New.Status)
+	}
+
+	_, err := fmt.Fprintf(out, "%s %-6s %s/%s [%s -> %s]\n",
+		time.Unix(event.Timestamp, 0).Format(time.RFC3339), event.Type,
+		event.InstanceId, event.ResourceId, oldStatus, newStatus)
+	return err
+}
@@ -18,6 +18,10 @@ package subcmd
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"time"
+
 	"github.com/openziti/fablab/kernel/model"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -25,8 +29,11 @@ import (
 
 func init() {
 	RootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().BoolVar(&statusHistory, "history", false, "show the lifecycle transitions of the instance")
 }
 
+var statusHistory bool
+
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "show the environment and active instance status",
@@ -46,6 +53,39 @@ func status(_ *cobra.Command, _ []string) {
 		fmt.Printf("%-20s\n", "Label")
 		fmt.Printf("%-20s %s\n", "  Model", l.Model)
 		fmt.Printf("%-20s %s\n", "  State", l.State)
+		if statusHistory {
+			fmt.Println()
+			fmt.Printf("%-20s\n", "History")
+			printStateHistory(os.Stdout, l.History)
+		}
 	}
 	fmt.Println()
 }
+
+// printStateHistory writes a line per lifecycle transition, oldest first, followed by the error
+// of failed transitions.
+func printStateHistory(out io.Writer, history []model.StateTransition) {
+	if len(history) == 0 {
+		_, _ = fmt.Fprintln(out, "  no transitions recorded")
+		return
+	}
+	for _, t := range history {
+		result := "ok"
+		if !t.Success {
+			result = "failed"
+		}
+		if t.Forced {
+			result += " (forced)"
+		}
+		_, _ = fmt.Fprintf(out, "  %s  %-26s %-15s %8s  %-12s %s\n",
+			t.Time.Local().Format(time.DateTime),
+			fmt.Sprintf("%s -> %s", t.From, t.To),
+			result,
+			t.Duration.Round(time.Second),
+			t.User,
+			t.Command)
+		if t.Error != "" {
+			_, _ = fmt.Fprintf(out, "      %s\n", t.Error)
+		}
+	}
+}
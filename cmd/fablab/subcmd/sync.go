@@ -26,6 +26,9 @@ func init() {
 	RootCmd.AddCommand(syncCmd)
 	syncCmd.AddCommand(syncBinariesCmd)
 	syncCmd.AddCommand(syncConfigCmd)
+	addForceFlag(syncCmd)
+	addForceFlag(syncBinariesCmd)
+	addForceFlag(syncConfigCmd)
 }
 
 var syncCmd = &cobra.Command{
@@ -25,6 +25,7 @@ import (
 
 func init() {
 	RootCmd.AddCommand(upCmd)
+	addForceFlag(upCmd)
 }
 
 var upCmd = &cobra.Command{
@@ -0,0 +1,103 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package subcmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/openziti/fablab/kernel/engine"
+	"github.com/openziti/fablab/kernel/model"
+	"github.com/openziti/fablab/kernel/store"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.AddCommand(NewUpgradeCommand())
+}
+
+func NewUpgradeCommand() *cobra.Command {
+	upgradeCmd := &UpgradeCommand{}
+
+	cmd := &cobra.Command{
+		Use:   "upgrade <component-spec>",
+		Short: "upgrade the selected components to a new version, in batches",
+		Args:  cobra.ExactArgs(1),
+		RunE:  upgradeCmd.run,
+	}
+
+	cmd.Flags().StringVar(&upgradeCmd.Version, "version", "", "version to upgrade to")
+	cmd.Flags().IntVarP(&upgradeCmd.BatchSize, "batch-size", "b", engine.DefaultUpgradeBatchSize, "number of components upgraded at once")
+	cmd.Flags().DurationVar(&upgradeCmd.ReadyTimeout, "ready-timeout", model.DefaultReadyTimeout, "how long to wait for each component to become ready")
+	cmd.Flags().StringVar(&upgradeCmd.OnFailure, "on-failure", string(engine.UpgradeRollback), "what to do when a batch fails: rollback or pause")
+	_ = cmd.MarkFlagRequired("version")
+	addForceFlag(cmd)
+
+	return cmd
+}
+
+type UpgradeCommand struct {
+	Version      string
+	BatchSize    int
+	ReadyTimeout time.Duration
+	OnFailure    string
+}
+
+func (u *UpgradeCommand) run(cmd *cobra.Command, args []string) error {
+	ctx, err := model.MustBootstrapContext()
+	if err != nil {
+		return fmt.Errorf("unable to bootstrap (%w)", err)
+	}
+
+	run, err := ctx.MustRun()
+	if err != nil {
+		return fmt.Errorf("error initializing run (%w)", err)
+	}
+
+	upgrader := engine.NewUpgrader(store.NewFileStore(model.GetConfig()))
+	result, err := upgrader.Upgrade(run, args[0], engine.UpgradeOptions{
+		Version:      u.Version,
+		BatchSize:    u.BatchSize,
+		ReadyTimeout: u.ReadyTimeout,
+		OnFailure:    engine.UpgradeFailurePolicy(u.OnFailure),
+	})
+
+	out := cmd.OutOrStdout()
+	if result != nil {
+		for _, c := range result.Skipped {
+			_, _ = fmt.Fprintf(out, "%-40s already at %s\n", c.GetPathId(), u.Version)
+		}
+		for _, c := range result.Upgraded {
+			_, _ = fmt.Fprintf(out, "%-40s upgraded to %s\n", c.GetPathId(), u.Version)
+		}
+		for _, c := range result.RolledBack {
+			_, _ = fmt.Fprintf(out, "%-40s rolled back to %s\n", c.GetPathId(), c.Type.GetVersion())
+		}
+		if result.RolledBack == nil {
+			for _, c := range result.Failed {
+				_, _ = fmt.Fprintf(out, "%-40s failed, left at %s\n", c.GetPathId(), c.Type.GetVersion())
+			}
+		}
+	}
+	if err != nil {
+		cmd.SilenceUsage = true
+		return err
+	}
+
+	_, _ = fmt.Fprintf(out, "%d component(s) upgraded to %s in %d batch(es)\n", len(result.Upgraded), u.Version, result.Batches)
+	return nil
+}
@@ -0,0 +1,110 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package subcmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/openziti/fablab/kernel/loader"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.AddCommand(NewValidateCommand())
+}
+
+func NewValidateCommand() *cobra.Command {
+	validateCmd := &ValidateCommand{}
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "validate a YAML configuration, reporting problems as file:line:col",
+		Args:  cobra.ExactArgs(0),
+		RunE:  validateCmd.validate,
+	}
+
+	cmd.Flags().StringArrayVarP(&validateCmd.ConfigPaths, "config", "c", nil, "path to YAML configuration file, repeat to overlay files in order")
+	cmd.Flags().BoolVar(&validateCmd.NoSnippets, "no-snippets", false, "print only one line per problem")
+	cmd.MarkFlagRequired("config")
+
+	return cmd
+}
+
+type ValidateCommand struct {
+	ConfigPaths []string
+	NoSnippets  bool
+}
+
+func (v *ValidateCommand) validate(cmd *cobra.Command, _ []string) error {
+	result, err := loader.ValidateConfig(v.ConfigPaths...)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+	for _, e := range result.Errors {
+		v.print(out, "error", e)
+	}
+	for _, e := range result.Warnings {
+		v.print(out, "warning", e)
+	}
+
+	if !result.IsValid() {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("configuration has %d error(s)", len(result.Errors))
+	}
+	return nil
+}
+
+// print writes a problem in the `file:line:col: severity: message` form understood by editors
+// and CI annotations, followed by the source line with a caret under the column.
+func (v *ValidateCommand) print(out io.Writer, severity string, e loader.ValidationError) {
+	msg := e.Message
+	if e.Path != "" {
+		msg = fmt.Sprintf("%s: %s", e.Path, e.Message)
+	}
+
+	if e.Position.IsValid() || e.Position.File != "" {
+		_, _ = fmt.Fprintf(out, "%s: %s: %s\n", e.Position, severity, msg)
+	} else {
+		_, _ = fmt.Fprintf(out, "%s: %s\n", severity, msg)
+	}
+
+	if v.NoSnippets || e.Position.Snippet == "" {
+		return
+	}
+	_, _ = fmt.Fprintf(out, "    %s\n", e.Position.Snippet)
+	_, _ = fmt.Fprintf(out, "    %s^\n", caretIndent(e.Position.Snippet, e.Position.Column))
+}
+
+// caretIndent returns whitespace reaching column of line, keeping tabs so the caret lines up.
+func caretIndent(line string, column int) string {
+	var indent strings.Builder
+	for i, r := range []rune(line) {
+		if i >= column-1 {
+			break
+		}
+		if r == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+	return indent.String()
+}
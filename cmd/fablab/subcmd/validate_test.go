@@ -0,0 +1,62 @@
+package subcmd
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidateCommand_ReportsPositions(t *testing.T) {
+	yaml := `model:
+  id: test-validate
+regions:
+  us-east-1:
+    hosts:
+      host1:
+        components:
+          - type: unknown-type
+`
+	path := writeTempYaml(t, yaml)
+	defer os.Remove(path)
+
+	out := &bytes.Buffer{}
+	cmd := NewValidateCommand()
+	cmd.SetOut(out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--config", path})
+
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected error for unknown component type")
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	if !strings.Contains(lines[0], ":8:19: error: regions.us-east-1.hosts.host1.components[0].type: ") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+	if strings.TrimSpace(lines[1]) != "- type: unknown-type" || strings.TrimSpace(lines[2]) != "^" {
+		t.Fatalf("expected snippet with caret, got:\n%s", out.String())
+	}
+}
+
+func TestValidateCommand_Valid(t *testing.T) {
+	yaml := `model:
+  id: test-validate
+regions:
+  us-east-1:
+    hosts:
+      host1:
+        components:
+          - type: ziti-controller
+`
+	path := writeTempYaml(t, yaml)
+	defer os.Remove(path)
+
+	cmd := NewValidateCommand()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--config", path})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("validate command failed: %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package subcmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/openziti/fablab/kernel/model"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.AddCommand(NewVarsCommand())
+}
+
+func NewVarsCommand() *cobra.Command {
+	varsCmd := &VarsCommand{}
+
+	cmd := &cobra.Command{
+		Use:   "vars",
+		Short: "inspect how variables resolve",
+	}
+
+	cmd.PersistentFlags().StringVarP(&varsCmd.Type, "type", "t", model.EntityTypeComponent, "type of entity to select: host, component or region. The spec 'model' selects the model itself")
+	cmd.PersistentFlags().StringArrayVarP(&varsCmd.ConfigPaths, "config", "c", nil, "path to YAML configuration file, repeat to overlay files in order. Defaults to the active instance")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "explain <entity-spec> <name>",
+		Short: "show every resolver consulted for a variable and which one answered",
+		Args:  cobra.ExactArgs(2),
+		RunE:  varsCmd.explain,
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list <entity-spec>",
+		Short: "show the effective variables of the selected entities",
+		Args:  cobra.ExactArgs(1),
+		RunE:  varsCmd.list,
+	})
+
+	return cmd
+}
+
+type VarsCommand struct {
+	Type        string
+	ConfigPaths []string
+}
+
+func (v *VarsCommand) explain(cmd *cobra.Command, args []string) error {
+	entities, err := v.selectEntities(args[0])
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	for i, entity := range entities {
+		if i > 0 {
+			_, _ = fmt.Fprintln(out)
+		}
+		printVariableTrace(out, entity.GetScope().TraceVariable(args[1]))
+	}
+	return nil
+}
+
+func (v *VarsCommand) list(cmd *cobra.Command, args []string) error {
+	entities, err := v.selectEntities(args[0])
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	for i, entity := range entities {
+		if i > 0 {
+			_, _ = fmt.Fprintln(out)
+		}
+		_, _ = fmt.Fprintln(out, describeEntity(entity))
+		variables := model.ListVariables(entity)
+		if len(variables) == 0 {
+			_, _ = fmt.Fprintln(out, "  no variables")
+		}
+		for _, variable := range variables {
+			_, _ = fmt.Fprintf(out, "  %-40s %-30v %s\n", variable.Name, variable.Value, describeVariableSource(variable.Name, variable.Source))
+		}
+	}
+	return nil
+}
+
+func (v *VarsCommand) selectEntities(spec string) ([]model.Entity, error) {
+	m, err := loadModel(v.ConfigPaths)
+	if err != nil {
+		return nil, err
+	}
+	if spec == model.EntityTypeModel {
+		return []model.Entity{m}, nil
+	}
+
+	matches, err := m.ExplainSelector(spec, v.Type)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("'%s' matched no %ss", spec, v.Type)
+	}
+	var result []model.Entity
+	for _, match := range matches {
+		result = append(result, match.Entity)
+	}
+	return result, nil
+}
+
+// printVariableTrace writes the lookups made by the resolvers which hold values, up to the one
+// which answered, followed by the value.
+func printVariableTrace(out io.Writer, trace *model.VariableTrace) {
+	_, _ = fmt.Fprintf(out, "%s: %s\n", describeEntity(trace.Entity), trace.Name)
+
+	answer := trace.Answer()
+	step := 0
+	for i := range trace.Lookups {
+		lookup := &trace.Lookups[i]
+		if lookup.IsAggregate() {
+			continue
+		}
+		step++
+		result := "not found"
+		if lookup.Found {
+			result = fmt.Sprintf("found %v", lookup.Value)
+		}
+		if lookup.Detail != "" {
+			result += " (" + lookup.Detail + ")"
+		}
+		_, _ = fmt.Fprintf(out, "  %2d. %-12s %-40s %-40s %s\n", step, lookup.Resolver, describeEntity(lookup.Entity), lookup.Name, result)
+		if lookup == answer {
+			break
+		}
+	}
+
+	if !trace.Found {
+		_, _ = fmt.Fprintln(out, "  not found")
+		return
+	}
+	if answer != nil {
+		_, _ = fmt.Fprintf(out, "  answered by %s\n", describeVariableSource(trace.Name, answer))
+	}
+	_, _ = fmt.Fprintf(out, "  value: %v\n", trace.Value)
+}
+
+// describeVariableSource names the resolver which provided a value, the entity it was consulted
+// for, and the key it was found under if that isn't the variable's name.
+func describeVariableSource(name string, source *model.VariableLookup) string {
+	if source == nil {
+		return "-"
+	}
+	result := fmt.Sprintf("%s on %s", source.Resolver, describeEntity(source.Entity))
+	if source.Name != name {
+		result += " as " + source.Name
+	}
+	if source.Detail != "" {
+		result += " (" + source.Detail + ")"
+	}
+	return result
+}
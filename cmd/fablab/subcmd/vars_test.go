@@ -0,0 +1,102 @@
+package subcmd
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+const varsTestYaml = `model:
+  id: test-vars
+  variables:
+    ziti:
+      version: 1.1.0
+    credentials:
+      password: hunter2
+regions:
+  us-east-1:
+    variables:
+      ziti:
+        version: 1.2.0
+    hosts:
+      ctrl:
+        components:
+          - type: ziti-controller
+            id: ctrl
+`
+
+func runVars(t *testing.T, args ...string) (string, error) {
+	path := writeTempYaml(t, varsTestYaml)
+	t.Cleanup(func() { _ = os.Remove(path) })
+
+	out := &bytes.Buffer{}
+	cmd := NewVarsCommand()
+	cmd.SetOut(out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs(append(args, "--config", path))
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestVarsCommand_Explain(t *testing.T) {
+	out, err := runVars(t, "explain", "#ctrl", "ziti.version")
+	if err != nil {
+		t.Fatalf("vars explain failed: %v", err)
+	}
+
+	for _, expected := range []string{
+		"component us-east-1.ctrl.ctrl: ziti.version",
+		"env          component us-east-1.ctrl.ctrl            us-east-1.ctrl.ctrl.ziti.version         not found (env.name=US-EAST-1_CTRL_CTRL_ZITI_VERSION)",
+		"hierarchical region us-east-1                         ziti.version                             found 1.2.0",
+		"answered by hierarchical on region us-east-1",
+		"value: 1.2.0",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected output to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
+
+func TestVarsCommand_ExplainEnvOverride(t *testing.T) {
+	t.Setenv("ZITI_VERSION", "2.0.0")
+	out, err := runVars(t, "explain", "--type", "host", "ctrl", "ziti.version")
+	if err != nil {
+		t.Fatalf("vars explain failed: %v", err)
+	}
+	if !strings.Contains(out, "answered by env on host us-east-1.ctrl (env.name=ZITI_VERSION)") || !strings.Contains(out, "value: 2.0.0") {
+		t.Fatalf("expected the environment to answer, got:\n%s", out)
+	}
+}
+
+func TestVarsCommand_RedactsSecrets(t *testing.T) {
+	out, err := runVars(t, "explain", "model", "credentials.password")
+	if err != nil {
+		t.Fatalf("vars explain failed: %v", err)
+	}
+	if strings.Contains(out, "hunter2") || !strings.Contains(out, "value: **secret**") {
+		t.Fatalf("expected password to be redacted, got:\n%s", out)
+	}
+
+	out, err = runVars(t, "list", "#ctrl")
+	if err != nil {
+		t.Fatalf("vars list failed: %v", err)
+	}
+	if strings.Contains(out, "hunter2") {
+		t.Fatalf("expected password to be redacted, got:\n%s", out)
+	}
+	for _, expected := range []string{
+		"credentials.password                     **secret**",
+		"ziti.version                             1.2.0                          hierarchical on region us-east-1",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected output to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
+
+func TestVarsCommand_NoMatch(t *testing.T) {
+	if _, err := runVars(t, "list", "#missing"); err == nil || !strings.Contains(err.Error(), "matched no components") {
+		t.Fatalf("expected no match error, got %v", err)
+	}
+}
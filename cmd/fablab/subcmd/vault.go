@@ -0,0 +1,153 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package subcmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openziti/fablab/kernel/model"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v2"
+)
+
+func init() {
+	RootCmd.AddCommand(NewVaultCommand())
+}
+
+func NewVaultCommand() *cobra.Command {
+	vaultCmd := &VaultCommand{}
+
+	cmd := &cobra.Command{
+		Use:   "vault",
+		Short: "manage encrypted vault files used by vault secret providers",
+	}
+
+	cmd.PersistentFlags().StringVar(&vaultCmd.PasswordFile, "password-file", "", "file holding the vault password. Defaults to $"+model.DefaultVaultPasswordEnv+", or a prompt")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "encrypt <variables.yml> <vault>",
+		Short: "encrypt a YAML file of variables into a vault",
+		Args:  cobra.ExactArgs(2),
+		RunE:  vaultCmd.encrypt,
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "decrypt <vault>",
+		Short: "print the decrypted variables of a vault",
+		Args:  cobra.ExactArgs(1),
+		RunE:  vaultCmd.decrypt,
+	})
+
+	return cmd
+}
+
+type VaultCommand struct {
+	PasswordFile string
+}
+
+func (v *VaultCommand) encrypt(cmd *cobra.Command, args []string) error {
+	plaintext, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	variables := model.Variables{}
+	if err = yaml.Unmarshal(plaintext, &variables); err != nil {
+		return fmt.Errorf("%s does not hold YAML variables (%w)", args[0], err)
+	}
+
+	password, err := v.password(true)
+	if err != nil {
+		return err
+	}
+	data, err := model.EncryptVault(plaintext, password)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(args[1], data, 0600); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "encrypted %d variable(s) into %s\n", len(variables), args[1])
+	return nil
+}
+
+func (v *VaultCommand) decrypt(cmd *cobra.Command, args []string) error {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	password, err := v.password(false)
+	if err != nil {
+		return err
+	}
+	plaintext, err := model.DecryptVault(data, password)
+	if err != nil {
+		return err
+	}
+	_, err = cmd.OutOrStdout().Write(plaintext)
+	return err
+}
+
+// password reads the vault password from the password file, the environment or a prompt. New
+// passwords are prompted for twice.
+func (v *VaultCommand) password(confirm bool) (string, error) {
+	if v.PasswordFile != "" {
+		data, err := os.ReadFile(v.PasswordFile)
+		if err != nil {
+			return "", fmt.Errorf("unable to read password file (%w)", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if password, found := os.LookupEnv(model.DefaultVaultPasswordEnv); found {
+		return password, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no vault password, use --password-file or set %s", model.DefaultVaultPasswordEnv)
+	}
+	password, err := promptPassword(fd, "vault password: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		again, err := promptPassword(fd, "confirm vault password: ")
+		if err != nil {
+			return "", err
+		}
+		if again != password {
+			return "", errors.New("passwords do not match")
+		}
+	}
+	if password == "" {
+		return "", errors.New("empty vault password")
+	}
+	return password, nil
+}
+
+func promptPassword(fd int, prompt string) (string, error) {
+	_, _ = fmt.Fprint(os.Stderr, prompt)
+	password, err := term.ReadPassword(fd)
+	_, _ = fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("unable to read password (%w)", err)
+	}
+	return string(password), nil
+}
@@ -26,16 +26,19 @@ import (
 )
 
 func init() {
+	verifyUpCmd.Flags().DurationVarP(&verifyUpTimeout, "timeout", "t", model.DefaultReadyTimeout, "how long to wait for the components to be ready")
 	RootCmd.AddCommand(verifyUpCmd)
 }
 
 var verifyUpCmd = &cobra.Command{
 	Use:   "verify-up <componentSpec>",
-	Short: "verifies that the selected components are up and running",
+	Short: "verifies that the selected components are up and ready, using their health checks where declared",
 	Args:  cobra.ExactArgs(1),
 	Run:   verifyUp,
 }
 
+var verifyUpTimeout time.Duration
+
 func verifyUp(_ *cobra.Command, args []string) {
 	ctx, err := model.MustBootstrapContext()
 	if err != nil {
@@ -53,25 +56,13 @@ func verifyUp(_ *cobra.Command, args []string) {
 		logrus.WithError(err).Fatal("error initializing run")
 	}
 
-	deadline := time.Now().Add(time.Minute)
+	deadline := time.Now().Add(verifyUpTimeout)
 
 	for _, c := range components {
 		log := pfxlog.Logger().WithField("componentId", c.Id)
-
-		running := false
-		for !running {
-			if time.Now().After(deadline) {
-				log.Fatal("timed out waiting for component to be running")
-			}
-			running, err = c.IsRunning(run)
-			if err != nil {
-				log.WithError(err).Fatal("unable to check component status")
-			}
-			if !running {
-				log.Info("component not running yet, waiting 1 second")
-			}
-			time.Sleep(time.Second)
+		if err := c.WaitReady(run, time.Until(deadline)); err != nil {
+			log.WithError(err).Fatal("component is not ready")
 		}
-		log.Info("component is running")
+		log.Info("component is ready")
 	}
 }
@@ -1,6 +1,9 @@
 package engine
 
 import (
+	"encoding/json"
+	"sort"
+
 	"github.com/openziti/fablab/kernel/model"
 	"github.com/openziti/fablab/kernel/store"
 	"github.com/sirupsen/logrus"
@@ -17,15 +20,15 @@ const (
 
 // ResourceChange represents a single resource that needs to be created, updated, or deleted.
 type ResourceChange struct {
-	Id           string
-	Type         string // "host", "component"
-	RegionId     string
-	HostId       string
-	ComponentId  string            // for component-level changes
-	Action       Action            // create, update, delete
-	Changes      []string          // list of changed fields for updates
-	OldMetadata  map[string]string // previous state
-	NewMetadata  map[string]string // desired state
+	Id          string
+	Type        string // "host", "component"
+	RegionId    string
+	HostId      string
+	ComponentId string            // for component-level changes
+	Action      Action            // create, update, delete
+	Changes     []string          // list of changed fields for updates
+	OldMetadata map[string]string // previous state
+	NewMetadata map[string]string // desired state
 }
 
 // Diff represents the difference between desired and current state.
@@ -111,9 +114,7 @@ func ComputeDiff(desired, current *model.Model) *Diff {
 					HostId:      desiredHost.Id,
 					ComponentId: compId,
 					Action:      ActionCreate,
-					NewMetadata: map[string]string{
-						"componentType": comp.Type.Label(),
-					},
+					NewMetadata: componentMetadata(comp),
 				})
 			}
 		} else {
@@ -206,13 +207,13 @@ func computeComponentDiff(desiredHost, currentHost *model.Host) *Diff {
 				HostId:      desiredHost.Id,
 				ComponentId: compId,
 				Action:      ActionCreate,
-				NewMetadata: map[string]string{
-					"componentType": desiredComp.Type.Label(),
-				},
+				NewMetadata: componentMetadata(desiredComp),
 			})
 		} else {
-			// Check for component type change (rare but possible)
-			if desiredComp.Type.Label() != currentComp.Type.Label() {
+			// Check for component type, version or configuration changes
+			oldMetadata := componentMetadata(currentComp)
+			newMetadata := componentMetadata(desiredComp)
+			if changes := changedKeys(oldMetadata, newMetadata); len(changes) > 0 {
 				diff.ToUpdate = append(diff.ToUpdate, ResourceChange{
 					Id:          desiredHost.Id + "/" + compId,
 					Type:        "component",
@@ -220,13 +221,9 @@ func computeComponentDiff(desiredHost, currentHost *model.Host) *Diff {
 					HostId:      desiredHost.Id,
 					ComponentId: compId,
 					Action:      ActionUpdate,
-					Changes:     []string{"componentType"},
-					OldMetadata: map[string]string{
-						"componentType": currentComp.Type.Label(),
-					},
-					NewMetadata: map[string]string{
-						"componentType": desiredComp.Type.Label(),
-					},
+					Changes:     changes,
+					OldMetadata: oldMetadata,
+					NewMetadata: newMetadata,
 				})
 			}
 		}
@@ -249,6 +246,73 @@ func computeComponentDiff(desiredHost, currentHost *model.Host) *Diff {
 	return diff
 }
 
+// componentMetadata returns the stored representation of a component: its type label, version
+// and the JSON encoding of its type's Dump output.
+func componentMetadata(c *model.Component) map[string]string {
+	metadata := map[string]string{}
+	if c.Type == nil {
+		return metadata
+	}
+	metadata["componentType"] = c.Type.Label()
+	if version := c.Type.GetVersion(); version != "" {
+		metadata["version"] = version
+	}
+	if dump := c.Type.Dump(); dump != nil {
+		if data, err := json.Marshal(dump); err == nil {
+			metadata["config"] = string(data)
+		}
+	}
+	return metadata
+}
+
+// changedKeys returns the sorted keys whose values differ between the two metadata maps.
+func changedKeys(old, new map[string]string) []string {
+	var changes []string
+	for k, v := range new {
+		if old[k] != v {
+			changes = append(changes, k)
+		}
+	}
+	for k := range old {
+		if _, found := new[k]; !found {
+			changes = append(changes, k)
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// storedComponentType stands in for a component's type when the component is rebuilt from
+// stored metadata, reproducing the label, version and dump that were recorded.
+type storedComponentType struct {
+	label   string
+	version string
+	config  string
+}
+
+func (t *storedComponentType) Label() string {
+	return t.label
+}
+
+func (t *storedComponentType) GetVersion() string {
+	return t.version
+}
+
+func (t *storedComponentType) Dump() any {
+	if t.config == "" {
+		return nil
+	}
+	return json.RawMessage(t.config)
+}
+
+func (t *storedComponentType) IsRunning(model.Run, *model.Component) (bool, error) {
+	return false, nil
+}
+
+func (t *storedComponentType) Stop(model.Run, *model.Component) error {
+	return nil
+}
+
 // collectHosts returns a map of all hosts in the model keyed by their Id.
 func collectHosts(m *model.Model) map[string]*model.Host {
 	hosts := make(map[string]*model.Host)
@@ -265,7 +329,7 @@ func collectHosts(m *model.Model) map[string]*model.Host {
 
 // ReconcileOptions configures reconciliation behavior.
 type ReconcileOptions struct {
-	DryRun         bool
+	DryRun          bool
 	ContinueOnError bool
 }
 
@@ -460,11 +524,36 @@ func buildModelFromResources(resources map[string]store.ResourceState) *model.Mo
 		}
 
 		host := &model.Host{
-			Id:     res.Id,
-			Region: region,
+			Id:           res.Id,
+			Region:       region,
+			InstanceType: res.Metadata["instanceType"],
+			Components:   make(model.Components),
 		}
 		region.Hosts[res.Id] = host
 	}
 
+	hosts := collectHosts(m)
+	for _, res := range resources {
+		if res.Type != "component" {
+			continue
+		}
+
+		host, found := hosts[res.Metadata["hostId"]]
+		if !found {
+			continue
+		}
+
+		componentId := res.Metadata["componentId"]
+		host.Components[componentId] = &model.Component{
+			Id:   componentId,
+			Host: host,
+			Type: &storedComponentType{
+				label:   res.Metadata["componentType"],
+				version: res.Metadata["version"],
+				config:  res.Metadata["config"],
+			},
+		}
+	}
+
 	return m
 }
@@ -83,6 +83,38 @@ func TestReconciler_Idempotent(t *testing.T) {
 	}
 }
 
+func TestReconciler_ComponentConfigChanges(t *testing.T) {
+	memStore := store.NewMemoryStore()
+	r := NewReconciler(memStore)
+
+	m := createTestModel("component-test", 1, 1)
+	host := m.Regions["region-a"].Hosts["region-a-host-0"]
+	router := &model.ZitiRouterType{Version: "v1.0.0", Mode: "edge"}
+	host.Components["router"] = &model.Component{Id: "router", Host: host, Type: router}
+	ctx := model.NewContext(m, nil, nil)
+
+	if _, err := r.Reconcile(ctx); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	// unchanged config produces no diff
+	diff, _ := r.GetDiff(ctx)
+	if !diff.IsEmpty() {
+		t.Fatalf("expected empty diff, got %+v", diff)
+	}
+
+	router.Mode = "fabric"
+	router.Version = "v1.1.0"
+	diff, _ = r.GetDiff(ctx)
+	if len(diff.ToUpdate) != 1 {
+		t.Fatalf("expected 1 update, got %d", len(diff.ToUpdate))
+	}
+	changes := diff.ToUpdate[0].Changes
+	if len(changes) != 2 || changes[0] != "config" || changes[1] != "version" {
+		t.Errorf("expected config and version changes, got %v", changes)
+	}
+}
+
 func createTestModel(id string, regions, hostsPerRegion int) *model.Model {
 	m := &model.Model{
 		Id:      id,
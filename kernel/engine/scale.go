@@ -0,0 +1,348 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openziti/fablab/kernel/model"
+	"github.com/openziti/fablab/kernel/store"
+	"github.com/sirupsen/logrus"
+)
+
+// ScaleOptions configures a rescale.
+type ScaleOptions struct {
+	ReadyTimeout time.Duration
+}
+
+// ScaleChange is the change made to a single scale group.
+type ScaleChange struct {
+	Group   *model.ScaleGroup
+	From    uint32
+	To      uint32
+	Added   []model.Entity
+	Removed []model.Entity
+}
+
+// ScaleResult summarizes a rescale.
+type ScaleResult struct {
+	Changes           []*ScaleChange
+	AddedHosts        []*model.Host
+	AddedComponents   []*model.Component // including those of added hosts and regions
+	RemovedHosts      []*model.Host
+	RemovedComponents []*model.Component // including those of removed hosts and regions
+}
+
+// IsEmpty returns true if no entities were added or removed.
+func (r *ScaleResult) IsEmpty() bool {
+	return len(r.AddedHosts) == 0 && len(r.AddedComponents) == 0 && len(r.RemovedHosts) == 0 && len(r.RemovedComponents) == 0
+}
+
+// syncedHosts returns the hosts the kit is synced to: the new hosts, and those of new components
+// added to hosts which were already there.
+func (r *ScaleResult) syncedHosts() []*model.Host {
+	result := append([]*model.Host(nil), r.AddedHosts...)
+	for _, host := range hostsOfComponents(r.AddedComponents) {
+		if !slices.Contains(result, host) {
+			result = append(result, host)
+		}
+	}
+	return result
+}
+
+// Scaler changes the number of entities in scale groups of a running instance, taking the new
+// entities as far through the lifecycle as the instance has gone, and recording the changes in
+// the label and the store.
+type Scaler struct {
+	Store store.ResourceStore
+	// Express creates and disposes of hosts, model.Model.ExpressStages by default
+	Express func(run model.Run) error
+	// Build kits the new components, model.Model.BuildComponentStages by default
+	Build func(run model.Run, components []*model.Component) error
+	// Sync distributes the kit to the new hosts and those of the new components, and initializes
+	// them for the new components, model.Model.SyncHostStages by default
+	Sync func(run model.Run, hosts []*model.Host, components []*model.Component) error
+}
+
+// NewScaler creates a new Scaler with the given store.
+func NewScaler(s store.ResourceStore) *Scaler {
+	return &Scaler{
+		Store:   s,
+		Express: func(run model.Run) error { return run.GetModel().ExpressStages(run) },
+		Build: func(run model.Run, components []*model.Component) error {
+			return run.GetModel().BuildComponentStages(run, components)
+		},
+		Sync: func(run model.Run, hosts []*model.Host, components []*model.Component) error {
+			return run.GetModel().SyncHostStages(run, hosts, components)
+		},
+	}
+}
+
+// Scale sets the count of the scale groups matching spec, which is either the key of a group or
+// a selector matching members of the groups. Components scaled away are drained and stopped,
+// dependents first, and their hosts disposed of by expressing the infrastructure again. New
+// hosts are expressed and new components built, synced and started, dependencies first, as far
+// as the state of the instance calls for. Only the new hosts and those of new components are
+// synced; other entities are left alone, though expressing the infrastructure and distribution
+// stages which aren't a model.HostStage visit the whole model. The new counts are persisted in
+// the label, so later runs build the model at the same scale.
+func (s *Scaler) Scale(run model.Run, spec string, count uint32, options ScaleOptions) (*ScaleResult, error) {
+	m := run.GetModel()
+	groups, err := selectScaleGroups(m, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	label := run.GetLabel()
+	state := model.Created
+	if label != nil {
+		state = label.State
+	}
+	instanceId := runInstanceId(run)
+
+	result := &ScaleResult{}
+	for _, group := range groups {
+		change := &ScaleChange{Group: group, From: group.Count(), To: count}
+		result.Changes = append(result.Changes, change)
+		if count >= change.From {
+			continue
+		}
+
+		components := componentsOf(group.Members()[count:])
+		if state >= model.Activated {
+			if err := drainAndStop(run, components); err != nil {
+				return result, fmt.Errorf("unable to scale down [%s] (%w)", group.Key, err)
+			}
+		}
+		change.Removed = group.Shrink(count)
+		result.RemovedHosts = append(result.RemovedHosts, hostsOf(change.Removed)...)
+		result.RemovedComponents = append(result.RemovedComponents, components...)
+		logrus.Infof("scaled [%s] down from %d to %d", group.Key, change.From, count)
+	}
+
+	for _, change := range result.Changes {
+		if count <= change.From {
+			continue
+		}
+		change.Added, err = change.Group.Grow(count)
+		result.AddedHosts = append(result.AddedHosts, hostsOf(change.Added)...)
+		result.AddedComponents = append(result.AddedComponents, componentsOf(change.Added)...)
+		if err != nil {
+			return result, err
+		}
+		logrus.Infof("scaled [%s] up from %d to %d", change.Group.Key, change.From, count)
+	}
+
+	if result.IsEmpty() {
+		return result, nil
+	}
+
+	if err := s.persist(m, label, result); err != nil {
+		return result, err
+	}
+	if err := s.forget(instanceId, result); err != nil {
+		return result, err
+	}
+
+	if len(result.AddedHosts) > 0 || len(result.RemovedHosts) > 0 {
+		if state >= model.Expressed {
+			logrus.Infof("expressing %d new and %d removed host(s)", len(result.AddedHosts), len(result.RemovedHosts))
+			if err := s.Express(run); err != nil {
+				return result, fmt.Errorf("unable to express scaled hosts (%w)", err)
+			}
+		}
+		if err := s.recordHosts(instanceId, result.AddedHosts); err != nil {
+			return result, err
+		}
+	}
+
+	if len(result.AddedHosts) == 0 && len(result.AddedComponents) == 0 {
+		return result, nil
+	}
+	if state >= model.Configured {
+		if err := s.Build(run, result.AddedComponents); err != nil {
+			return result, fmt.Errorf("unable to build scaled components (%w)", err)
+		}
+	}
+	if state >= model.Distributed {
+		if err := s.Sync(run, result.syncedHosts(), result.AddedComponents); err != nil {
+			return result, fmt.Errorf("unable to sync scaled components (%w)", err)
+		}
+	}
+
+	status := store.StatusPending
+	if state >= model.Activated {
+		plan, err := m.PlanComponents(result.AddedComponents)
+		if err == nil {
+			err = startComponents(run, plan, options.readyTimeout())
+		}
+		if err != nil {
+			if recordErr := recordComponents(s.Store, instanceId, result.AddedComponents, store.StatusError, nil); recordErr != nil {
+				logrus.WithError(recordErr).Error("unable to record scaled components")
+			}
+			return result, fmt.Errorf("unable to start scaled components (%w)", err)
+		}
+		status = store.StatusRunning
+	}
+	return result, recordComponents(s.Store, instanceId, result.AddedComponents, status, nil)
+}
+
+// persist records the new counts in the label, and gives the new entities stable indexes.
+func (s *Scaler) persist(m *model.Model, label *model.Label, result *ScaleResult) error {
+	if label == nil {
+		return nil
+	}
+	if label.Scales == nil {
+		label.Scales = map[string]uint32{}
+	}
+	for _, change := range result.Changes {
+		label.Scales[change.Group.Key] = change.To
+	}
+	if err := m.BindIndexes(label); err != nil {
+		return fmt.Errorf("unable to record entity indexes (%w)", err)
+	}
+	if err := label.Save(); err != nil {
+		return fmt.Errorf("unable to save scale to label (%w)", err)
+	}
+	return nil
+}
+
+// forget removes the hosts and components scaled away from the store.
+func (s *Scaler) forget(instanceId string, result *ScaleResult) error {
+	if s.Store == nil {
+		return nil
+	}
+	for _, c := range result.RemovedComponents {
+		if err := s.Store.DeleteResource(instanceId, c.GetHost().Id+"/"+c.Id); err != nil {
+			return fmt.Errorf("unable to forget component '%s' (%w)", c.GetPathId(), err)
+		}
+	}
+	for _, host := range result.RemovedHosts {
+		if err := s.Store.DeleteResource(instanceId, host.Id); err != nil {
+			return fmt.Errorf("unable to forget host '%s' (%w)", host.GetPath(), err)
+		}
+	}
+	return nil
+}
+
+func (s *Scaler) recordHosts(instanceId string, hosts []*model.Host) error {
+	if s.Store == nil {
+		return nil
+	}
+	now := time.Now().Unix()
+	for _, host := range hosts {
+		resource := store.ResourceState{
+			Id:     host.Id,
+			Type:   "host",
+			Status: store.StatusRunning,
+			Metadata: mergeMetadata(map[string]string{
+				"regionId": host.Region.Id,
+				"hostId":   host.Id,
+			}, map[string]string{"instanceType": host.InstanceType}),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := s.Store.SaveResource(instanceId, resource); err != nil {
+			return fmt.Errorf("unable to record host '%s' (%w)", host.GetPath(), err)
+		}
+	}
+	return nil
+}
+
+// selectScaleGroups returns the group keyed by spec, or else the groups with members matching
+// spec as a selector.
+func selectScaleGroups(m *model.Model, spec string) ([]*model.ScaleGroup, error) {
+	if group := m.GetScaleGroup(spec); group != nil {
+		return []*model.ScaleGroup{group}, nil
+	}
+
+	var keys []string
+	for _, group := range m.GetScaleGroups() {
+		keys = append(keys, group.Key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("model '%s' has no scaled entities", m.Id)
+	}
+
+	if _, err := model.ParseSelector(spec); err != nil {
+		return nil, err
+	}
+	selected := map[model.Entity]bool{}
+	for _, region := range m.SelectRegions(spec) {
+		selected[region] = true
+	}
+	for _, host := range m.SelectHosts(spec) {
+		selected[host] = true
+	}
+	for _, component := range m.SelectComponents(spec) {
+		selected[component] = true
+	}
+
+	var result []*model.ScaleGroup
+	for _, group := range m.GetScaleGroups() {
+		for _, member := range group.Members() {
+			if selected[member] {
+				result = append(result, group)
+				break
+			}
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("'%s' matched no scale groups, groups are: %s", spec, strings.Join(keys, ", "))
+	}
+	return result, nil
+}
+
+// drainAndStop drains and stops the components, dependents first.
+func drainAndStop(run model.Run, components []*model.Component) error {
+	plan, err := run.GetModel().PlanComponents(components)
+	if err != nil {
+		return err
+	}
+	return plan.ExecuteReverse(1, func(c *model.Component) error {
+		if drainable, ok := c.Type.(model.DrainableComponent); ok {
+			if err := drainable.Drain(run, c); err != nil {
+				return fmt.Errorf("unable to drain component '%s' (%w)", c.GetPathId(), err)
+			}
+		}
+		if err := c.Type.Stop(run, c); err != nil {
+			return fmt.Errorf("unable to stop component '%s' (%w)", c.GetPathId(), err)
+		}
+		return nil
+	})
+}
+
+// hostsOf returns the hosts among the entities, and those of the regions among them.
+func hostsOf(entities []model.Entity) []*model.Host {
+	var result []*model.Host
+	for _, entity := range entities {
+		entity.Accept(func(e model.Entity) {
+			if host, ok := e.(*model.Host); ok {
+				result = append(result, host)
+			}
+		})
+	}
+	return result
+}
+
+// componentsOf returns the components among the entities, and those of the regions and hosts
+// among them.
+func componentsOf(entities []model.Entity) []*model.Component {
+	var result []*model.Component
+	for _, entity := range entities {
+		entity.Accept(func(e model.Entity) {
+			if component, ok := e.(*model.Component); ok {
+				result = append(result, component)
+			}
+		})
+	}
+	return result
+}
+
+func (o *ScaleOptions) readyTimeout() time.Duration {
+	if o.ReadyTimeout == 0 {
+		return model.DefaultReadyTimeout
+	}
+	return o.ReadyTimeout
+}
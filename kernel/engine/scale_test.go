@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openziti/fablab/kernel/model"
+	"github.com/openziti/fablab/kernel/store"
+)
+
+type scaleTestStrategy struct{}
+
+func (scaleTestStrategy) IsScaled(entity model.Entity) bool {
+	return entity.GetScope().HasTag("scaled")
+}
+
+func (scaleTestStrategy) GetEntityCount(model.Entity) uint32 {
+	return 2
+}
+
+// scaleTestType logs what happens to its components in events.
+type scaleTestType struct {
+	events *[]string
+}
+
+func (t *scaleTestType) Label() string                                       { return "scale-test" }
+func (t *scaleTestType) GetVersion() string                                  { return "1.0" }
+func (t *scaleTestType) Dump() any                                           { return nil }
+func (t *scaleTestType) IsRunning(model.Run, *model.Component) (bool, error) { return true, nil }
+
+func (t *scaleTestType) Stop(_ model.Run, c *model.Component) error {
+	*t.events = append(*t.events, "stop "+c.GetPathId())
+	return nil
+}
+
+func (t *scaleTestType) Start(_ model.Run, c *model.Component) error {
+	*t.events = append(*t.events, "start "+c.GetPathId())
+	return nil
+}
+
+func (t *scaleTestType) Drain(_ model.Run, c *model.Component) error {
+	*t.events = append(*t.events, "drain "+c.GetPathId())
+	return nil
+}
+
+func createScaleTestModel(t *testing.T, events *[]string) (model.Run, *model.Label) {
+	componentType := &scaleTestType{events: events}
+	m := &model.Model{
+		Id: "scale-test",
+		Regions: model.Regions{
+			"region": {
+				Hosts: model.Hosts{
+					"ctrl": {
+						Components: model.Components{"ctrl": {Type: componentType}},
+					},
+					"router-{{ .ScaleIndex }}": {
+						Scope: model.Scope{Tags: model.Tags{"scaled"}},
+						Components: model.Components{
+							"router": {Type: componentType, DependsOn: []string{"#ctrl"}},
+							"tunnel": {Type: componentType, DependsOn: []string{"#router"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	m.Init()
+	if err := model.NewScaleFactoryWithDefaultEntityFactory(scaleTestStrategy{}).Build(m); err != nil {
+		t.Fatal(err)
+	}
+	m.Init()
+
+	dir := t.TempDir()
+	if err := (&model.Label{InstanceId: "scale-test", Model: "scale-test", State: model.Activated}).SaveAtPath(dir); err != nil {
+		t.Fatal(err)
+	}
+	l, err := model.LoadLabel(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.BindIndexes(l); err != nil {
+		t.Fatal(err)
+	}
+	return model.NewContext(m, l, nil).NewRun(), l
+}
+
+func newTestScaler(s store.ResourceStore, events *[]string) *Scaler {
+	step := func(name string) func(model.Run) error {
+		return func(model.Run) error {
+			*events = append(*events, name)
+			return nil
+		}
+	}
+	return &Scaler{
+		Store:   s,
+		Express: step("express"),
+		Build: func(_ model.Run, components []*model.Component) error {
+			*events = append(*events, "build "+strings.Join(componentPaths(components), " "))
+			return nil
+		},
+		Sync: func(_ model.Run, hosts []*model.Host, _ []*model.Component) error {
+			var ids []string
+			for _, host := range hosts {
+				ids = append(ids, host.Id)
+			}
+			*events = append(*events, "sync "+strings.Join(ids, " "))
+			return nil
+		},
+	}
+}
+
+func TestScaler_ScaleUpAndDown(t *testing.T) {
+	var events []string
+	run, l := createScaleTestModel(t, &events)
+	memStore := store.NewMemoryStore()
+	scaler := newTestScaler(memStore, &events)
+
+	result, err := scaler.Scale(run, "#ctrl", 3, ScaleOptions{})
+	if err == nil || !strings.Contains(err.Error(), "matched no scale groups") {
+		t.Fatalf("expected unscaled entities to be rejected, got %v", err)
+	}
+
+	result, err = scaler.Scale(run, "region.router-{{ .ScaleIndex }}", 3, ScaleOptions{})
+	if err != nil {
+		t.Fatalf("scale up failed: %v", err)
+	}
+	if len(result.AddedHosts) != 1 || len(result.AddedComponents) != 2 {
+		t.Errorf("expected 1 host and 2 components added, got %d and %d", len(result.AddedHosts), len(result.AddedComponents))
+	}
+	expected := "express, build region.router-2.router region.router-2.tunnel, sync router-2, start region.router-2.router, start region.router-2.tunnel"
+	if actual := strings.Join(events, ", "); actual != expected {
+		t.Errorf("unexpected events\n got: %s\nwant: %s", actual, expected)
+	}
+	if l.Scales["region.router-{{ .ScaleIndex }}"] != 3 {
+		t.Errorf("expected scale to be persisted, got %v", l.Scales)
+	}
+	if index := l.Indexes.Hosts["region.router-2"]; index != 4 {
+		t.Errorf("expected new host to be given index 4, got %d", index)
+	}
+
+	resources, _ := memStore.GetResources("scale-test")
+	if resources["router-2"].Type != "host" || resources["router-2/tunnel"].Status != store.StatusRunning {
+		t.Errorf("expected new host and components to be recorded, got %v", resources)
+	}
+
+	events = nil
+	result, err = scaler.Scale(run, "#router-0", 1, ScaleOptions{})
+	if err != nil {
+		t.Fatalf("scale down failed: %v", err)
+	}
+	expected = "drain region.router-1.tunnel, stop region.router-1.tunnel, drain region.router-2.tunnel, stop region.router-2.tunnel, " +
+		"drain region.router-1.router, stop region.router-1.router, drain region.router-2.router, stop region.router-2.router, express"
+	if actual := strings.Join(events, ", "); actual != expected {
+		t.Errorf("unexpected events\n got: %s\nwant: %s", actual, expected)
+	}
+	if hosts := run.GetModel().SelectHosts("*"); len(hosts) != 2 {
+		t.Errorf("expected ctrl and router-0 to remain, got %d hosts", len(hosts))
+	}
+	if _, found := l.Indexes.Hosts["region.router-1"]; found {
+		t.Error("expected index of removed host to be released")
+	}
+	resources, _ = memStore.GetResources("scale-test")
+	if _, found := resources["router-2"]; found {
+		t.Error("expected removed host to be forgotten")
+	}
+
+	events = nil
+	if result, err = scaler.Scale(run, "region.router-{{ .ScaleIndex }}", 1, ScaleOptions{}); err != nil || !result.IsEmpty() || len(events) != 0 {
+		t.Errorf("expected scaling to the current count to do nothing, got %v %v", err, events)
+	}
+}
+
+func TestScaler_FollowsInstanceState(t *testing.T) {
+	var events []string
+	run, l := createScaleTestModel(t, &events)
+	l.State = model.Expressed
+
+	memStore := store.NewMemoryStore()
+	if _, err := newTestScaler(memStore, &events).Scale(run, "region.router-{{ .ScaleIndex }}", 3, ScaleOptions{}); err != nil {
+		t.Fatalf("scale up failed: %v", err)
+	}
+	if actual := strings.Join(events, ", "); actual != "express" {
+		t.Errorf("expected an expressed instance to only express new hosts, got %s", actual)
+	}
+	resources, _ := memStore.GetResources("scale-test")
+	if resources["router-2/router"].Status != store.StatusPending {
+		t.Errorf("expected new components to be pending, got %v", resources["router-2/router"])
+	}
+}
+
+func TestScaler_SyncsNewHosts(t *testing.T) {
+	var events, synced []string
+	run, _ := createScaleTestModel(t, &events)
+	run.GetModel().Distribution = model.Stages{hostSyncStage{synced: &synced}}
+
+	scaler := NewScaler(nil)
+	scaler.Express = func(model.Run) error { return nil }
+	if _, err := scaler.Scale(run, "region.router-{{ .ScaleIndex }}", 4, ScaleOptions{}); err != nil {
+		t.Fatalf("scale up failed: %v", err)
+	}
+	if actual := strings.Join(synced, ", "); actual != "router-2 router-3" {
+		t.Errorf("expected only the new hosts to be synced, got %s", actual)
+	}
+}
@@ -0,0 +1,369 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/openziti/fablab/kernel/model"
+	"github.com/openziti/fablab/kernel/store"
+	"github.com/sirupsen/logrus"
+)
+
+// UpgradeFailurePolicy decides what happens when a batch of an upgrade fails.
+type UpgradeFailurePolicy string
+
+const (
+	// UpgradeRollback returns every component touched by the upgrade to its previous version.
+	UpgradeRollback UpgradeFailurePolicy = "rollback"
+	// UpgradePause stops the upgrade, leaving the failed batch as it is for inspection.
+	UpgradePause UpgradeFailurePolicy = "pause"
+)
+
+// DefaultUpgradeBatchSize is the number of components upgraded at once, unless configured otherwise.
+const DefaultUpgradeBatchSize = 1
+
+// UpgradeOptions configures a rolling upgrade.
+type UpgradeOptions struct {
+	Version      string
+	BatchSize    int
+	ReadyTimeout time.Duration
+	OnFailure    UpgradeFailurePolicy
+}
+
+// UpgradeResult summarizes a rolling upgrade.
+type UpgradeResult struct {
+	Upgraded   []*model.Component
+	Skipped    []*model.Component // already at the requested version
+	Failed     []*model.Component // the batch which failed, if any
+	RolledBack []*model.Component
+	Batches    int
+}
+
+// Upgrader performs rolling upgrades of versionable components, recording version changes in
+// the store.
+type Upgrader struct {
+	Store store.ResourceStore
+	// Build re-kits the model for the new versions, model.Model.BuildStages by default, so that
+	// the state of the instance is left as it is
+	Build func(run model.Run) error
+	// Sync distributes the kit to the hosts of a batch and initializes them for its components,
+	// model.Model.SyncHostStages by default, so that hosts outside the batch keep what they run
+	Sync func(run model.Run, hosts []*model.Host, components []*model.Component) error
+}
+
+// NewUpgrader creates a new Upgrader with the given store.
+func NewUpgrader(s store.ResourceStore) *Upgrader {
+	return &Upgrader{
+		Store: s,
+		Build: func(run model.Run) error { return run.GetModel().BuildStages(run) },
+		Sync: func(run model.Run, hosts []*model.Host, components []*model.Component) error {
+			return run.GetModel().SyncHostStages(run, hosts, components)
+		},
+	}
+}
+
+// Upgrade moves the components matching componentSpec to options.Version. The kit is built once
+// for the new version, then components are upgraded in batches, in dependency order. Each batch
+// is stopped, synced, started and waited on until ready before the next one begins. If a batch
+// fails the upgrade either rolls back or pauses, as set by options.OnFailure.
+func (u *Upgrader) Upgrade(run model.Run, componentSpec string, options UpgradeOptions) (*UpgradeResult, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	m := run.GetModel()
+	if _, err := model.ParseSelector(componentSpec); err != nil {
+		return nil, err
+	}
+	components := m.SelectComponents(componentSpec)
+	if len(components) == 0 {
+		return nil, fmt.Errorf("'%s' matched no components", componentSpec)
+	}
+
+	result := &UpgradeResult{}
+	var pending []*model.Component
+	for _, c := range components {
+		if _, ok := c.Type.(model.VersionableComponent); !ok {
+			return nil, fmt.Errorf("component '%s' of type '%s' does not support setting its version", c.GetPathId(), c.Type.Label())
+		}
+		if c.Type.GetVersion() == options.Version {
+			result.Skipped = append(result.Skipped, c)
+		} else {
+			pending = append(pending, c)
+		}
+	}
+	if len(pending) == 0 {
+		return result, nil
+	}
+
+	batches, err := planUpgradeBatches(m, pending, options.batchSize())
+	if err != nil {
+		return nil, err
+	}
+
+	previous := map[*model.Component]string{}
+	for _, c := range pending {
+		previous[c] = c.Type.GetVersion()
+	}
+	for _, c := range pending {
+		setVersion(c, options.Version)
+	}
+
+	logrus.Infof("kitting version %s for %d component(s)", options.Version, len(pending))
+	if err := u.Build(run); err != nil {
+		restoreVersions(pending, previous)
+		return nil, fmt.Errorf("unable to kit version %s (%w)", options.Version, err)
+	}
+
+	instanceId := runInstanceId(run)
+	var touched []*model.Component
+	for i, batch := range batches {
+		result.Batches++
+		logrus.Infof("upgrading batch %d of %d: %s", i+1, len(batches), componentPaths(batch))
+
+		if err := u.recordAll(instanceId, batch, store.StatusUpdating, previous); err != nil {
+			return result, err
+		}
+		touched = append(touched, batch...)
+
+		if err := u.restart(run, batch, options.readyTimeout()); err != nil {
+			result.Failed = batch
+			if recordErr := u.recordAll(instanceId, batch, store.StatusError, previous); recordErr != nil {
+				logrus.WithError(recordErr).Error("unable to record failed batch")
+			}
+			if options.onFailure() == UpgradePause {
+				for _, remaining := range batches[i+1:] {
+					restoreVersions(remaining, previous)
+				}
+				return result, fmt.Errorf("upgrade to %s paused at batch %d of %d (%w)", options.Version, i+1, len(batches), err)
+			}
+			if rollbackErr := u.rollback(run, instanceId, touched, previous, options); rollbackErr != nil {
+				return result, fmt.Errorf("upgrade to %s failed at batch %d of %d (%w), rollback failed (%v)", options.Version, i+1, len(batches), err, rollbackErr)
+			}
+			result.RolledBack = touched
+			return result, fmt.Errorf("upgrade to %s failed at batch %d of %d and was rolled back (%w)", options.Version, i+1, len(batches), err)
+		}
+
+		if err := u.recordAll(instanceId, batch, store.StatusRunning, previous); err != nil {
+			return result, err
+		}
+		result.Upgraded = append(result.Upgraded, batch...)
+	}
+
+	return result, nil
+}
+
+// rollback returns the touched components to their previous versions. The remaining components
+// never left their previous versions, but their kit has to be rebuilt all the same.
+func (u *Upgrader) rollback(run model.Run, instanceId string, touched []*model.Component, previous map[*model.Component]string, options UpgradeOptions) error {
+	logrus.Warnf("rolling back %d component(s): %s", len(touched), componentPaths(touched))
+
+	current := map[*model.Component]string{}
+	var all []*model.Component
+	for c := range previous {
+		current[c] = c.Type.GetVersion()
+		all = append(all, c)
+	}
+	restoreVersions(all, previous)
+
+	if err := u.Build(run); err != nil {
+		return fmt.Errorf("unable to kit previous versions (%w)", err)
+	}
+
+	var err error
+	if err = u.restart(run, touched, options.readyTimeout()); err != nil {
+		err = fmt.Errorf("unable to restart previous versions (%w)", err)
+	}
+
+	status := store.StatusRunning
+	if err != nil {
+		status = store.StatusError
+	}
+	if recordErr := u.recordAll(instanceId, touched, status, current); recordErr != nil && err == nil {
+		err = recordErr
+	}
+	return err
+}
+
+// restart stops the components, dependents first, syncs the kit to their hosts and starts them
+// again, dependencies first, waiting for each to become ready.
+func (u *Upgrader) restart(run model.Run, components []*model.Component, readyTimeout time.Duration) error {
+	m := run.GetModel()
+	plan, err := m.PlanComponents(components)
+	if err != nil {
+		return err
+	}
+
+	err = plan.ExecuteReverse(1, func(c *model.Component) error {
+		if err := c.Type.Stop(run, c); err != nil {
+			return fmt.Errorf("unable to stop component '%s' (%w)", c.GetPathId(), err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := u.Sync(run, hostsOfComponents(components), components); err != nil {
+		return fmt.Errorf("unable to sync (%w)", err)
+	}
+
+	return startComponents(run, plan, readyTimeout)
+}
+
+// startComponents starts the planned components, dependencies first, waiting for each to become
+// ready.
+func startComponents(run model.Run, plan *model.ComponentPlan, readyTimeout time.Duration) error {
+	return plan.Execute(1, func(c *model.Component) error {
+		startable, ok := c.Type.(model.ServerComponent)
+		if !ok {
+			return nil
+		}
+		if err := startable.Start(run, c); err != nil {
+			return fmt.Errorf("unable to start component '%s' (%w)", c.GetPathId(), err)
+		}
+		return c.WaitReady(run, readyTimeout)
+	})
+}
+
+// recordAll saves the components to the store with the given status. previous holds the
+// versions the components were moved away from.
+func (u *Upgrader) recordAll(instanceId string, components []*model.Component, status store.ResourceStatus, previous map[*model.Component]string) error {
+	return recordComponents(u.Store, instanceId, components, status, previous)
+}
+
+// recordComponents saves the components to the store with the given status. previous, if set,
+// holds the versions the components were moved away from.
+func recordComponents(s store.ResourceStore, instanceId string, components []*model.Component, status store.ResourceStatus, previous map[*model.Component]string) error {
+	if s == nil {
+		return nil
+	}
+	resources, err := s.GetResources(instanceId)
+	if err != nil {
+		return fmt.Errorf("unable to load resources for instance [%s] (%w)", instanceId, err)
+	}
+
+	now := time.Now().Unix()
+	for _, c := range components {
+		id := c.GetHost().Id + "/" + c.Id
+		resource, found := resources[id]
+		if !found {
+			resource = store.ResourceState{Id: id, Type: "component", CreatedAt: now}
+		}
+		resource.Status = status
+		resource.UpdatedAt = now
+		resource.Metadata = mergeMetadata(resource.Metadata, map[string]string{
+			"regionId":    c.GetRegion().Id,
+			"hostId":      c.GetHost().Id,
+			"componentId": c.Id,
+		})
+		resource.Metadata = mergeMetadata(resource.Metadata, componentMetadata(c))
+		if version := previous[c]; version != "" && version != c.Type.GetVersion() {
+			resource.Metadata["previousVersion"] = version
+		}
+		if err := s.SaveResource(instanceId, resource); err != nil {
+			return fmt.Errorf("unable to record component '%s' (%w)", c.GetPathId(), err)
+		}
+	}
+	return nil
+}
+
+// planUpgradeBatches splits the components into batches of at most size components, ordered so
+// that dependencies are upgraded before the components depending on them.
+func planUpgradeBatches(m *model.Model, components []*model.Component, size int) ([][]*model.Component, error) {
+	plan, err := m.PlanComponents(components)
+	if err != nil {
+		return nil, err
+	}
+	var ordered []*model.Component
+	err = plan.Execute(1, func(c *model.Component) error {
+		ordered = append(ordered, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var batches [][]*model.Component
+	for len(ordered) > 0 {
+		n := min(size, len(ordered))
+		batches = append(batches, ordered[:n])
+		ordered = ordered[n:]
+	}
+	return batches, nil
+}
+
+// runInstanceId returns the id resources of the run are stored under.
+func runInstanceId(run model.Run) string {
+	if label := run.GetLabel(); label != nil && label.InstanceId != "" {
+		return label.InstanceId
+	}
+	return run.GetModel().Id
+}
+
+// hostsOfComponents returns the hosts of the components, each once.
+func hostsOfComponents(components []*model.Component) []*model.Host {
+	var result []*model.Host
+	seen := map[*model.Host]bool{}
+	for _, c := range components {
+		if host := c.GetHost(); !seen[host] {
+			seen[host] = true
+			result = append(result, host)
+		}
+	}
+	return result
+}
+
+func setVersion(c *model.Component, version string) {
+	c.Type.(model.VersionableComponent).SetVersion(version)
+}
+
+func restoreVersions(components []*model.Component, versions map[*model.Component]string) {
+	for _, c := range components {
+		setVersion(c, versions[c])
+	}
+}
+
+func componentPaths(components []*model.Component) []string {
+	var result []string
+	for _, c := range components {
+		result = append(result, c.GetPathId())
+	}
+	return result
+}
+
+func (o *UpgradeOptions) validate() error {
+	if o.Version == "" {
+		return fmt.Errorf("no version given")
+	}
+	if o.BatchSize < 0 {
+		return fmt.Errorf("invalid batch size %d, must be at least 1", o.BatchSize)
+	}
+	switch o.OnFailure {
+	case "", UpgradeRollback, UpgradePause:
+		return nil
+	default:
+		return fmt.Errorf("invalid failure policy '%s', must be '%s' or '%s'", o.OnFailure, UpgradeRollback, UpgradePause)
+	}
+}
+
+func (o *UpgradeOptions) batchSize() int {
+	if o.BatchSize == 0 {
+		return DefaultUpgradeBatchSize
+	}
+	return o.BatchSize
+}
+
+func (o *UpgradeOptions) readyTimeout() time.Duration {
+	if o.ReadyTimeout == 0 {
+		return model.DefaultReadyTimeout
+	}
+	return o.ReadyTimeout
+}
+
+func (o *UpgradeOptions) onFailure() UpgradeFailurePolicy {
+	if o.OnFailure == "" {
+		return UpgradeRollback
+	}
+	return o.OnFailure
+}
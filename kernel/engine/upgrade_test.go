@@ -0,0 +1,350 @@
+package engine
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openziti/fablab/kernel/model"
+	"github.com/openziti/fablab/kernel/store"
+)
+
+// upgradeTestType fails to start at badVersion, logging what happens to it in events.
+type upgradeTestType struct {
+	version    string
+	badVersion string
+	running    bool
+	events     *[]string
+}
+
+func (t *upgradeTestType) Label() string             { return "upgrade-test" }
+func (t *upgradeTestType) GetVersion() string        { return t.version }
+func (t *upgradeTestType) SetVersion(version string) { t.version = version }
+func (t *upgradeTestType) Dump() any                 { return nil }
+
+func (t *upgradeTestType) IsRunning(model.Run, *model.Component) (bool, error) {
+	return t.running, nil
+}
+
+func (t *upgradeTestType) Stop(_ model.Run, c *model.Component) error {
+	t.running = false
+	*t.events = append(*t.events, "stop "+c.Id)
+	return nil
+}
+
+func (t *upgradeTestType) Start(_ model.Run, c *model.Component) error {
+	*t.events = append(*t.events, "start "+c.Id+"@"+t.version)
+	if t.version == t.badVersion {
+		return errors.New("crashed")
+	}
+	t.running = true
+	return nil
+}
+
+func createUpgradeTestModel(events *[]string, badVersion string) *model.Model {
+	newType := func() *upgradeTestType {
+		return &upgradeTestType{version: "1.0", badVersion: badVersion, running: true, events: events}
+	}
+	m := &model.Model{
+		Id: "upgrade-test",
+		Regions: model.Regions{
+			"region": {
+				Hosts: model.Hosts{
+					"host": {
+						Components: model.Components{
+							"ctrl":    {Type: newType()},
+							"router1": {Type: newType(), DependsOn: []string{"#ctrl"}},
+							"router2": {Type: newType(), DependsOn: []string{"#ctrl"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	m.Init()
+	return m
+}
+
+func newTestUpgrader(s store.ResourceStore, events *[]string) *Upgrader {
+	return &Upgrader{
+		Store: s,
+		Build: func(model.Run) error {
+			*events = append(*events, "build")
+			return nil
+		},
+		Sync: func(model.Run, []*model.Host, []*model.Component) error {
+			*events = append(*events, "sync")
+			return nil
+		},
+	}
+}
+
+// hostSyncStage records the hosts a distribution stage is limited to.
+type hostSyncStage struct {
+	synced *[]string
+}
+
+func (stage hostSyncStage) Execute(run model.Run) error {
+	return stage.ExecuteOnHosts(run, run.GetModel().SelectHosts("*"))
+}
+
+func (stage hostSyncStage) ExecuteOnHosts(_ model.Run, hosts []*model.Host) error {
+	var ids []string
+	for _, host := range hosts {
+		ids = append(ids, host.Id)
+	}
+	*stage.synced = append(*stage.synced, strings.Join(ids, " "))
+	return nil
+}
+
+func versionsOf(m *model.Model) string {
+	var result []string
+	for _, c := range m.SelectComponents("*") {
+		result = append(result, c.Id+"@"+c.Type.GetVersion())
+	}
+	return strings.Join(result, " ")
+}
+
+func TestUpgrader_Batches(t *testing.T) {
+	var events []string
+	m := createUpgradeTestModel(&events, "")
+	memStore := store.NewMemoryStore()
+
+	result, err := newTestUpgrader(memStore, &events).Upgrade(model.NewContext(m, nil, nil).NewRun(), "*", UpgradeOptions{
+		Version:   "2.0",
+		BatchSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("upgrade failed: %v", err)
+	}
+	if result.Batches != 2 || len(result.Upgraded) != 3 {
+		t.Errorf("expected 3 components upgraded in 2 batches, got %d in %d", len(result.Upgraded), result.Batches)
+	}
+
+	expected := "build, stop router1, stop ctrl, sync, start ctrl@2.0, start router1@2.0, stop router2, sync, start router2@2.0"
+	if actual := strings.Join(events, ", "); actual != expected {
+		t.Errorf("unexpected events\n got: %s\nwant: %s", actual, expected)
+	}
+
+	resources, err := memStore.GetResources("upgrade-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"host/ctrl", "host/router1", "host/router2"} {
+		resource := resources[id]
+		if resource.Status != store.StatusRunning {
+			t.Errorf("expected %s to be running, got %s", id, resource.Status)
+		}
+		if resource.Metadata["version"] != "2.0" || resource.Metadata["previousVersion"] != "1.0" {
+			t.Errorf("expected %s to be recorded as upgraded from 1.0 to 2.0, got %v", id, resource.Metadata)
+		}
+	}
+}
+
+func TestUpgrader_Rollback(t *testing.T) {
+	var events []string
+	m := createUpgradeTestModel(&events, "2.0")
+	m.SelectComponents("#ctrl")[0].Type.(*upgradeTestType).badVersion = ""
+	memStore := store.NewMemoryStore()
+
+	result, err := newTestUpgrader(memStore, &events).Upgrade(model.NewContext(m, nil, nil).NewRun(), "*", UpgradeOptions{
+		Version:      "2.0",
+		ReadyTimeout: time.Second,
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed at batch 2 of 3 and was rolled back") {
+		t.Fatalf("expected rolled back upgrade, got %v", err)
+	}
+	if len(result.RolledBack) != 2 {
+		t.Errorf("expected 2 components rolled back, got %d", len(result.RolledBack))
+	}
+	if versions := versionsOf(m); versions != "ctrl@1.0 router1@1.0 router2@1.0" {
+		t.Errorf("expected all components back at 1.0, got %s", versions)
+	}
+	if events[len(events)-1] != "start router1@1.0" {
+		t.Errorf("expected rollback to restart router1 last, got %v", events)
+	}
+
+	resources, _ := memStore.GetResources("upgrade-test")
+	for _, id := range []string{"host/ctrl", "host/router1"} {
+		resource := resources[id]
+		if resource.Status != store.StatusRunning || resource.Metadata["version"] != "1.0" || resource.Metadata["previousVersion"] != "2.0" {
+			t.Errorf("expected %s to be recorded as rolled back to 1.0, got %s %v", id, resource.Status, resource.Metadata)
+		}
+	}
+	if _, found := resources["host/router2"]; found {
+		t.Error("expected router2 to be untouched")
+	}
+}
+
+func TestUpgrader_Pause(t *testing.T) {
+	var events []string
+	m := createUpgradeTestModel(&events, "2.0")
+	memStore := store.NewMemoryStore()
+
+	result, err := newTestUpgrader(memStore, &events).Upgrade(model.NewContext(m, nil, nil).NewRun(), "*", UpgradeOptions{
+		Version:   "2.0",
+		OnFailure: UpgradePause,
+	})
+	if err == nil || !strings.Contains(err.Error(), "paused at batch 1 of 3") {
+		t.Fatalf("expected paused upgrade, got %v", err)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Id != "ctrl" || result.RolledBack != nil {
+		t.Errorf("expected ctrl to fail without rollback, got %v", result)
+	}
+	if versions := versionsOf(m); versions != "ctrl@2.0 router1@1.0 router2@1.0" {
+		t.Errorf("expected only ctrl at 2.0, got %s", versions)
+	}
+
+	resources, _ := memStore.GetResources("upgrade-test")
+	if resource := resources["host/ctrl"]; resource.Status != store.StatusError || resource.Metadata["version"] != "2.0" {
+		t.Errorf("expected ctrl to be recorded as failed at 2.0, got %s %v", resource.Status, resource.Metadata)
+	}
+}
+
+// hostScaleStrategy scales tagged hosts to three
+type hostScaleStrategy struct{}
+
+func (hostScaleStrategy) IsScaled(entity model.Entity) bool {
+	return entity.GetScope().HasTag("scaled")
+}
+
+func (hostScaleStrategy) GetEntityCount(model.Entity) uint32 {
+	return 3
+}
+
+func createScaledUpgradeTestModel(t *testing.T, events *[]string) *model.Model {
+	m := &model.Model{
+		Id: "upgrade-test",
+		Regions: model.Regions{
+			"region": {
+				Hosts: model.Hosts{
+					"host-{{ .ScaleIndex }}": {
+						Scope: model.Scope{Tags: model.Tags{"scaled"}},
+						Components: model.Components{
+							"router": {Type: &upgradeTestType{version: "1.0", running: true, events: events}},
+						},
+					},
+				},
+			},
+		},
+	}
+	m.Init()
+	if err := model.NewScaleFactoryWithDefaultEntityFactory(hostScaleStrategy{}).Build(m); err != nil {
+		t.Fatalf("unable to scale model: %v", err)
+	}
+	return m
+}
+
+func hostVersionsOf(m *model.Model) string {
+	var result []string
+	for _, c := range m.SelectComponents("*") {
+		result = append(result, c.GetHost().Id+"@"+c.Type.GetVersion())
+	}
+	sort.Strings(result)
+	return strings.Join(result, " ")
+}
+
+func TestUpgrader_ScaledComponents(t *testing.T) {
+	var events []string
+	m := createScaledUpgradeTestModel(t, &events)
+
+	if _, err := newTestUpgrader(nil, &events).Upgrade(model.NewContext(m, nil, nil).NewRun(), "host-0 > *", UpgradeOptions{Version: "2.0"}); err != nil {
+		t.Fatalf("upgrade failed: %v", err)
+	}
+	if versions := hostVersionsOf(m); versions != "host-0@2.0 host-1@1.0 host-2@1.0" {
+		t.Errorf("expected only the router on host-0 at 2.0, got %s", versions)
+	}
+
+	m = createScaledUpgradeTestModel(t, &events)
+	m.SelectComponents("host-1 > *")[0].Type.(*upgradeTestType).badVersion = "2.0"
+	_, err := newTestUpgrader(nil, &events).Upgrade(model.NewContext(m, nil, nil).NewRun(), "*", UpgradeOptions{
+		Version:      "2.0",
+		ReadyTimeout: time.Second,
+	})
+	if err == nil || !strings.Contains(err.Error(), "was rolled back") {
+		t.Fatalf("expected rolled back upgrade, got %v", err)
+	}
+	if versions := hostVersionsOf(m); versions != "host-0@1.0 host-1@1.0 host-2@1.0" {
+		t.Errorf("expected all routers back at 1.0, got %s", versions)
+	}
+}
+
+func TestUpgrader_Selection(t *testing.T) {
+	var events []string
+	m := createUpgradeTestModel(&events, "")
+	m.SelectComponents("#ctrl")[0].Type.(*upgradeTestType).version = "2.0"
+
+	result, err := newTestUpgrader(nil, &events).Upgrade(model.NewContext(m, nil, nil).NewRun(), "*", UpgradeOptions{Version: "2.0"})
+	if err != nil {
+		t.Fatalf("upgrade failed: %v", err)
+	}
+	if len(result.Skipped) != 1 || len(result.Upgraded) != 2 {
+		t.Errorf("expected ctrl skipped and routers upgraded, got %d skipped, %d upgraded", len(result.Skipped), len(result.Upgraded))
+	}
+
+	m.SelectComponents("#router2")[0].Type = &storedComponentType{label: "fixed"}
+	if _, err = newTestUpgrader(nil, &events).Upgrade(model.NewContext(m, nil, nil).NewRun(), "*", UpgradeOptions{Version: "3.0"}); err == nil || !strings.Contains(err.Error(), "does not support setting its version") {
+		t.Errorf("expected unversionable component to be rejected, got %v", err)
+	}
+	if _, err = newTestUpgrader(nil, &events).Upgrade(model.NewContext(m, nil, nil).NewRun(), "#nothing", UpgradeOptions{Version: "3.0"}); err == nil {
+		t.Error("expected empty selection to be rejected")
+	}
+	if _, err = newTestUpgrader(nil, &events).Upgrade(model.NewContext(m, nil, nil).NewRun(), "*", UpgradeOptions{Version: "3.0", OnFailure: "ignore"}); err == nil {
+		t.Error("expected unknown failure policy to be rejected")
+	}
+}
+
+func TestUpgrader_SyncsBatchHosts(t *testing.T) {
+	var events, synced []string
+	newType := func() *upgradeTestType {
+		return &upgradeTestType{version: "1.0", running: true, events: &events}
+	}
+	m := &model.Model{
+		Id: "upgrade-test",
+		Regions: model.Regions{
+			"region": {
+				Hosts: model.Hosts{
+					"host1": {Components: model.Components{"router1": {Type: newType()}}},
+					"host2": {Components: model.Components{"router2": {Type: newType()}}},
+				},
+			},
+		},
+		Distribution: model.Stages{hostSyncStage{synced: &synced}},
+	}
+	m.Init()
+
+	upgrader := NewUpgrader(nil)
+	upgrader.Build = func(model.Run) error { return nil }
+	if _, err := upgrader.Upgrade(model.NewContext(m, nil, nil).NewRun(), "*", UpgradeOptions{Version: "2.0"}); err != nil {
+		t.Fatalf("upgrade failed: %v", err)
+	}
+	if actual := strings.Join(synced, ", "); actual != "host1, host2" {
+		t.Errorf("expected each batch to sync only its own host, got %s", actual)
+	}
+}
+
+func TestUpgrader_KeepsInstanceState(t *testing.T) {
+	var events []string
+	m := createUpgradeTestModel(&events, "")
+
+	dir := t.TempDir()
+	if err := (&model.Label{InstanceId: "upgrade-test", Model: "upgrade-test", State: model.Activated}).SaveAtPath(dir); err != nil {
+		t.Fatal(err)
+	}
+	l, err := model.LoadLabel(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = NewUpgrader(nil).Upgrade(model.NewContext(m, l, nil).NewRun(), "*", UpgradeOptions{Version: "2.0"}); err != nil {
+		t.Fatalf("upgrade failed: %v", err)
+	}
+	if l.State != model.Activated || len(l.History) != 0 {
+		t.Errorf("expected the instance to stay activated without transitions, got %s with %d transition(s)", l.State, len(l.History))
+	}
+	if loaded, err := model.LoadLabel(dir); err != nil || loaded.State != model.Activated {
+		t.Errorf("expected the saved label to stay activated, got %v (%v)", loaded, err)
+	}
+}
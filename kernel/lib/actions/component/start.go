@@ -17,6 +17,8 @@
 package component
 
 import (
+	"time"
+
 	"github.com/openziti/fablab/kernel/model"
 )
 
@@ -25,16 +27,31 @@ func Start(componentSpec string) model.Action {
 }
 
 func StartInParallel(componentSpec string, concurrency int) model.Action {
+	return StartInParallelWithReadyTimeout(componentSpec, concurrency, model.DefaultReadyTimeout)
+}
+
+// StartInParallelWithReadyTimeout starts the components, waiting up to readyTimeout for those
+// with health checks to become ready before starting the components depending on them.
+func StartInParallelWithReadyTimeout(componentSpec string, concurrency int, readyTimeout time.Duration) model.Action {
 	return &start{
 		componentSpec: componentSpec,
 		concurrency:   concurrency,
+		readyTimeout:  readyTimeout,
 	}
 }
 
+// Execute starts the components in dependency order, see model.Component.DependsOn.
 func (start *start) Execute(run model.Run) error {
-	return run.GetModel().ForEachComponent(start.componentSpec, start.concurrency, func(c *model.Component) error {
-		if startable, ok := c.Type.(model.ServerComponent); ok {
-			return startable.Start(run, c)
+	return run.GetModel().ForEachComponentByDependency(start.componentSpec, start.concurrency, false, func(c *model.Component) error {
+		startable, ok := c.Type.(model.ServerComponent)
+		if !ok {
+			return nil
+		}
+		if err := startable.Start(run, c); err != nil {
+			return err
+		}
+		if len(c.GetHealthChecks()) > 0 {
+			return c.WaitReady(run, start.readyTimeout)
 		}
 		return nil
 	})
@@ -43,4 +60,5 @@ func (start *start) Execute(run model.Run) error {
 type start struct {
 	componentSpec string
 	concurrency   int
+	readyTimeout  time.Duration
 }
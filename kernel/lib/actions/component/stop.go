@@ -38,8 +38,9 @@ func StopInParallelHostExclusive(componentSpec string, concurrency int) model.Ac
 	}
 }
 
+// Execute stops the components in reverse dependency order, see model.Component.DependsOn.
 func (stop *stop) Execute(run model.Run) error {
-	return run.GetModel().ForEachComponent(stop.componentSpec, stop.concurrency, func(c *model.Component) error {
+	return run.GetModel().ForEachComponentByDependency(stop.componentSpec, stop.concurrency, true, func(c *model.Component) error {
 		if c.Type != nil {
 			return c.Type.Stop(run, c)
 		}
@@ -58,7 +59,7 @@ type stopByHost struct {
 }
 
 func (stop *stopByHost) Execute(run model.Run) error {
-	return run.GetModel().ForEachComponent(stop.componentSpec, stop.concurrency, func(c *model.Component) error {
+	return run.GetModel().ForEachComponentByDependency(stop.componentSpec, stop.concurrency, true, func(c *model.Component) error {
 		return c.Host.DoExclusiveFallible(func() error {
 			if c.Type != nil {
 				return c.Type.Stop(run, c)
@@ -20,29 +20,35 @@ func DistributeDataWithReplaceCallbacks(hostSpec, data, dest string, filemode os
 }
 
 func (df *distDataWithReplaceCallbacks) Execute(run model.Run) error {
-	return run.GetModel().ForEachHost(df.hostSpec, 25, func(host *model.Host) error {
-		ssh := host.NewSshConfigFactory()
+	return run.GetModel().ForEachHost(df.hostSpec, 25, df.distribute)
+}
+
+func (df *distDataWithReplaceCallbacks) ExecuteOnHosts(run model.Run, hosts []*model.Host) error {
+	return run.GetModel().ForEachHostIn(df.hostSpec, hosts, 25, df.distribute)
+}
+
+func (df *distDataWithReplaceCallbacks) distribute(host *model.Host) error {
+	ssh := host.NewSshConfigFactory()
 
-		dataRaw := df.data
+	dataRaw := df.data
 
-		for k, v := range df.callbacks {
-			dataRaw = strings.ReplaceAll(dataRaw, k, v(host))
-		}
+	for k, v := range df.callbacks {
+		dataRaw = strings.ReplaceAll(dataRaw, k, v(host))
+	}
 
-		if err := libssh.SendData(ssh, []byte(dataRaw), df.dest); err != nil {
-			logrus.Errorf("[%s] unable to send data => %s", host.PublicIp, df.dest)
-			return err
-		}
+	if err := libssh.SendData(ssh, []byte(dataRaw), df.dest); err != nil {
+		logrus.Errorf("[%s] unable to send data => %s", host.PublicIp, df.dest)
+		return err
+	}
 
-		if err := libssh.Chmod(ssh, df.dest, df.filemode); err != nil {
-			logrus.Errorf("[%s] unable to send data => %s", host.PublicIp, df.dest)
-			return err
-		}
+	if err := libssh.Chmod(ssh, df.dest, df.filemode); err != nil {
+		logrus.Errorf("[%s] unable to send data => %s", host.PublicIp, df.dest)
+		return err
+	}
 
-		logrus.Infof("[%s] data => %s", host.PublicIp, df.dest)
+	logrus.Infof("[%s] data => %s", host.PublicIp, df.dest)
 
-		return nil
-	})
+	return nil
 }
 
 type distDataWithReplaceCallbacks struct {
@@ -62,22 +68,28 @@ func DistributeData(hostSpec string, data []byte, dest string) model.Stage {
 }
 
 func (df *distData) Execute(run model.Run) error {
-	return run.GetModel().ForEachHost(df.hostSpec, 25, func(host *model.Host) error {
-		ssh := host.NewSshConfigFactory()
-		if err := libssh.SendData(ssh, df.data, df.dest); err != nil {
-			logrus.Errorf("[%s] unable to send data => %s", host.PublicIp, df.dest)
-			return err
-		}
-
-		if err := libssh.Chmod(ssh, df.dest, os.FileMode(0644)); err != nil {
-			logrus.Errorf("[%s] unable to send data => %s", host.PublicIp, df.dest)
-			return err
-		}
-
-		logrus.Infof("[%s] data => %s", host.PublicIp, df.dest)
-
-		return nil
-	})
+	return run.GetModel().ForEachHost(df.hostSpec, 25, df.distribute)
+}
+
+func (df *distData) ExecuteOnHosts(run model.Run, hosts []*model.Host) error {
+	return run.GetModel().ForEachHostIn(df.hostSpec, hosts, 25, df.distribute)
+}
+
+func (df *distData) distribute(host *model.Host) error {
+	ssh := host.NewSshConfigFactory()
+	if err := libssh.SendData(ssh, df.data, df.dest); err != nil {
+		logrus.Errorf("[%s] unable to send data => %s", host.PublicIp, df.dest)
+		return err
+	}
+
+	if err := libssh.Chmod(ssh, df.dest, os.FileMode(0644)); err != nil {
+		logrus.Errorf("[%s] unable to send data => %s", host.PublicIp, df.dest)
+		return err
+	}
+
+	logrus.Infof("[%s] data => %s", host.PublicIp, df.dest)
+
+	return nil
 }
 
 type distData struct {
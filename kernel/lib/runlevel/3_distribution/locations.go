@@ -31,20 +31,26 @@ func Locations(hostSpec string, paths ...string) model.Stage {
 }
 
 func (self *locations) Execute(run model.Run) error {
-	return run.GetModel().ForEachHost(self.hostSpec, 25, func(host *model.Host) error {
-		ssh := host.NewSshConfigFactory()
-		var cmds []string
-		for _, path := range self.paths {
-			mkdir := fmt.Sprintf("mkdir -p %s", path)
-			cmds = append(cmds, mkdir)
-		}
-		if _, err := libssh.RemoteExecAll(ssh, cmds...); err == nil {
-			logrus.Infof("%s => %s", host.PublicIp, self.paths)
-			return nil
-		} else {
-			return fmt.Errorf("error creating paths [%s] on host [%s] (%w)", self.paths, host.PublicIp, err)
-		}
-	})
+	return run.GetModel().ForEachHost(self.hostSpec, 25, self.create)
+}
+
+func (self *locations) ExecuteOnHosts(run model.Run, hosts []*model.Host) error {
+	return run.GetModel().ForEachHostIn(self.hostSpec, hosts, 25, self.create)
+}
+
+func (self *locations) create(host *model.Host) error {
+	ssh := host.NewSshConfigFactory()
+	var cmds []string
+	for _, path := range self.paths {
+		mkdir := fmt.Sprintf("mkdir -p %s", path)
+		cmds = append(cmds, mkdir)
+	}
+	if _, err := libssh.RemoteExecAll(ssh, cmds...); err == nil {
+		logrus.Infof("%s => %s", host.PublicIp, self.paths)
+		return nil
+	} else {
+		return fmt.Errorf("error creating paths [%s] on host [%s] (%w)", self.paths, host.PublicIp, err)
+	}
 }
 
 type locations struct {
@@ -54,10 +54,19 @@ type stagedRsyncStage struct {
 // rsync from first host to next host in region
 
 func (rsync *stagedRsyncStage) Execute(run model.Run) error {
+	return rsync.execute(run, run.GetModel().SelectHosts(rsync.hostSelector))
+}
+
+// ExecuteOnHosts syncs the selected hosts which are among the given hosts
+func (rsync *stagedRsyncStage) ExecuteOnHosts(run model.Run, hosts []*model.Host) error {
+	return rsync.execute(run, run.GetModel().SelectHostsIn(rsync.hostSelector, hosts))
+}
+
+func (rsync *stagedRsyncStage) execute(run model.Run, selected []*model.Host) error {
 	group, ctx := errgroup.WithContext(context.Background())
 	hosts := map[string]*model.Host{}
 
-	for _, host := range run.GetModel().SelectHosts(rsync.hostSelector) {
+	for _, host := range selected {
 		hosts[host.GetPath()] = host
 	}
 
@@ -327,9 +336,15 @@ type rsyncHostStage struct {
 }
 
 func (self *rsyncHostStage) Execute(run model.Run) error {
-	return run.GetModel().ForEachHost(self.hostSpec, 1, func(host *model.Host) error {
-		cfg := NewConfig(host)
-		dest := cfg.sshConfigFactory.User() + "@" + cfg.sshConfigFactory.Hostname() + ":" + self.dest
-		return RunRsync(NewConfig(host), self.src, dest)
-	})
+	return run.GetModel().ForEachHost(self.hostSpec, 1, self.sync)
+}
+
+func (self *rsyncHostStage) ExecuteOnHosts(run model.Run, hosts []*model.Host) error {
+	return run.GetModel().ForEachHostIn(self.hostSpec, hosts, 1, self.sync)
+}
+
+func (self *rsyncHostStage) sync(host *model.Host) error {
+	cfg := NewConfig(host)
+	dest := cfg.sshConfigFactory.User() + "@" + cfg.sshConfigFactory.Hostname() + ":" + self.dest
+	return RunRsync(NewConfig(host), self.src, dest)
 }
@@ -0,0 +1,361 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+// Package stages registers builders for the library's stages and actions, so YAML models can
+// declare them by name. Importing it for its side effects makes them available.
+package stages
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/openziti/fablab/kernel/lib/actions/component"
+	"github.com/openziti/fablab/kernel/lib/actions/host"
+	"github.com/openziti/fablab/kernel/lib/actions/semaphore"
+	aws_ssh_key_0 "github.com/openziti/fablab/kernel/lib/runlevel/0_infrastructure/aws_ssh_key"
+	semaphore_0 "github.com/openziti/fablab/kernel/lib/runlevel/0_infrastructure/semaphore"
+	terraform_0 "github.com/openziti/fablab/kernel/lib/runlevel/0_infrastructure/terraform"
+	"github.com/openziti/fablab/kernel/lib/runlevel/1_configuration/config"
+	"github.com/openziti/fablab/kernel/lib/runlevel/2_kitting/devkit"
+	distribution "github.com/openziti/fablab/kernel/lib/runlevel/3_distribution"
+	"github.com/openziti/fablab/kernel/lib/runlevel/3_distribution/rsync"
+	operation "github.com/openziti/fablab/kernel/lib/runlevel/5_operation"
+	aws_ssh_key_6 "github.com/openziti/fablab/kernel/lib/runlevel/6_disposal/aws_ssh_key"
+	terraform_6 "github.com/openziti/fablab/kernel/lib/runlevel/6_disposal/terraform"
+	"github.com/openziti/fablab/kernel/model"
+)
+
+func init() {
+	// infrastructure
+	model.RegisterStageBuilder("terraform", terraformExpress)
+	model.RegisterStageBuilder("aws-ssh-key", noParams(aws_ssh_key_0.Express))
+	model.RegisterStageBuilder("ready", ready)
+	model.RegisterStageBuilder("restart", restart)
+
+	// configuration and kitting
+	model.RegisterStageBuilder("static-config", staticConfig)
+	model.RegisterStageBuilder("devkit", devKit)
+
+	// distribution
+	model.RegisterStageBuilder("rsync", rsyncStage)
+	model.RegisterStageBuilder("ssh-key", sshKey)
+	model.RegisterStageBuilder("locations", locations)
+
+	// components and hosts
+	model.RegisterStageBuilder("start", start)
+	model.RegisterStageBuilder("stop", stop)
+	model.RegisterStageBuilder("exec", exec)
+	model.RegisterStageBuilder("host-exec", hostExec)
+	model.RegisterStageBuilder("host-kill", hostKill)
+
+	// operation
+	model.RegisterStageBuilder("sleep", sleep)
+	model.RegisterStageBuilder("banner", banner)
+	model.RegisterStageBuilder("iperf", iperf)
+	model.RegisterStageBuilder("persist", noParams(operation.Persist))
+	model.RegisterStageBuilder("action", action)
+
+	// disposal
+	model.RegisterStageBuilder("terraform-dispose", noParams(terraform_6.Dispose))
+	model.RegisterStageBuilder("aws-ssh-key-dispose", noParams(aws_ssh_key_6.Dispose))
+}
+
+func noParams(f func() model.Stage) model.StageBuilder {
+	return func(params model.StageParams) (model.Stage, error) {
+		if err := params.Decode(&struct{}{}); err != nil {
+			return nil, err
+		}
+		return f(), nil
+	}
+}
+
+func required(name, value string) error {
+	if value == "" {
+		return fmt.Errorf("param '%s' is required", name)
+	}
+	return nil
+}
+
+func terraformExpress(params model.StageParams) (model.Stage, error) {
+	p := struct {
+		Retries      *uint8        `yaml:"retries"`
+		ReadyTimeout time.Duration `yaml:"readyTimeout"`
+	}{ReadyTimeout: 90 * time.Second}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	stage := terraform_0.Express().(*terraform_0.Terraform)
+	if p.Retries != nil {
+		stage.Retries = *p.Retries
+	}
+	stage.ReadyCheck.MaxWait = p.ReadyTimeout
+	return stage, nil
+}
+
+func ready(params model.StageParams) (model.Stage, error) {
+	p := struct {
+		MaxWait time.Duration `yaml:"maxWait"`
+	}{MaxWait: 90 * time.Second}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	return semaphore_0.Ready(p.MaxWait), nil
+}
+
+func restart(params model.StageParams) (model.Stage, error) {
+	p := struct {
+		PreDelay time.Duration `yaml:"preDelay"`
+	}{}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	return semaphore_0.Restart(p.PreDelay), nil
+}
+
+func staticConfig(params model.StageParams) (model.Stage, error) {
+	p := struct {
+		Configs []struct {
+			Src  string `yaml:"src"`
+			Name string `yaml:"name"`
+		} `yaml:"configs"`
+	}{}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	if len(p.Configs) == 0 {
+		return nil, fmt.Errorf("param 'configs' is required")
+	}
+	var configs []config.StaticConfig
+	for i, c := range p.Configs {
+		if c.Src == "" {
+			return nil, fmt.Errorf("configs[%d]: src is required", i)
+		}
+		if c.Name == "" {
+			c.Name = c.Src
+		}
+		configs = append(configs, config.StaticConfig{Src: c.Src, Name: c.Name})
+	}
+	return config.Static(configs), nil
+}
+
+func devKit(params model.StageParams) (model.Stage, error) {
+	p := struct {
+		Root     string   `yaml:"root"`
+		Binaries []string `yaml:"binaries"`
+	}{}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	if err := required("root", p.Root); err != nil {
+		return nil, err
+	}
+	return devkit.DevKit(p.Root, p.Binaries), nil
+}
+
+func rsyncStage(params model.StageParams) (model.Stage, error) {
+	p := struct {
+		Hosts string `yaml:"hosts"`
+		Src   string `yaml:"src"`
+		Dst   string `yaml:"dst"`
+	}{Hosts: "*"}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	return rsync.RsyncSelected(p.Hosts, p.Src, p.Dst), nil
+}
+
+func sshKey(params model.StageParams) (model.Stage, error) {
+	p := struct {
+		Hosts string `yaml:"hosts"`
+	}{Hosts: "*"}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	return distribution.DistributeSshKey(p.Hosts), nil
+}
+
+func locations(params model.StageParams) (model.Stage, error) {
+	p := struct {
+		Hosts string   `yaml:"hosts"`
+		Paths []string `yaml:"paths"`
+	}{Hosts: "*"}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	if len(p.Paths) == 0 {
+		return nil, fmt.Errorf("param 'paths' is required")
+	}
+	return distribution.Locations(p.Hosts, p.Paths...), nil
+}
+
+type componentParams struct {
+	Components   string        `yaml:"components"`
+	Concurrency  int           `yaml:"concurrency"`
+	Action       string        `yaml:"action"`
+	ReadyTimeout time.Duration `yaml:"readyTimeout"`
+}
+
+func decodeComponentParams(params model.StageParams) (*componentParams, error) {
+	p := &componentParams{Components: "*", Concurrency: 1, ReadyTimeout: model.DefaultReadyTimeout}
+	if err := params.Decode(p); err != nil {
+		return nil, err
+	}
+	if p.Concurrency < 1 {
+		return nil, fmt.Errorf("param 'concurrency' must be at least 1")
+	}
+	return p, nil
+}
+
+func start(params model.StageParams) (model.Stage, error) {
+	p, err := decodeComponentParams(params)
+	if err != nil {
+		return nil, err
+	}
+	if p.Action != "" {
+		return nil, fmt.Errorf("param 'action' is only supported by exec")
+	}
+	return component.StartInParallelWithReadyTimeout(p.Components, p.Concurrency, p.ReadyTimeout), nil
+}
+
+func stop(params model.StageParams) (model.Stage, error) {
+	p, err := decodeComponentParams(params)
+	if err != nil {
+		return nil, err
+	}
+	if p.Action != "" {
+		return nil, fmt.Errorf("param 'action' is only supported by exec")
+	}
+	if _, found := params["readyTimeout"]; found {
+		return nil, fmt.Errorf("param 'readyTimeout' is only supported by start")
+	}
+	return component.StopInParallel(p.Components, p.Concurrency), nil
+}
+
+func exec(params model.StageParams) (model.Stage, error) {
+	p, err := decodeComponentParams(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := required("action", p.Action); err != nil {
+		return nil, err
+	}
+	if _, found := params["readyTimeout"]; found {
+		return nil, fmt.Errorf("param 'readyTimeout' is only supported by start")
+	}
+	return component.ExecInParallel(p.Components, p.Concurrency, p.Action), nil
+}
+
+func hostExec(params model.StageParams) (model.Stage, error) {
+	p := struct {
+		Hosts       string   `yaml:"hosts"`
+		Concurrency int      `yaml:"concurrency"`
+		Cmds        []string `yaml:"cmds"`
+	}{Hosts: "*", Concurrency: 1}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	if len(p.Cmds) == 0 {
+		return nil, fmt.Errorf("param 'cmds' is required")
+	}
+	if p.Concurrency < 1 {
+		return nil, fmt.Errorf("param 'concurrency' must be at least 1")
+	}
+	return host.GroupExec(p.Hosts, p.Concurrency, p.Cmds...), nil
+}
+
+func hostKill(params model.StageParams) (model.Stage, error) {
+	p := struct {
+		Hosts string `yaml:"hosts"`
+		Match string `yaml:"match"`
+	}{Hosts: "*"}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	if err := required("match", p.Match); err != nil {
+		return nil, err
+	}
+	return host.GroupKill(p.Hosts, p.Match), nil
+}
+
+func sleep(params model.StageParams) (model.Stage, error) {
+	p := struct {
+		Duration time.Duration `yaml:"duration"`
+	}{}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	if p.Duration <= 0 {
+		return nil, fmt.Errorf("param 'duration' is required")
+	}
+	return semaphore.Sleep(p.Duration), nil
+}
+
+func banner(params model.StageParams) (model.Stage, error) {
+	p := struct {
+		Message string `yaml:"message"`
+	}{}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	if err := required("message", p.Message); err != nil {
+		return nil, err
+	}
+	return operation.Banner(p.Message), nil
+}
+
+// iperf runs an iperf3 test between two hosts. The client connects to endpoint, which defaults
+// to the public IP of the server host, as that's only known once the host has been expressed.
+func iperf(params model.StageParams) (model.Stage, error) {
+	p := struct {
+		Scenario    string `yaml:"scenario"`
+		Endpoint    string `yaml:"endpoint"`
+		ServerHosts string `yaml:"serverHosts"`
+		ClientHosts string `yaml:"clientHosts"`
+		Seconds     int    `yaml:"seconds"`
+	}{Scenario: "iperf", Seconds: 30}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	if err := required("serverHosts", p.ServerHosts); err != nil {
+		return nil, err
+	}
+	if err := required("clientHosts", p.ClientHosts); err != nil {
+		return nil, err
+	}
+	endpoint := func(m *model.Model) string {
+		if p.Endpoint != "" {
+			return p.Endpoint
+		}
+		if hosts := m.SelectHosts(p.ServerHosts); len(hosts) == 1 {
+			return hosts[0].PublicIp
+		}
+		return ""
+	}
+	return operation.Iperf(p.Scenario, endpoint, p.ServerHosts, p.ClientHosts, p.Seconds), nil
+}
+
+func action(params model.StageParams) (model.Stage, error) {
+	p := struct {
+		Name string `yaml:"name"`
+	}{}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	if err := required("name", p.Name); err != nil {
+		return nil, err
+	}
+	return model.RunAction(p.Name), nil
+}
@@ -0,0 +1,246 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// IncludeKey lists files whose contents are merged underneath the including document
+	IncludeKey = "include"
+	// BaseKey names a model file the document is an overlay of
+	BaseKey = "base"
+	// DeleteMarker removes a key when used as its value in an overlay, or a list entry when used
+	// as a key in an entry that carries the same id as an entry in the base list
+	DeleteMarker = "$delete"
+)
+
+type document = map[interface{}]interface{}
+
+// ComposeConfig loads the given files and deep merges them in order, so later files overlay
+// earlier ones. Each file's base and includes are resolved relative to that file and merged
+// underneath it, base first.
+func ComposeConfig(paths ...string) (map[interface{}]interface{}, error) {
+	doc, _, err := composeConfig(paths...)
+	return doc, err
+}
+
+func composeConfig(paths ...string) (document, sourceMap, error) {
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("no configuration files specified")
+	}
+
+	result, sources := document{}, sourceMap{}
+	for _, path := range paths {
+		doc, docSources, err := loadComposedFile(path, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		result = mergeDocuments(result, doc)
+		sources = sources.merge(docSources)
+	}
+	return result, sources, nil
+}
+
+// RenderConfig returns the merged YAML that LoadModel would build a model from.
+func RenderConfig(paths ...string) ([]byte, error) {
+	doc, err := ComposeConfig(paths...)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
+
+func loadComposedFile(path string, stack []string) (document, sourceMap, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve path '%s': %w", path, err)
+	}
+	for _, p := range stack {
+		if p == absPath {
+			return nil, nil, fmt.Errorf("include cycle detected: %v -> %s", stack, absPath)
+		}
+	}
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	doc, sources, err := composeBytes(data, filepath.Dir(absPath), append(stack, absPath))
+	if err != nil {
+		var validationErr ValidationError
+		if errors.As(err, &validationErr) {
+			// already carries the file it occurred in
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return doc, sources, nil
+}
+
+// composeBytes parses a document and merges its base and includes underneath it. Relative
+// paths are resolved against dir, and positions are reported against the last file in stack.
+func composeBytes(data []byte, dir string, stack []string) (document, sourceMap, error) {
+	var file string
+	if len(stack) > 0 {
+		file = displayFile(stack[len(stack)-1])
+	}
+
+	node, err := parseNode(data, file)
+	if err != nil {
+		return nil, nil, err
+	}
+	positions := recordPositions(node, data, file)
+
+	doc := document{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+
+	var layers []string
+	if base, found := doc[BaseKey]; found {
+		basePath, ok := base.(string)
+		if !ok {
+			return nil, nil, ValidationError{Path: BaseKey, Message: fmt.Sprintf("%s must be a file path", BaseKey), Position: positions[BaseKey]}
+		}
+		layers = append(layers, basePath)
+		delete(doc, BaseKey)
+	}
+
+	if include, found := doc[IncludeKey]; found {
+		switch v := include.(type) {
+		case string:
+			layers = append(layers, v)
+		case []interface{}:
+			for _, entry := range v {
+				includePath, ok := entry.(string)
+				if !ok {
+					return nil, nil, ValidationError{Path: IncludeKey, Message: fmt.Sprintf("%s entries must be file paths", IncludeKey), Position: positions[IncludeKey]}
+				}
+				layers = append(layers, includePath)
+			}
+		default:
+			return nil, nil, ValidationError{Path: IncludeKey, Message: fmt.Sprintf("%s must be a file path or a list of file paths", IncludeKey), Position: positions[IncludeKey]}
+		}
+		delete(doc, IncludeKey)
+	}
+
+	result, sources := document{}, sourceMap{}
+	for _, layer := range layers {
+		if !filepath.IsAbs(layer) {
+			layer = filepath.Join(dir, layer)
+		}
+		layerDoc, layerSources, err := loadComposedFile(layer, stack)
+		if err != nil {
+			return nil, nil, err
+		}
+		result = mergeDocuments(result, layerDoc)
+		sources = sources.merge(layerSources)
+	}
+
+	return mergeDocuments(result, doc), sources.merge(positions), nil
+}
+
+// mergeDocuments deep merges overlay onto base. Maps are merged key by key, lists whose entries
+// all carry an id are merged entry by entry, and any other value in overlay replaces the value
+// in base. The DeleteMarker removes keys and list entries.
+func mergeDocuments(base, overlay document) document {
+	result := document{}
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range overlay {
+		if v == DeleteMarker {
+			delete(result, k)
+			continue
+		}
+		result[k] = mergeValues(result[k], v)
+	}
+	return result
+}
+
+func mergeValues(base, overlay interface{}) interface{} {
+	switch overlayVal := overlay.(type) {
+	case document:
+		if baseVal, ok := base.(document); ok {
+			return mergeDocuments(baseVal, overlayVal)
+		}
+		return mergeDocuments(document{}, overlayVal)
+	case []interface{}:
+		if baseVal, ok := base.([]interface{}); ok && hasIds(baseVal) && hasIds(overlayVal) {
+			return mergeListsById(baseVal, overlayVal)
+		}
+		return removeDeletedEntries(overlayVal)
+	}
+	return overlay
+}
+
+func hasIds(list []interface{}) bool {
+	for _, entry := range list {
+		doc, ok := entry.(document)
+		if !ok {
+			return false
+		}
+		if _, ok := doc["id"].(string); !ok {
+			return false
+		}
+	}
+	return len(list) > 0
+}
+
+func mergeListsById(base, overlay []interface{}) []interface{} {
+	result := make([]interface{}, 0, len(base)+len(overlay))
+	index := map[string]int{}
+	for _, entry := range base {
+		doc := entry.(document)
+		index[doc["id"].(string)] = len(result)
+		result = append(result, doc)
+	}
+
+	deleted := map[int]bool{}
+	for _, entry := range overlay {
+		doc := entry.(document)
+		id := doc["id"].(string)
+		idx, found := index[id]
+		if _, remove := doc[DeleteMarker]; remove {
+			if found {
+				deleted[idx] = true
+			}
+			continue
+		}
+		if found {
+			result[idx] = mergeDocuments(result[idx].(document), doc)
+		} else {
+			index[id] = len(result)
+			result = append(result, doc)
+		}
+	}
+
+	var merged []interface{}
+	for idx, entry := range result {
+		if !deleted[idx] {
+			merged = append(merged, entry)
+		}
+	}
+	return merged
+}
+
+func removeDeletedEntries(list []interface{}) []interface{} {
+	var result []interface{}
+	for _, entry := range list {
+		if doc, ok := entry.(document); ok {
+			if _, remove := doc[DeleteMarker]; remove {
+				continue
+			}
+			result = append(result, mergeDocuments(document{}, doc))
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result
+}
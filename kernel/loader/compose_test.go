@@ -0,0 +1,148 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeYamlFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadModel_BaseAndIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeYamlFile(t, dir, "common/ctrl.yml", `
+regions:
+  us-east-1:
+    hosts:
+      ctrl:
+        instanceType: t3.medium
+        components:
+          - type: ziti-controller
+            id: ctrl
+`)
+	writeYamlFile(t, dir, "base.yml", `
+include:
+  - common/ctrl.yml
+model:
+  id: lab
+  variables:
+    environment: dev
+regions:
+  us-east-1:
+    hosts:
+      router-1:
+        instanceType: t3.small
+        components:
+          - type: ziti-router
+            id: router
+            config:
+              mode: edge
+      router-2:
+        components:
+          - type: ziti-router
+            id: router
+`)
+	perf := writeYamlFile(t, dir, "perf.yml", `
+base: base.yml
+model:
+  variables:
+    environment: perf
+regions:
+  us-east-1:
+    hosts:
+      ctrl:
+        instanceType: c5.xlarge
+      router-1:
+        components:
+          - id: router
+            config:
+              mode: fabric
+      router-2: $delete
+`)
+
+	m, err := LoadModel(perf)
+	if err != nil {
+		t.Fatalf("LoadModel failed: %v", err)
+	}
+
+	hosts := m.Regions["us-east-1"].Hosts
+	if len(hosts) != 2 {
+		t.Fatalf("expected router-2 to be deleted, got %d hosts", len(hosts))
+	}
+	if hosts["ctrl"].InstanceType != "c5.xlarge" {
+		t.Errorf("expected overlay instance type, got %s", hosts["ctrl"].InstanceType)
+	}
+	if hosts["router-1"].InstanceType != "t3.small" {
+		t.Errorf("expected base instance type to be kept, got %s", hosts["router-1"].InstanceType)
+	}
+	router := hosts["router-1"].Components["router"]
+	if router == nil || router.Type.Label() != "ziti-router" {
+		t.Fatalf("expected router component merged by id, got %v", hosts["router-1"].Components)
+	}
+	if router.Type.Dump().(map[string]string)["mode"] != "fabric" {
+		t.Errorf("expected overlay mode, got %v", router.Type.Dump())
+	}
+	if val, _ := m.GetVariable("environment"); val != "perf" {
+		t.Errorf("expected overlay variable, got %v", val)
+	}
+}
+
+func TestLoadModel_MultipleFiles(t *testing.T) {
+	dir := t.TempDir()
+	base := writeYamlFile(t, dir, "base.yml", `
+model:
+  id: lab
+regions:
+  us-east-1:
+    hosts:
+      ctrl:
+        components:
+          - type: ziti-controller
+`)
+	overlay := writeYamlFile(t, dir, "overlay.yml", `
+regions:
+  us-west-2:
+    hosts:
+      router:
+        components:
+          - type: ziti-router
+`)
+
+	m, err := LoadModel(base, overlay)
+	if err != nil {
+		t.Fatalf("LoadModel failed: %v", err)
+	}
+	if len(m.Regions) != 2 {
+		t.Errorf("expected 2 regions, got %d", len(m.Regions))
+	}
+
+	rendered, err := RenderConfig(base, overlay)
+	if err != nil {
+		t.Fatalf("RenderConfig failed: %v", err)
+	}
+	if !strings.Contains(string(rendered), "us-west-2") || !strings.Contains(string(rendered), "us-east-1") {
+		t.Errorf("expected rendered config to contain both regions, got:\n%s", rendered)
+	}
+}
+
+func TestLoadModel_IncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	a := writeYamlFile(t, dir, "a.yml", "include: b.yml\nmodel:\n  id: lab\n")
+	writeYamlFile(t, dir, "b.yml", "include: a.yml\n")
+
+	_, err := LoadModel(a)
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected include cycle error, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/openziti/fablab/kernel/model"
+)
+
+// validateDependencies reports dependsOn selectors which are malformed or match no other
+// component, and components depending on each other.
+func validateDependencies(m *model.Model, result *ValidationResult) {
+	components := m.SelectComponents("*")
+	reported := map[string]bool{}
+	valid := true
+	for _, c := range components {
+		for i, spec := range c.DependsOn {
+			path := fmt.Sprintf("%s.dependsOn[%d]", sourcePath(c), i)
+			if reported[path] {
+				continue
+			}
+			matcher, err := model.ParseSelector(spec)
+			if err != nil {
+				result.AddError(path, err.Error())
+				reported[path] = true
+				valid = false
+				continue
+			}
+			if !matchesOtherComponent(components, c, matcher) {
+				result.AddWarning(path, fmt.Sprintf("dependency '%s' matches no other component", spec))
+				reported[path] = true
+			}
+		}
+	}
+	if !valid {
+		return
+	}
+
+	if _, err := m.PlanComponents(components); err != nil {
+		var cycleErr *model.DependencyCycleError
+		if errors.As(err, &cycleErr) {
+			result.AddError(sourcePath(cycleErr.Components[0])+".dependsOn", err.Error())
+		} else {
+			result.AddError("", err.Error())
+		}
+	}
+}
+
+func matchesOtherComponent(components []*model.Component, c *model.Component, matcher model.EntityMatcher) bool {
+	for _, other := range components {
+		if other != c && matcher(other) {
+			return true
+		}
+	}
+	return false
+}
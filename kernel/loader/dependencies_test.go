@@ -0,0 +1,84 @@
+package loader
+
+import (
+	"testing"
+)
+
+const dependenciesYaml = `
+model:
+  id: test-model
+regions:
+  us-east:
+    hosts:
+      ctrl:
+        components:
+          - type: generic
+            id: ctrl
+            tags: [ctrl]
+      router:
+        components:
+          - type: generic
+            id: router
+            dependsOn: [component.ctrl]
+`
+
+func TestLoadModel_Dependencies(t *testing.T) {
+	m, err := LoadModelFromBytes([]byte(dependenciesYaml))
+	if err != nil {
+		t.Fatalf("LoadModelFromBytes failed: %v", err)
+	}
+
+	router := m.Regions["us-east"].Hosts["router"].Components["router"]
+	dependencies, err := router.GetDependencies()
+	if err != nil {
+		t.Fatalf("GetDependencies failed: %v", err)
+	}
+	if len(dependencies) != 1 || dependencies[0].Id != "ctrl" {
+		t.Errorf("expected router to depend on ctrl, got %v", dependencies)
+	}
+}
+
+func TestValidateConfig_Dependencies(t *testing.T) {
+	yaml := `
+model:
+  id: test-model
+regions:
+  us-east:
+    hosts:
+      ctrl:
+        components:
+          - type: generic
+            id: ctrl
+            dependsOn: ["#router"]
+      router:
+        components:
+          - type: generic
+            id: router
+            dependsOn: ["#ctrl", "#missing"]
+          - type: generic
+            id: broken
+            dependsOn: ["hots.x"]
+`
+	result, err := ValidateConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ValidateConfigBytes failed: %v", err)
+	}
+
+	if len(result.Errors) != 1 || result.Errors[0].Path != "regions.us-east.hosts.router.components[1].dependsOn[0]" {
+		t.Fatalf("expected invalid selector error, got %v", result.Errors)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Path != "regions.us-east.hosts.router.components[0].dependsOn[1]" {
+		t.Errorf("expected warning for dependency matching nothing, got %v", result.Warnings)
+	}
+
+	// once the selector is fixed the cycle is reported
+	yaml = yaml[:len(yaml)-len(`["hots.x"]`)-1] + `["#ctrl"]` + "\n"
+	result, err = ValidateConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ValidateConfigBytes failed: %v", err)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Path != "regions.us-east.hosts.ctrl.components[0].dependsOn" ||
+		result.Errors[0].Message != "dependency cycle: us-east.ctrl.ctrl -> us-east.router.router -> us-east.ctrl.ctrl" {
+		t.Errorf("expected dependency cycle error, got %v", result.Errors)
+	}
+}
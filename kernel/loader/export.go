@@ -0,0 +1,275 @@
+package loader
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/openziti/fablab/kernel/model"
+	"gopkg.in/yaml.v2"
+)
+
+// ExportModel converts a model, typically a bootstrapped Go-defined one, into the YAML
+// configuration LoadModel builds an equivalent model from. Factories have already been applied
+// to a bootstrapped model, so scaled entities are exported individually. Parts of the model YAML
+// can't express, such as stages, actions and component types which aren't registered, are
+// returned as warnings naming where they occur.
+func ExportModel(m *model.Model) (*FablabYaml, []ValidationError) {
+	e := &exporter{}
+
+	config := &FablabYaml{
+		Model: ModelYaml{
+			ScopeYaml: e.scope("model", &m.Scope),
+			Id:        m.Id,
+		},
+		Regions: map[string]RegionYaml{},
+	}
+	e.behavior(m)
+
+	for _, regionId := range sortedKeys(m.Regions) {
+		config.Regions[regionId] = e.region("regions."+regionId, m.Regions[regionId])
+	}
+
+	return config, e.warnings
+}
+
+// MarshalConfig encodes a configuration as YAML.
+func MarshalConfig(config *FablabYaml) ([]byte, error) {
+	return yaml.Marshal(config)
+}
+
+type exporter struct {
+	warnings []ValidationError
+}
+
+func (e *exporter) warn(path, format string, args ...interface{}) {
+	e.warnings = append(e.warnings, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// behavior reports the parts of the model which are Go code rather than structure.
+func (e *exporter) behavior(m *model.Model) {
+	stages := []struct {
+		name   string
+		stages model.Stages
+	}{
+		{"infrastructure", m.Infrastructure},
+		{"configuration", m.Configuration},
+		{"distribution", m.Distribution},
+		{"activation", m.Activation},
+		{"operation", m.Operation},
+		{"disposal", m.Disposal},
+	}
+	for _, s := range stages {
+		if len(s.stages) > 0 {
+			e.warn("model", "%d %s stage(s) can't be expressed in YAML", len(s.stages), s.name)
+		}
+	}
+
+	for _, factory := range append(append([]model.Factory{}, m.StructureFactories...), m.Factories...) {
+		e.warn("model", "factory %v can't be expressed in YAML, the entities it created are exported individually", reflect.TypeOf(factory))
+	}
+
+	if len(m.Actions) > 0 {
+		e.warn("model", "actions %v can't be expressed in YAML", sortedKeys(m.Actions))
+	}
+	if len(m.BootstrapExtensions) > 0 {
+		e.warn("model", "%d bootstrap extension(s) can't be expressed in YAML", len(m.BootstrapExtensions))
+	}
+	if len(m.MetricsHandlers) > 0 {
+		e.warn("model", "%d metrics handler(s) can't be expressed in YAML", len(m.MetricsHandlers))
+	}
+	if len(m.Resources) > 0 {
+		e.warn("model", "resources %v can't be expressed in YAML", sortedKeys(m.Resources))
+	}
+}
+
+func (e *exporter) region(path string, region *model.Region) RegionYaml {
+	result := RegionYaml{
+		ScopeYaml: e.scope(path, &region.Scope),
+		Site:      region.Site,
+		Hosts:     map[string]HostYaml{},
+	}
+	if region.Region != region.Id {
+		result.Region = region.Region
+	}
+
+	for _, hostId := range sortedKeys(region.Hosts) {
+		result.Hosts[hostId] = e.host(path+".hosts."+hostId, region.Hosts[hostId])
+	}
+	return result
+}
+
+func (e *exporter) host(path string, host *model.Host) HostYaml {
+	result := HostYaml{
+		ScopeYaml:    e.scope(path, &host.Scope),
+		HostSpecYaml: exportHostSpec(host),
+	}
+
+	for _, compId := range sortedKeys(host.Components) {
+		compPath := fmt.Sprintf("%s.components[%d]", path, len(result.Components))
+		if comp, ok := e.component(compPath, compId, host.Components[compId]); ok {
+			result.Components = append(result.Components, comp)
+		}
+	}
+	return result
+}
+
+func (e *exporter) component(path, id string, c *model.Component) (ComponentYaml, bool) {
+	if c.Type == nil {
+		e.warn(path, "component '%s' has no type and was skipped", id)
+		return ComponentYaml{}, false
+	}
+
+	typeName := c.Type.Label()
+	registered, err := model.GetComponentType(typeName)
+	if err != nil || reflect.TypeOf(registered) != reflect.TypeOf(c.Type) {
+		e.warn(path, "component '%s' has type %v, which isn't registered as '%s', and was skipped",
+			id, reflect.TypeOf(c.Type), typeName)
+		return ComponentYaml{}, false
+	}
+
+	result := ComponentYaml{
+		ScopeYaml:    e.scope(path, &c.Scope),
+		Type:         typeName,
+		Id:           id,
+		DependsOn:    c.DependsOn,
+		HealthChecks: c.HealthChecks,
+	}
+
+	config, err := componentConfig(c.Type, registered)
+	if err != nil {
+		e.warn(path, "config of component '%s' can't be expressed in YAML: %v", id, err)
+	}
+	result.Config = config
+	return result, true
+}
+
+// componentConfig returns the fields of the component type which differ from those of a new
+// instance of the registered type.
+func componentConfig(compType, defaults model.ComponentType) (config map[string]interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	current, err := toMap(compType)
+	if err != nil {
+		return nil, err
+	}
+	initial, err := toMap(defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range current {
+		if !reflect.DeepEqual(initial[k], v) {
+			if config == nil {
+				config = map[string]interface{}{}
+			}
+			config[k] = v
+		}
+	}
+	return config, nil
+}
+
+func toMap(v interface{}) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (e *exporter) scope(path string, scope *model.Scope) ScopeYaml {
+	result := ScopeYaml{
+		Tags: append([]string(nil), scope.Tags...),
+	}
+
+	for _, name := range sortedKeys(scope.Defaults) {
+		if v, ok := plainValue(scope.Defaults[name]); ok {
+			if result.Variables == nil {
+				result.Variables = map[string]interface{}{}
+			}
+			result.Variables[name] = v
+		} else {
+			e.warn(path+".variables."+name, "value of type %T can't be expressed in YAML", scope.Defaults[name])
+		}
+	}
+
+	for _, name := range sortedKeys(scope.Data) {
+		if name == scaleDataKey || name == sourcePathKey {
+			continue
+		}
+		if v, ok := plainValue(scope.Data[name]); ok {
+			if result.Data == nil {
+				result.Data = map[string]interface{}{}
+			}
+			result.Data[name] = v
+		} else {
+			e.warn(path+".data."+name, "value of type %T can't be expressed in YAML", scope.Data[name])
+		}
+	}
+
+	return result
+}
+
+// plainValue converts a variable or data value to strings, numbers, booleans, lists and maps,
+// returning false if it contains anything else.
+func plainValue(value interface{}) (interface{}, bool) {
+	if value == nil {
+		return nil, true
+	}
+	if expr, ok := value.(model.Expression); ok {
+		return string(expr), true
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Slice, reflect.Array:
+		result := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elem, ok := plainValue(v.Index(i).Interface())
+			if !ok {
+				return nil, false
+			}
+			result = append(result, elem)
+		}
+		return result, true
+	case reflect.Map:
+		result := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			elem, ok := plainValue(iter.Value().Interface())
+			if !ok {
+				return nil, false
+			}
+			result[fmt.Sprint(iter.Key().Interface())] = elem
+		}
+		return result, true
+	}
+	return nil, false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
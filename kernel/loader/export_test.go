@@ -0,0 +1,108 @@
+package loader
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openziti/fablab/kernel/model"
+)
+
+type unregisteredComponent struct {
+	model.GenericComponent
+}
+
+func (c *unregisteredComponent) Label() string {
+	return "unregistered"
+}
+
+func TestExportModel_RoundTrip(t *testing.T) {
+	source := &model.Model{
+		Id: "exported",
+		Scope: model.Scope{
+			Defaults: model.Variables{
+				"credentials": model.Variables{
+					"ssh": model.Variables{"username": "ubuntu"},
+				},
+			},
+		},
+		Regions: model.Regions{
+			"us-east-1": {
+				Scope: model.Scope{Tags: model.Tags{"primary"}},
+				Site:  "us-east-1a",
+				Hosts: model.Hosts{
+					"ctrl": {
+						InstanceType: "t3.medium",
+						Components: model.Components{
+							"ctrl": {Type: &model.ZitiControllerType{Version: "v1.2.3"}},
+						},
+					},
+					"router": {
+						Scope:        model.Scope{Tags: model.Tags{"edge"}, Data: model.Data{"weight": 3}},
+						InstanceType: "t3.small",
+						Components: model.Components{
+							"router": {
+								Scope: model.Scope{Defaults: model.Variables{"listenPort": 3022}},
+								Type:  &model.ZitiRouterType{Version: "v1.2.3", Mode: "fabric"},
+							},
+							"custom": {Type: &unregisteredComponent{}},
+						},
+					},
+				},
+			},
+		},
+		Configuration: model.Stages{model.StageActionF(func(model.Run) error { return nil })},
+	}
+	source.Init()
+
+	config, warnings := ExportModel(source)
+
+	var messages []string
+	for _, w := range warnings {
+		messages = append(messages, w.Error())
+	}
+	joined := strings.Join(messages, "\n")
+	if len(warnings) != 2 || !strings.Contains(joined, "configuration stage") || !strings.Contains(joined, "'custom'") {
+		t.Fatalf("expected warnings for the stage and unregistered component, got:\n%s", joined)
+	}
+
+	data, err := MarshalConfig(config)
+	if err != nil {
+		t.Fatalf("MarshalConfig failed: %v", err)
+	}
+	m, err := LoadModelFromBytes(data)
+	if err != nil {
+		t.Fatalf("LoadModelFromBytes failed: %v\n%s", err, data)
+	}
+
+	if m.Id != "exported" {
+		t.Errorf("expected model id 'exported', got %s", m.Id)
+	}
+	if val, _ := m.GetVariable("credentials.ssh.username"); val != "ubuntu" {
+		t.Errorf("expected nested model variable, got %v", val)
+	}
+
+	region := m.Regions["us-east-1"]
+	if region.Site != "us-east-1a" || !region.HasTag("primary") {
+		t.Errorf("unexpected region %+v", region)
+	}
+
+	routerHost := region.Hosts["router"]
+	if routerHost.InstanceType != "t3.small" || !routerHost.HasTag("edge") || routerHost.Data["weight"] != 3 {
+		t.Errorf("unexpected router host %+v", routerHost)
+	}
+	if _, found := routerHost.Components["custom"]; found {
+		t.Error("expected unregistered component to be skipped")
+	}
+
+	router, ok := routerHost.Components["router"].Type.(*model.ZitiRouterType)
+	if !ok || router.Version != "v1.2.3" || router.Mode != "fabric" {
+		t.Errorf("unexpected router type %+v", routerHost.Components["router"].Type)
+	}
+	if val, _ := routerHost.Components["router"].GetVariable("listenPort"); val != 3022 {
+		t.Errorf("expected component variable, got %v", val)
+	}
+
+	if version := region.Hosts["ctrl"].Components["ctrl"].Type.GetVersion(); version != "v1.2.3" {
+		t.Errorf("expected controller version v1.2.3, got %s", version)
+	}
+}
@@ -0,0 +1,67 @@
+package loader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadModel_HealthChecks(t *testing.T) {
+	yaml := `
+model:
+  id: test-model
+regions:
+  us-east:
+    hosts:
+      app:
+        components:
+          - type: generic
+            id: api
+            healthChecks:
+              - http:
+                  port: 8080
+                  path: /health
+                timeout: 2s
+                successThreshold: 3
+              - log:
+                  path: logs/api.log
+                  match: listening on
+`
+	m, err := LoadModelFromBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("LoadModelFromBytes failed: %v", err)
+	}
+
+	checks := m.Regions["us-east"].Hosts["app"].Components["api"].GetHealthChecks()
+	if len(checks) != 2 || checks[0].HTTP == nil || checks[0].HTTP.Port != 8080 ||
+		checks[0].Timeout != 2*time.Second || checks[0].SuccessThreshold != 3 || checks[1].Log == nil {
+		t.Errorf("unexpected health checks %+v", checks)
+	}
+}
+
+func TestValidateConfig_HealthChecks(t *testing.T) {
+	yaml := `
+model:
+  id: test-model
+regions:
+  us-east:
+    hosts:
+      app:
+        components:
+          - type: generic
+            id: api
+            healthChecks:
+              - tcp:
+                  port: 8080
+              - tcp:
+                  port: 8080
+                exec:
+                  cmd: "true"
+`
+	result, err := ValidateConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ValidateConfigBytes failed: %v", err)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Path != "regions.us-east.hosts.app.components[0].healthChecks[1]" {
+		t.Errorf("expected error for health check with two probes, got %v", result.Errors)
+	}
+}
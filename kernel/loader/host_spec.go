@@ -0,0 +1,159 @@
+package loader
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/openziti/fablab/kernel/model"
+)
+
+const (
+	InstanceResourceTypeOnDemand = "ondemand"
+	InstanceResourceTypeSpot     = "spot"
+)
+
+var (
+	validInstanceResourceTypes = []string{InstanceResourceTypeOnDemand, InstanceResourceTypeSpot}
+	validSpotTypes             = []string{"one-time", "persistent"}
+	validVolumeTypes           = []string{"gp2", "gp3", "io1", "io2", "sc1", "st1", "standard"}
+	provisionedIopsVolumeTypes = []string{"gp3", "io1", "io2"}
+	spotPricePattern           = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+)
+
+// maxVolumeSizeGB is the largest EBS volume size
+const maxVolumeSizeGB = 16384
+
+// HostSpecYaml holds the provisioning settings of a host, which feed the terraform templates.
+// Regions declare defaults for their hosts using the same settings.
+type HostSpecYaml struct {
+	InstanceType         string      `yaml:"instanceType,omitempty"`
+	InstanceResourceType string      `yaml:"instanceResourceType,omitempty" jsonschema:"enum=ondemand,enum=spot" jsonschema_description:"Selects the terraform instance template, defaults to ondemand"`
+	SpotPrice            string      `yaml:"spotPrice,omitempty" jsonschema_description:"Maximum hourly price in USD for spot instances, e.g. 0.0416"`
+	SpotType             string      `yaml:"spotType,omitempty" jsonschema:"enum=one-time,enum=persistent"`
+	Volume               *VolumeYaml `yaml:"volume,omitempty" jsonschema_description:"Root volume of the host"`
+}
+
+// VolumeYaml maps onto model.EC2Volume.
+type VolumeYaml struct {
+	Type   string `yaml:"type,omitempty" jsonschema:"enum=gp2,enum=gp3,enum=io1,enum=io2,enum=sc1,enum=st1,enum=standard"`
+	SizeGB uint32 `yaml:"sizeGB,omitempty"`
+	IOPS   uint32 `yaml:"iops,omitempty" jsonschema_description:"Provisioned IOPS, for gp3, io1 and io2 volumes"`
+}
+
+// withDefaults returns the spec with unset settings taken from defaults.
+func (spec HostSpecYaml) withDefaults(defaults *HostSpecYaml) HostSpecYaml {
+	if defaults == nil {
+		return spec
+	}
+	result := spec
+	if result.InstanceType == "" {
+		result.InstanceType = defaults.InstanceType
+	}
+	if result.InstanceResourceType == "" {
+		result.InstanceResourceType = defaults.InstanceResourceType
+	}
+	if result.SpotPrice == "" {
+		result.SpotPrice = defaults.SpotPrice
+	}
+	if result.SpotType == "" {
+		result.SpotType = defaults.SpotType
+	}
+	if defaults.Volume != nil {
+		volume := *defaults.Volume
+		if spec.Volume != nil {
+			if spec.Volume.Type != "" {
+				volume.Type = spec.Volume.Type
+			}
+			if spec.Volume.SizeGB != 0 {
+				volume.SizeGB = spec.Volume.SizeGB
+			}
+			if spec.Volume.IOPS != 0 {
+				volume.IOPS = spec.Volume.IOPS
+			}
+		}
+		result.Volume = &volume
+	}
+	return result
+}
+
+// apply sets the provisioning settings of the host.
+func (spec HostSpecYaml) apply(host *model.Host) {
+	host.InstanceType = spec.InstanceType
+	host.InstanceResourceType = spec.InstanceResourceType
+	host.SpotPrice = spec.SpotPrice
+	host.SpotType = spec.SpotType
+	if spec.Volume != nil {
+		host.EC2.Volume = model.EC2Volume{
+			Type:   spec.Volume.Type,
+			SizeGB: spec.Volume.SizeGB,
+			IOPS:   spec.Volume.IOPS,
+		}
+	}
+}
+
+// exportHostSpec returns the provisioning settings of the host.
+func exportHostSpec(host *model.Host) HostSpecYaml {
+	spec := HostSpecYaml{
+		InstanceType:         host.InstanceType,
+		InstanceResourceType: host.InstanceResourceType,
+		SpotPrice:            host.SpotPrice,
+		SpotType:             host.SpotType,
+	}
+	if host.EC2.Volume != (model.EC2Volume{}) {
+		spec.Volume = &VolumeYaml{
+			Type:   host.EC2.Volume.Type,
+			SizeGB: host.EC2.Volume.SizeGB,
+			IOPS:   host.EC2.Volume.IOPS,
+		}
+	}
+	return spec
+}
+
+// validateHostSpec checks the format of the settings declared at basePath.
+func validateHostSpec(spec *HostSpecYaml, basePath string, result *ValidationResult) {
+	if spec.InstanceResourceType != "" && !contains(validInstanceResourceTypes, spec.InstanceResourceType) {
+		result.AddError(basePath+".instanceResourceType", fmt.Sprintf("invalid instance resource type '%s'. Valid types: %s",
+			spec.InstanceResourceType, strings.Join(validInstanceResourceTypes, ", ")))
+	}
+	if spec.SpotPrice != "" && !spotPricePattern.MatchString(spec.SpotPrice) {
+		result.AddError(basePath+".spotPrice", fmt.Sprintf("invalid spot price '%s': must be a price in USD, e.g. 0.0416", spec.SpotPrice))
+	}
+	if spec.SpotType != "" && !contains(validSpotTypes, spec.SpotType) {
+		result.AddError(basePath+".spotType", fmt.Sprintf("invalid spot type '%s'. Valid types: %s",
+			spec.SpotType, strings.Join(validSpotTypes, ", ")))
+	}
+
+	if volume := spec.Volume; volume != nil {
+		path := basePath + ".volume"
+		if volume.Type != "" && !contains(validVolumeTypes, volume.Type) {
+			result.AddError(path+".type", fmt.Sprintf("invalid volume type '%s'. Valid types: %s",
+				volume.Type, strings.Join(validVolumeTypes, ", ")))
+		}
+		if volume.SizeGB > maxVolumeSizeGB {
+			result.AddError(path+".sizeGB", fmt.Sprintf("volume size %dGB exceeds the maximum of %dGB", volume.SizeGB, maxVolumeSizeGB))
+		}
+	}
+}
+
+// validateEffectiveHostSpec checks the settings a host ends up with once region defaults apply.
+// Spot settings inherited from the region are quietly ignored by on-demand hosts, only those
+// declared on the host itself are warned about.
+func validateEffectiveHostSpec(declared, spec *HostSpecYaml, basePath string, result *ValidationResult) {
+	if spec.InstanceResourceType != InstanceResourceTypeSpot && (declared.SpotPrice != "" || declared.SpotType != "") {
+		result.AddWarning(basePath, "spotPrice and spotType are ignored unless instanceResourceType is 'spot'")
+	}
+	if volume := spec.Volume; volume != nil && volume.IOPS != 0 && !contains(provisionedIopsVolumeTypes, volume.Type) {
+		result.AddError(basePath+".volume.iops", fmt.Sprintf("iops can only be set for volume types %s",
+			strings.Join(provisionedIopsVolumeTypes, ", ")))
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
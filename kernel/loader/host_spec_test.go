@@ -0,0 +1,163 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openziti/fablab/kernel/lib"
+	"github.com/openziti/fablab/resources"
+)
+
+const hostSpecYaml = `
+model:
+  id: test-model
+  variables:
+    environment: test
+    credentials:
+      aws:
+        access_key: access
+        secret_key: secret
+        ssh_key_name: key
+      ssh:
+        key_path: /tmp/key
+regions:
+  us-east:
+    region: us-east-1
+    site: us-east-1a
+    hostDefaults:
+      instanceType: t3.micro
+      instanceResourceType: spot
+      spotPrice: "0.02"
+      spotType: one-time
+      volume:
+        type: gp3
+        sizeGB: 20
+    hosts:
+      ctrl:
+        instanceType: c5.large
+        instanceResourceType: ondemand
+        volume:
+          sizeGB: 100
+          iops: 4000
+        components:
+          - type: ziti-controller
+      router:
+        components:
+          - type: ziti-router
+`
+
+func TestLoadModel_HostSpec(t *testing.T) {
+	m, err := LoadModelFromBytes([]byte(hostSpecYaml))
+	if err != nil {
+		t.Fatalf("LoadModelFromBytes failed: %v", err)
+	}
+
+	region := m.Regions["us-east"]
+	if region.Region != "us-east-1" || region.Site != "us-east-1a" {
+		t.Errorf("unexpected region %s/%s", region.Region, region.Site)
+	}
+
+	ctrl := region.Hosts["ctrl"]
+	if ctrl.InstanceType != "c5.large" || ctrl.InstanceResourceType != "ondemand" {
+		t.Errorf("expected host settings to override region defaults, got %s/%s", ctrl.InstanceType, ctrl.InstanceResourceType)
+	}
+	if ctrl.EC2.Volume.Type != "gp3" || ctrl.EC2.Volume.SizeGB != 100 || ctrl.EC2.Volume.IOPS != 4000 {
+		t.Errorf("expected volume merged with region defaults, got %+v", ctrl.EC2.Volume)
+	}
+
+	router := region.Hosts["router"]
+	if router.InstanceType != "t3.micro" || router.InstanceResourceType != "spot" ||
+		router.SpotPrice != "0.02" || router.SpotType != "one-time" || router.EC2.Volume.SizeGB != 20 {
+		t.Errorf("expected host to inherit region defaults, got %+v", router)
+	}
+
+	outDir := t.TempDir()
+	outPath := filepath.Join(outDir, "main.tf")
+	err = lib.RenderTemplateFS(resources.DefaultTerraformResources(), "main.tf", outPath, m, struct {
+		Model         interface{}
+		TerraformLib  string
+		PathSeparator string
+	}{Model: m, TerraformLib: outDir, PathSeparator: string(os.PathSeparator)})
+	if err != nil {
+		t.Fatalf("failed to render terraform: %v", err)
+	}
+	data, _ := os.ReadFile(outPath)
+	for _, expected := range []string{
+		outDir + "/spot_instance",
+		`spot_price        = "0.02"`,
+		`volume_type       = "gp3"`,
+		`volume_size       = 100`,
+		`volume_iops       = 4000`,
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expected generated terraform to contain %s", expected)
+		}
+	}
+}
+
+func TestValidateConfig_HostSpec_InheritedSpotSettings(t *testing.T) {
+	result, err := ValidateConfigBytes([]byte(hostSpecYaml))
+	if err != nil {
+		t.Fatalf("ValidateConfigBytes failed: %v", err)
+	}
+	if !result.IsValid() || len(result.Warnings) != 0 {
+		t.Errorf("expected on-demand host to ignore inherited spot settings, got %v %v", result.Errors, result.Warnings)
+	}
+}
+
+func TestValidateConfig_HostSpec(t *testing.T) {
+	yaml := `
+model:
+  id: test-model
+regions:
+  us-east:
+    hostDefaults:
+      spotPrice: cheap
+    hosts:
+      ctrl:
+        instanceResourceType: reserved
+        spotType: forever
+        volume:
+          type: magnetic
+          sizeGB: 20000
+      router:
+        instanceResourceType: ondemand
+        spotType: one-time
+        volume:
+          type: gp2
+          iops: 3000
+`
+	result, err := ValidateConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ValidateConfigBytes failed: %v", err)
+	}
+
+	errors := map[string]bool{}
+	for _, e := range result.Errors {
+		errors[e.Path] = true
+	}
+	for _, path := range []string{
+		"regions.us-east.hostDefaults.spotPrice",
+		"regions.us-east.hosts.ctrl.instanceResourceType",
+		"regions.us-east.hosts.ctrl.spotType",
+		"regions.us-east.hosts.ctrl.volume.type",
+		"regions.us-east.hosts.ctrl.volume.sizeGB",
+		"regions.us-east.hosts.router.volume.iops",
+	} {
+		if !errors[path] {
+			t.Errorf("expected error at %s, got %v", path, result.Errors)
+		}
+	}
+
+	warned := false
+	for _, w := range result.Warnings {
+		if w.Path == "regions.us-east.hosts.router" && strings.Contains(w.Message, "spotType") {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Errorf("expected warning for spot settings on an on-demand host, got %v", result.Warnings)
+	}
+}
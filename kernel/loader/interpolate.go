@@ -0,0 +1,293 @@
+package loader
+
+import (
+	"fmt"
+
+	"github.com/openziti/fablab/kernel/model"
+)
+
+// interpolateConfig resolves the `${...}` expressions in the configuration, see model.Expression.
+//
+// Expressions in variables are bound late: they're kept as model.Expression and resolved each
+// time the variable is read, so they may reference values only known after express, such as
+// `${hosts.ctrl.privateIp}`. Component config and stage parameters referencing such values are
+// kept as model.Expression as well, and resolved when the component is built or the stage is
+// run. All other expressions are resolved now, against a model built from the configuration as
+// written, and are reported as errors if they can't be. Stage parameters are resolved against the
+// model.
+func interpolateConfig(config *FablabYaml, result *ValidationResult) {
+	m := buildReferenceModel(config)
+	entities := map[string]model.Entity{}
+	m.Accept(func(entity model.Entity) {
+		entities[sourcePath(entity)] = entity
+	})
+
+	i := &interpolator{result: result}
+
+	i.scope(m, "model", &config.Model.ScopeYaml)
+	for regionId := range config.Regions {
+		region := config.Regions[regionId]
+		regionPath := "regions." + regionId
+		regionEntity := entities[regionPath]
+
+		i.scope(regionEntity, regionPath, &region.ScopeYaml)
+		region.Region = i.string(regionEntity, regionPath+".region", region.Region)
+		region.Site = i.string(regionEntity, regionPath+".site", region.Site)
+		i.hostSpec(regionEntity, regionPath+".hostDefaults", &region.HostDefaults)
+
+		for hostId := range region.Hosts {
+			host := region.Hosts[hostId]
+			hostPath := regionPath + ".hosts." + hostId
+			hostEntity := entities[hostPath]
+
+			i.scope(hostEntity, hostPath, &host.ScopeYaml)
+			i.hostSpec(hostEntity, hostPath, &host.HostSpecYaml)
+			host.PublicIp = i.string(hostEntity, hostPath+".publicIp", host.PublicIp)
+
+			for idx := range host.Components {
+				comp := &host.Components[idx]
+				compPath := fmt.Sprintf("%s.components[%d]", hostPath, idx)
+				compEntity := entities[compPath]
+
+				i.scope(compEntity, compPath, &comp.ScopeYaml)
+				for k, v := range comp.Config {
+					comp.Config[k] = i.typedValue(compEntity, compPath+".config."+k, v)
+				}
+			}
+			region.Hosts[hostId] = host
+		}
+		config.Regions[regionId] = region
+	}
+
+	steps := func(basePath string, steps []StepYaml) {
+		for idx, step := range steps {
+			for k, v := range step.Params {
+				step.Params[k] = i.typedValue(m, fmt.Sprintf("%s[%d].params.%s", basePath, idx, k), v)
+			}
+		}
+	}
+	for _, phase := range config.Stages.phases() {
+		steps("stages."+phase.name, phase.steps)
+	}
+	for name := range config.Actions {
+		steps("actions."+name, config.Actions[name])
+	}
+}
+
+type interpolator struct {
+	result *ValidationResult
+}
+
+func (i *interpolator) scope(entity model.Entity, path string, scope *ScopeYaml) {
+	for idx, tag := range scope.Tags {
+		scope.Tags[idx] = i.string(entity, fmt.Sprintf("%s.tags[%d]", path, idx), tag)
+	}
+	for k, v := range scope.Data {
+		scope.Data[k] = i.value(entity, path+".data."+k, v)
+	}
+	for k, v := range scope.Variables {
+		scope.Variables[k] = toExpressions(v)
+	}
+}
+
+func (i *interpolator) hostSpec(entity model.Entity, path string, spec *HostSpecYaml) {
+	spec.InstanceType = i.string(entity, path+".instanceType", spec.InstanceType)
+	spec.InstanceResourceType = i.string(entity, path+".instanceResourceType", spec.InstanceResourceType)
+	spec.SpotPrice = i.string(entity, path+".spotPrice", spec.SpotPrice)
+	spec.SpotType = i.string(entity, path+".spotType", spec.SpotType)
+	if spec.Volume != nil {
+		spec.Volume.Type = i.string(entity, path+".volume.type", spec.Volume.Type)
+	}
+}
+
+func (i *interpolator) string(entity model.Entity, path, value string) string {
+	if !model.IsExpression(value) {
+		return value
+	}
+	result, err := model.InterpolateString(entity, value)
+	if err != nil {
+		i.addError(path, err)
+		return value
+	}
+	return result
+}
+
+// addError reports an expression which can't be resolved. Values only known after express can't
+// be used where they're needed before.
+func (i *interpolator) addError(path string, err error) {
+	if model.IsLateReference(err) {
+		i.result.AddError(path, err.Error()+"; only variables, component config and stage params can reference it")
+		return
+	}
+	i.result.AddError(path, err.Error())
+}
+
+// value interpolates a data value. A string consisting of a single reference is replaced by the
+// referenced value, which stays a string if it resolved to one.
+func (i *interpolator) value(entity model.Entity, path string, value interface{}) interface{} {
+	return i.resolve(entity, path, value, false)
+}
+
+// typedValue interpolates a config value or stage parameter. A string consisting of a single
+// reference which resolves to a string is kept as a model.ResolvedString, which is typed by the
+// field it's decoded into, so `port: ${env.PORT}` decodes into a numeric field while
+// `version: ${env.VERSION}` keeps a version of 1.10 as written. Strings referencing values only
+// known after express are kept as model.Expression.
+func (i *interpolator) typedValue(entity model.Entity, path string, value interface{}) interface{} {
+	return i.resolve(entity, path, value, true)
+}
+
+func (i *interpolator) resolve(entity model.Entity, path string, value interface{}, typed bool) interface{} {
+	switch v := value.(type) {
+	case string:
+		if !model.IsExpression(v) {
+			return v
+		}
+		result, err := model.Interpolate(entity, v)
+		if err != nil {
+			if typed && model.IsLateReference(err) {
+				return model.Expression(v)
+			}
+			i.addError(path, err)
+			return v
+		}
+		if s, ok := result.(string); ok && typed {
+			return model.ResolvedString(s)
+		}
+		return result
+	case map[interface{}]interface{}:
+		for k, child := range v {
+			v[k] = i.resolve(entity, fmt.Sprintf("%s.%v", path, k), child, typed)
+		}
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = i.resolve(entity, path+"."+k, child, typed)
+		}
+	case []interface{}:
+		for idx, child := range v {
+			v[idx] = i.resolve(entity, fmt.Sprintf("%s[%d]", path, idx), child, typed)
+		}
+	}
+	return value
+}
+
+// toExpressions converts strings containing references in a variable value to model.Expression.
+func toExpressions(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if model.IsExpression(v) {
+			return model.Expression(v)
+		}
+	case map[interface{}]interface{}:
+		for k, child := range v {
+			v[k] = toExpressions(child)
+		}
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = toExpressions(child)
+		}
+	case []interface{}:
+		for idx, child := range v {
+			v[idx] = toExpressions(child)
+		}
+	}
+	return value
+}
+
+// withoutExpressions returns a copy of a config value without the entries holding a
+// model.Expression, so that the rest of it can be decoded before the expressions are resolved.
+// Lists holding an expression are left out as a whole. The second result is false if value itself
+// is left out.
+func withoutExpressions(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case model.Expression:
+		return nil, false
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			if kept, ok := withoutExpressions(child); ok {
+				result[k] = kept
+			}
+		}
+		return result, true
+	case map[interface{}]interface{}:
+		result := make(map[interface{}]interface{}, len(v))
+		for k, child := range v {
+			if kept, ok := withoutExpressions(child); ok {
+				result[k] = kept
+			}
+		}
+		return result, true
+	case []interface{}:
+		if model.HasExpressions(v) {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// lateConfigBinder returns a model.Component ConfigBinder which decodes config into the type of
+// the component, once the values its expressions reference are known.
+func lateConfigBinder(config map[string]interface{}) func(c *model.Component) error {
+	return func(c *model.Component) error {
+		resolved, err := model.ResolveTypedExpressions(c, config)
+		if err != nil {
+			return err
+		}
+		return decodeComponentConfig(c.Type, resolved.(map[string]interface{}))
+	}
+}
+
+// buildReferenceModel builds the model expressions are resolved against. It's built from the
+// configuration as written, without scaling, and tolerates component types and config which
+// don't decode, as those may depend on the expressions being resolved.
+func buildReferenceModel(config *FablabYaml) *model.Model {
+	m := &model.Model{
+		Id:      config.Model.Id,
+		Scope:   buildScope(&config.Model.ScopeYaml),
+		Regions: make(model.Regions),
+	}
+	setSourcePath(&m.Scope, "model")
+
+	for regionId, regionYaml := range config.Regions {
+		regionPath := "regions." + regionId
+		region := &model.Region{
+			Scope: buildScope(&regionYaml.ScopeYaml),
+			Id:    regionId,
+			Site:  regionYaml.Site,
+			Hosts: make(model.Hosts),
+		}
+		setSourcePath(&region.Scope, regionPath)
+		m.Regions[regionId] = region
+
+		for hostId, hostYaml := range regionYaml.Hosts {
+			hostPath := regionPath + ".hosts." + hostId
+			host := &model.Host{
+				Scope:      buildScope(&hostYaml.ScopeYaml),
+				Id:         hostId,
+				Region:     region,
+				PublicIp:   hostYaml.PublicIp,
+				Components: make(model.Components),
+			}
+			hostYaml.HostSpecYaml.withDefaults(&regionYaml.HostDefaults).apply(host)
+			setSourcePath(&host.Scope, hostPath)
+			region.Hosts[hostId] = host
+
+			for idx, compYaml := range hostYaml.Components {
+				comp := &model.Component{
+					Scope: buildScope(&compYaml.ScopeYaml),
+					Id:    componentId(idx, &compYaml),
+				}
+				if compType, err := model.GetComponentType(compYaml.Type); err == nil {
+					_ = decodeComponentConfig(compType, compYaml.Config)
+					comp.Type = compType
+				}
+				setSourcePath(&comp.Scope, fmt.Sprintf("%s.components[%d]", hostPath, idx))
+				host.Components[comp.Id] = comp
+			}
+		}
+	}
+
+	m.Init()
+	return m
+}
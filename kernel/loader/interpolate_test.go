@@ -0,0 +1,243 @@
+package loader
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openziti/fablab/kernel/model"
+)
+
+func TestLoadModel_Interpolation(t *testing.T) {
+	t.Setenv("FABLAB_TEST_INSTANCE_TYPE", "c5.large")
+	t.Setenv("FABLAB_TEST_RESTART_SEC", "15")
+
+	yaml := `
+model:
+  id: test-model
+  variables:
+    version: 1.2.3
+regions:
+  us-east-1:
+    hosts:
+      ctrl:
+        instanceType: ${env.FABLAB_TEST_INSTANCE_TYPE}
+        components:
+          - type: ziti-controller
+            id: ctrl
+            config:
+              version: ${model.variables.version}
+      router:
+        instanceType: ${hosts.ctrl.instanceType}
+        variables:
+          ctrlAddress: tls:${hosts.ctrl.privateIp}:6262
+        data:
+          size: ${env.FABLAB_TEST_MISSING:-small}
+        components:
+          - type: systemd-service
+            id: router
+            config:
+              binary: ziti-router
+              restartSec: ${env.FABLAB_TEST_RESTART_SEC}
+              version: v$${literal}
+`
+	m, err := LoadModelFromBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("LoadModelFromBytes failed: %v", err)
+	}
+
+	ctrlHost := m.Regions["us-east-1"].Hosts["ctrl"]
+	routerHost := m.Regions["us-east-1"].Hosts["router"]
+	if ctrlHost.InstanceType != "c5.large" || routerHost.InstanceType != "c5.large" {
+		t.Errorf("expected interpolated instance types, got %s and %s", ctrlHost.InstanceType, routerHost.InstanceType)
+	}
+	if routerHost.Data["size"] != "small" {
+		t.Errorf("expected default value for missing env variable, got %v", routerHost.Data["size"])
+	}
+
+	if version := ctrlHost.Components["ctrl"].Type.GetVersion(); version != "1.2.3" {
+		t.Errorf("expected controller version 1.2.3, got %s", version)
+	}
+	router := routerHost.Components["router"].Type.(*model.SystemdServiceType)
+	if router.RestartSec != 15 || router.Version != "v${literal}" {
+		t.Errorf("unexpected router config %+v", router)
+	}
+
+	// the controller address is only known once the host has been expressed
+	if _, found := routerHost.GetVariable("ctrlAddress"); found {
+		t.Error("expected unresolved variable before the private ip is known")
+	}
+	ctrlHost.PrivateIp = "10.0.0.5"
+	if val, _ := routerHost.GetVariable("ctrlAddress"); val != "tls:10.0.0.5:6262" {
+		t.Errorf("expected late bound controller address, got %v", val)
+	}
+}
+
+func TestLoadModel_InterpolationKeepsStrings(t *testing.T) {
+	t.Setenv("FABLAB_TEST_VERSION", "1.10")
+	t.Setenv("FABLAB_TEST_USER", "yes")
+	t.Setenv("FABLAB_TEST_MODE", "0755")
+	t.Setenv("FABLAB_TEST_RESTART_SEC", "20")
+
+	yaml := `
+model:
+  id: test-model
+regions:
+  us-east-1:
+    hosts:
+      host:
+        data:
+          answer: ${env.FABLAB_TEST_USER}
+        components:
+          - type: systemd-service
+            id: svc
+            config:
+              binary: svc
+              version: ${env.FABLAB_TEST_VERSION}
+              user: ${env.FABLAB_TEST_USER}
+              args: ["${env.FABLAB_TEST_VERSION}"]
+              env:
+                MODE: ${env.FABLAB_TEST_MODE}
+              restartSec: ${env.FABLAB_TEST_RESTART_SEC}
+`
+	m, err := LoadModelFromBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("LoadModelFromBytes failed: %v", err)
+	}
+
+	host := m.Regions["us-east-1"].Hosts["host"]
+	if host.Data["answer"] != "yes" {
+		t.Errorf("expected data to keep the string yes, got %#v", host.Data["answer"])
+	}
+	svc := host.Components["svc"].Type.(*model.SystemdServiceType)
+	if svc.Version != "1.10" || svc.User != "yes" || svc.Args[0] != "1.10" || svc.Env["MODE"] != "0755" {
+		t.Errorf("expected string fields to keep the resolved strings, got %+v", svc)
+	}
+	if svc.RestartSec != 20 {
+		t.Errorf("expected the numeric field to decode the resolved value as YAML, got %d", svc.RestartSec)
+	}
+}
+
+func TestValidateConfig_UnresolvedReference(t *testing.T) {
+	yaml := `model:
+  id: test-model
+regions:
+  us-east-1:
+    hosts:
+      ctrl:
+        instanceType: ${hosts.missing.instanceType}
+        components:
+          - type: ziti-controller
+`
+	result, err := ValidateConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ValidateConfigBytes failed: %v", err)
+	}
+	if len(result.Errors) != 1 {
+		t.Fatalf("expected a single error, got %v", result.Errors)
+	}
+	e := result.Errors[0]
+	if e.Path != "regions.us-east-1.hosts.ctrl.instanceType" || !strings.Contains(e.Message, "no host with id 'missing'") {
+		t.Errorf("unexpected error %v", e)
+	}
+	if e.Position.Line != 7 {
+		t.Errorf("expected error at line 7, got %+v", e.Position)
+	}
+
+	if _, err := LoadModelFromBytes([]byte(yaml)); err == nil {
+		t.Error("expected LoadModelFromBytes to fail on the unresolved reference")
+	}
+}
+
+func TestLoadModel_LateBoundConfig(t *testing.T) {
+	yaml := `
+model:
+  id: test-model
+regions:
+  us-east-1:
+    hosts:
+      ctrl:
+        components:
+          - type: ziti-controller
+            id: ctrl
+      router:
+        components:
+          - type: systemd-service
+            id: router
+            config:
+              binary: ziti-router
+              args: [run, "--ctrl", "tls:${hosts.ctrl.privateIp}:6262"]
+              version: ${hosts.ctrl.publicIp}
+stages:
+  configuration:
+    - type: test-record
+      params:
+        name: ${hosts.ctrl.privateIp}
+`
+	m, err := LoadModelFromBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("LoadModelFromBytes failed: %v", err)
+	}
+
+	c := m.Regions["us-east-1"].Hosts["router"].Components["router"]
+	router := c.Type.(*model.SystemdServiceType)
+	if router.Binary != "ziti-router" || router.Version != "" || router.Args != nil {
+		t.Errorf("expected only the config known before express to be decoded, got %+v", router)
+	}
+
+	// nothing is bound until the addresses are known, and building requires them
+	if err = m.BindComponentConfigs(); err != nil {
+		t.Fatalf("expected unknown addresses to be left for later, got %v", err)
+	}
+	if err = c.BindConfig(true); err == nil || !strings.Contains(err.Error(), "not known yet") {
+		t.Errorf("expected binding to require the addresses, got %v", err)
+	}
+
+	ctrlHost := m.Regions["us-east-1"].Hosts["ctrl"]
+	ctrlHost.PublicIp = "1.2.3.4"
+	ctrlHost.PrivateIp = "10.0.0.5"
+	if err = m.BindComponentConfigs(); err != nil {
+		t.Fatalf("binding failed: %v", err)
+	}
+	if router.Version != "1.2.3.4" || strings.Join(router.Args, " ") != "run --ctrl tls:10.0.0.5:6262" {
+		t.Errorf("expected late bound config, got %+v", router)
+	}
+
+	recordedSteps = nil
+	if err = m.Configuration[0].Execute(model.NewContext(m, nil, nil).NewRun()); err != nil {
+		t.Fatalf("executing stage failed: %v", err)
+	}
+	if strings.Join(recordedSteps, ",") != "10.0.0.5" {
+		t.Errorf("expected the stage to be built with the private ip, got %v", recordedSteps)
+	}
+}
+
+func TestValidateConfig_LateReferenceInData(t *testing.T) {
+	yaml := `model:
+  id: test-model
+regions:
+  us-east-1:
+    hosts:
+      ctrl:
+        components:
+          - type: ziti-controller
+      router:
+        data:
+          ctrl: ${hosts.ctrl.privateIp}
+        components:
+          - type: ziti-router
+            config:
+              mode: edge
+              version: ${hosts.ctrl.publicIp}
+`
+	result, err := ValidateConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ValidateConfigBytes failed: %v", err)
+	}
+	if len(result.Errors) != 1 {
+		t.Fatalf("expected a single error, got %v", result.Errors)
+	}
+	if e := result.Errors[0]; e.Path != "regions.us-east-1.hosts.router.data.ctrl" ||
+		!strings.Contains(e.Message, "only variables, component config and stage params can reference it") {
+		t.Errorf("unexpected error %v", e)
+	}
+}
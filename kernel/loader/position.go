@@ -0,0 +1,261 @@
+package loader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/openziti/fablab/kernel/model"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Position locates a value in a YAML source file. Line and Column are 1-based, Snippet holds the
+// source line.
+type Position struct {
+	File    string
+	Line    int
+	Column  int
+	Snippet string
+}
+
+// IsValid returns true if the position points at a source line.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		return p.File
+	}
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// sourceMap maps paths in a document to where they were declared. Paths are keyed the way
+// documents are merged, list entries carrying an id are keyed by that id rather than their
+// index, so positions follow entries as overlays are applied.
+type sourceMap map[string]Position
+
+// merge records the positions of overlay on top of those in s, mirroring mergeDocuments.
+func (s sourceMap) merge(overlay sourceMap) sourceMap {
+	result := sourceMap{}
+	for k, v := range s {
+		result[k] = v
+	}
+	for k, v := range overlay {
+		result[k] = v
+	}
+	return result
+}
+
+// sourcePathKey holds the YAML path an entity was declared at in its scope data. Scope data is
+// copied when entities are cloned, so scaled entities report the path of their declaration.
+const sourcePathKey = "__loader_path__"
+
+func setSourcePath(scope *model.Scope, path string) {
+	scope.Data[sourcePathKey] = path
+}
+
+func sourcePath(entity model.Entity) string {
+	path, _ := entity.GetScope().Data[sourcePathKey].(string)
+	return path
+}
+
+func clearSourcePaths(m *model.Model) {
+	m.Accept(func(entity model.Entity) {
+		delete(entity.GetScope().Data, sourcePathKey)
+	})
+}
+
+// positionIndex maps the paths used in validation results to source positions.
+type positionIndex map[string]Position
+
+// lookup returns the position of path, falling back to its closest declared ancestor, so
+// errors about missing keys point at the enclosing block.
+func (idx positionIndex) lookup(path string) Position {
+	for {
+		if pos, found := idx[path]; found {
+			return pos
+		}
+		if path == "" {
+			return Position{}
+		}
+		path = parentPath(path)
+	}
+}
+
+func parentPath(path string) string {
+	if i := strings.LastIndexAny(path, ".["); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+func childPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// displayFile returns path relative to the working directory when it lies beneath it.
+func displayFile(path string) string {
+	if path == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
+
+// parseNode parses data into a YAML node tree. Syntax errors are returned as a ValidationError
+// carrying the position reported by the parser.
+func parseNode(data []byte, file string) (*yamlv3.Node, error) {
+	var node yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &node); err != nil {
+		pos := Position{File: file}
+		if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+			pos.Line, _ = strconv.Atoi(match[1])
+			pos.Column = 1
+			pos.Snippet = sourceLine(data, pos.Line)
+		}
+		msg := yamlErrorLine.ReplaceAllString(strings.TrimPrefix(err.Error(), "yaml: "), "")
+		return nil, ValidationError{Message: "failed to parse YAML: " + msg, Position: pos}
+	}
+	return &node, nil
+}
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+): `)
+
+func sourceLine(data []byte, line int) string {
+	lines := strings.Split(string(data), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[line-1], "\r")
+}
+
+// recordPositions walks a parsed file and returns the position of every value in it.
+func recordPositions(node *yamlv3.Node, data []byte, file string) sourceMap {
+	positions := sourceMap{}
+	lines := strings.Split(string(data), "\n")
+	position := func(n *yamlv3.Node) Position {
+		pos := Position{File: file, Line: n.Line, Column: n.Column}
+		if n.Line > 0 && n.Line <= len(lines) {
+			pos.Snippet = strings.TrimRight(lines[n.Line-1], "\r")
+		}
+		return pos
+	}
+
+	positions[""] = Position{File: file, Line: 1, Column: 1, Snippet: strings.TrimRight(lines[0], "\r")}
+	walkNode(node, "", true, func(path string, key, value *yamlv3.Node) {
+		if key != nil && value.Kind != yamlv3.ScalarNode {
+			positions[path] = position(key)
+		} else {
+			positions[path] = position(value)
+		}
+	})
+	return positions
+}
+
+// walkNode visits every value beneath node with its path. Mapping values are visited with their
+// key node, sequence entries without. If byId is set, sequence entries carrying an id are keyed
+// by it, matching the keys of a sourceMap.
+func walkNode(node *yamlv3.Node, path string, byId bool, visit func(path string, key, value *yamlv3.Node)) {
+	switch node.Kind {
+	case yamlv3.DocumentNode:
+		for _, child := range node.Content {
+			walkNode(child, path, byId, visit)
+		}
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			valuePath := childPath(path, key.Value)
+			visit(valuePath, key, value)
+			walkNode(value, valuePath, byId, visit)
+		}
+	case yamlv3.SequenceNode:
+		for i, item := range node.Content {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			if byId {
+				if id, found := nodeId(item); found {
+					itemPath = fmt.Sprintf("%s[id=%s]", path, id)
+				}
+			}
+			visit(itemPath, nil, item)
+			walkNode(item, itemPath, byId, visit)
+		}
+	}
+}
+
+func nodeId(node *yamlv3.Node) (string, bool) {
+	if node.Kind != yamlv3.MappingNode {
+		return "", false
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "id" && node.Content[i+1].Kind == yamlv3.ScalarNode {
+			return node.Content[i+1].Value, true
+		}
+	}
+	return "", false
+}
+
+// indexPositions maps the paths of a merged document, which address list entries by index,
+// to the positions its values were declared at.
+func indexPositions(doc document, sources sourceMap) positionIndex {
+	idx := positionIndex{}
+	if pos, found := sources[""]; found {
+		idx[""] = pos
+	}
+
+	var walk func(value interface{}, path, key string)
+	walk = func(value interface{}, path, key string) {
+		if pos, found := sources[key]; found {
+			idx[path] = pos
+		}
+		switch v := value.(type) {
+		case document:
+			for k, child := range v {
+				name := fmt.Sprint(k)
+				walk(child, childPath(path, name), childPath(key, name))
+			}
+		case []interface{}:
+			for i, entry := range v {
+				entryKey := fmt.Sprintf("%s[%d]", key, i)
+				if entryDoc, ok := entry.(document); ok {
+					if id, ok := entryDoc["id"].(string); ok {
+						entryKey = fmt.Sprintf("%s[id=%s]", key, id)
+					}
+				}
+				walk(entry, fmt.Sprintf("%s[%d]", path, i), entryKey)
+			}
+		}
+	}
+	for k, child := range doc {
+		name := fmt.Sprint(k)
+		walk(child, name, name)
+	}
+	return idx
+}
+
+// renderedLinePaths maps the lines of a re-encoded document to the paths declared on them, so
+// decode errors against the re-encoded document can be traced back to the source files.
+func renderedLinePaths(data []byte) map[int]string {
+	var node yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &node); err != nil {
+		return nil
+	}
+	lines := map[int]string{}
+	walkNode(&node, "", false, func(path string, key, value *yamlv3.Node) {
+		lines[value.Line] = path
+	})
+	return lines
+}
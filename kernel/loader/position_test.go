@@ -0,0 +1,101 @@
+package loader
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateConfig_Positions(t *testing.T) {
+	yaml := `model:
+  id: test-model
+regions:
+  us-east-1:
+    hosts:
+      host1:
+        tags: [bad.tag]
+        components:
+          - type: ziti-controller
+            id: ctrl
+          - type: unknown-type
+`
+	result, err := ValidateConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ValidateConfigBytes failed: %v", err)
+	}
+
+	positions := map[string]Position{}
+	for _, e := range result.Errors {
+		positions[e.Path] = e.Position
+	}
+
+	tagPos := positions["regions.us-east-1.hosts.host1.tags[0]"]
+	if tagPos.Line != 7 || tagPos.Column != 16 || tagPos.Snippet != "        tags: [bad.tag]" {
+		t.Errorf("unexpected tag position %+v", tagPos)
+	}
+	typePos := positions["regions.us-east-1.hosts.host1.components[1].type"]
+	if typePos.Line != 11 || typePos.Column != 19 {
+		t.Errorf("unexpected type position %+v", typePos)
+	}
+}
+
+func TestValidateConfig_MissingValuePointsAtParent(t *testing.T) {
+	yaml := `model:
+  tags: [a]
+`
+	result, err := ValidateConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ValidateConfigBytes failed: %v", err)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Position.Line != 1 {
+		t.Fatalf("expected missing model id reported at the model block, got %+v", result.Errors)
+	}
+}
+
+func TestValidateConfig_SyntaxError(t *testing.T) {
+	path := writeYamlFile(t, t.TempDir(), "broken.yaml", "model:\n  id: test\n  tags: [a\n")
+
+	result, err := ValidateConfig(path)
+	if err != nil {
+		t.Fatalf("ValidateConfig failed: %v", err)
+	}
+	if len(result.Errors) != 1 {
+		t.Fatalf("expected a syntax error, got %+v", result.Errors)
+	}
+	pos := result.Errors[0].Position
+	if filepath.Base(pos.File) != "broken.yaml" || !pos.IsValid() {
+		t.Errorf("expected syntax error positioned in broken.yaml, got %+v", pos)
+	}
+}
+
+func TestValidateConfig_TypeErrorInOverlay(t *testing.T) {
+	dir := t.TempDir()
+	basePath := writeYamlFile(t, dir, "base.yaml", `model:
+  id: test-model
+regions:
+  us-east-1:
+    hosts:
+      host1:
+        instanceType: t3.micro
+`)
+	overlayPath := writeYamlFile(t, dir, "overlay.yaml", `regions:
+  us-east-1:
+    hosts:
+      host1:
+        scale: many
+`)
+
+	result, err := ValidateConfig(basePath, overlayPath)
+	if err != nil {
+		t.Fatalf("ValidateConfig failed: %v", err)
+	}
+	if len(result.Errors) != 1 {
+		t.Fatalf("expected a type error, got %+v", result.Errors)
+	}
+	e := result.Errors[0]
+	if e.Path != "regions.us-east-1.hosts.host1.scale" {
+		t.Errorf("unexpected path %s", e.Path)
+	}
+	if filepath.Base(e.Position.File) != "overlay.yaml" || e.Position.Line != 5 || e.Position.Column != 16 {
+		t.Errorf("expected type error positioned in overlay.yaml, got %+v", e.Position)
+	}
+}
@@ -0,0 +1,78 @@
+package loader
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig_ComponentRules(t *testing.T) {
+	yaml := `
+model:
+  id: test-model
+regions:
+  us-east-1:
+    hosts:
+      edge:
+        instanceType: t3.micro
+        components:
+          - type: ziti-router
+            id: router-a
+          - type: ziti-router
+            id: router-b
+            config:
+              mode: mesh
+`
+	result, err := ValidateConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ValidateConfigBytes failed: %v", err)
+	}
+
+	messages := map[string][]string{}
+	for _, e := range result.Errors {
+		messages[e.Path] = append(messages[e.Path], e.Message)
+	}
+
+	if !strings.Contains(strings.Join(messages["regions.us-east-1.hosts.edge.components[0]"], ";"), "requires a ziti-controller") {
+		t.Errorf("expected missing controller error, got %v", result.Errors)
+	}
+	if !strings.Contains(strings.Join(messages["regions.us-east-1.hosts.edge.components[1].config.mode"], ";"), "invalid mode 'mesh'") {
+		t.Errorf("expected invalid mode error, got %v", result.Errors)
+	}
+	// the routers' listen ports are only known from their rendered configs
+	if strings.Contains(strings.Join(messages["regions.us-east-1.hosts.edge.components[1]"], ";"), "port") {
+		t.Errorf("expected no port conflict before the configs are rendered, got %v", result.Errors)
+	}
+
+	for _, e := range result.Errors {
+		if e.Path == "regions.us-east-1.hosts.edge.components[1].config.mode" && e.Position.Line != 15 {
+			t.Errorf("expected mode error at line 15, got %+v", e.Position)
+		}
+	}
+}
+
+func TestValidateConfig_ControllerWithoutPublicIp(t *testing.T) {
+	yaml := `
+model:
+  id: test-model
+regions:
+  us-east-1:
+    hosts:
+      ctrl:
+        components:
+          - type: ziti-controller
+      edge:
+        publicIp: 203.0.113.10
+        components:
+          - type: ziti-router
+`
+	result, err := ValidateConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ValidateConfigBytes failed: %v", err)
+	}
+	if !result.IsValid() {
+		t.Fatalf("expected valid config, got errors: %v", result.Errors)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Path != "regions.us-east-1.hosts.ctrl.components[0]" {
+		t.Fatalf("expected a public IP warning for the controller, got %v", result.Warnings)
+	}
+}
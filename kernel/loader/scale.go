@@ -0,0 +1,89 @@
+package loader
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/openziti/fablab/kernel/model"
+)
+
+// scaleDataKey holds the declared scale of a region, host or component in its scope data until
+// the ScaleFactory has run. Scope data is copied when entities are cloned, so entities nested
+// inside a scaled parent keep their own declared scale.
+const scaleDataKey = "__loader_scale__"
+
+var templateExpr = regexp.MustCompile(`{{[^}]*}}`)
+
+// scaleStrategy is the model.ScaleStrategy for entities declared with `scale: N` in YAML.
+type scaleStrategy struct{}
+
+func (scaleStrategy) IsScaled(entity model.Entity) bool {
+	_, found := entity.GetScope().Data[scaleDataKey]
+	return found
+}
+
+func (scaleStrategy) GetEntityCount(entity model.Entity) uint32 {
+	count, _ := entity.GetScope().Data[scaleDataKey].(uint32)
+	return count
+}
+
+func (scaleStrategy) ClearScaleMarker(entity model.Entity) {
+	delete(entity.GetScope().Data, scaleDataKey)
+}
+
+func setScale(scope *model.Scope, scale *uint32) {
+	if scale != nil {
+		scope.Data[scaleDataKey] = *scale
+	}
+}
+
+// applyScaling stamps out the scaled entities using the default scale entity factory, which
+// renders templated ids such as `router-{{ .ScaleIndex }}`. The counts are those declared; when
+// the model is bootstrapped for an instance, model.Model.BindScales builds the scale groups
+// again at the counts recorded in its label.
+func applyScaling(m *model.Model) error {
+	strategy := scaleStrategy{}
+
+	scaled := false
+	m.Accept(func(entity model.Entity) {
+		if strategy.IsScaled(entity) {
+			scaled = true
+		}
+	})
+	if !scaled {
+		return nil
+	}
+
+	return model.NewScaleFactoryWithDefaultEntityFactory(strategy).Build(m)
+}
+
+// isTemplated returns true if the value contains a template expression.
+func isTemplated(value string) bool {
+	return templateExpr.MatchString(value)
+}
+
+// validateEntityId checks an entity id, which may be templated when the entity is scaled,
+// returning an error message or the empty string if the id is valid.
+func validateEntityId(kind, id string, scale *uint32) string {
+	if isTemplated(id) {
+		if !validIdPattern.MatchString(templateExpr.ReplaceAllString(id, "0")) {
+			return fmt.Sprintf("invalid %s id format", kind)
+		}
+		return ""
+	}
+
+	if strings.Contains(id, "{{") || strings.Contains(id, "}}") {
+		return fmt.Sprintf("malformed template in %s id", kind)
+	}
+
+	if !validIdPattern.MatchString(id) {
+		return fmt.Sprintf("invalid %s id format", kind)
+	}
+
+	if scale != nil && *scale > 1 {
+		return fmt.Sprintf("%s is scaled to %d, its id must be templated, e.g. '%s-{{ .ScaleIndex }}'", kind, *scale, id)
+	}
+
+	return ""
+}
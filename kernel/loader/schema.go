@@ -0,0 +1,108 @@
+package loader
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/invopop/jsonschema"
+	"github.com/openziti/fablab/kernel/model"
+)
+
+// SchemaVersion is the JSON Schema dialect of the generated schemas.
+const SchemaVersion = "https://json-schema.org/draft/2020-12/schema"
+
+func newSchemaReflector() *jsonschema.Reflector {
+	return &jsonschema.Reflector{
+		Anonymous:                  true,
+		FieldNameTag:               "yaml",
+		RequiredFromJSONSchemaTags: true,
+		ExpandedStruct:             true,
+	}
+}
+
+// GenerateSchema returns a JSON Schema for FablabYaml. Component and stage types are restricted
+// to the registered types, and each component's config block is validated against the
+// schema of its type.
+func GenerateSchema() *jsonschema.Schema {
+	schema := newSchemaReflector().Reflect(&FablabYaml{})
+	schema.Version = SchemaVersion
+	schema.Title = "fablab model"
+
+	// composition keys are resolved before the document is decoded into FablabYaml
+	schema.Properties.Set(BaseKey, &jsonschema.Schema{
+		Type:        "string",
+		Description: "Model file this document overlays",
+	})
+	schema.Properties.Set(IncludeKey, &jsonschema.Schema{
+		Description: "Files merged underneath this document",
+		OneOf: []*jsonschema.Schema{
+			{Type: "string"},
+			{Type: "array", Items: &jsonschema.Schema{Type: "string"}},
+		},
+	})
+
+	if stepSchema, found := schema.Definitions["StepYaml"]; found {
+		if typeSchema, found := stepSchema.Properties.Get("type"); found {
+			for _, typeName := range model.ListStageTypes() {
+				typeSchema.Enum = append(typeSchema.Enum, typeName)
+			}
+		}
+	}
+
+	componentSchema, found := schema.Definitions["ComponentYaml"]
+	if !found {
+		return schema
+	}
+
+	typeNames := model.ListComponentTypes()
+	sort.Strings(typeNames)
+
+	if typeSchema, found := componentSchema.Properties.Get("type"); found {
+		for _, typeName := range typeNames {
+			typeSchema.Enum = append(typeSchema.Enum, typeName)
+		}
+	}
+
+	for _, typeName := range typeNames {
+		configSchema, err := ComponentTypeSchema(typeName)
+		if err != nil {
+			continue
+		}
+		defName := "component." + typeName
+		configSchema.Version = ""
+		schema.Definitions[defName] = configSchema
+
+		thenSchema := &jsonschema.Schema{Properties: jsonschema.NewProperties()}
+		thenSchema.Properties.Set("config", &jsonschema.Schema{Ref: "#/$defs/" + defName})
+
+		ifSchema := &jsonschema.Schema{Properties: jsonschema.NewProperties(), Required: []string{"type"}}
+		ifSchema.Properties.Set("type", &jsonschema.Schema{Const: typeName})
+
+		componentSchema.AllOf = append(componentSchema.AllOf, &jsonschema.Schema{If: ifSchema, Then: thenSchema})
+	}
+
+	return schema
+}
+
+// ComponentTypeSchema returns the JSON Schema of the config block for a registered component
+// type, built from the yaml tags of the type's struct.
+func ComponentTypeSchema(typeName string) (*jsonschema.Schema, error) {
+	componentType, err := model.GetComponentType(typeName)
+	if err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf(componentType)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("component type '%s' is not a struct and has no configurable fields", typeName)
+	}
+
+	schema := newSchemaReflector().ReflectFromType(t)
+	schema.Version = SchemaVersion
+	schema.Title = typeName
+	return schema, nil
+}
@@ -0,0 +1,49 @@
+package loader
+
+import (
+	"testing"
+
+	"github.com/openziti/fablab/kernel/model"
+)
+
+func TestGenerateSchema(t *testing.T) {
+	schema := GenerateSchema()
+
+	if schema.Version != SchemaVersion {
+		t.Errorf("expected schema version %s, got %s", SchemaVersion, schema.Version)
+	}
+	for _, key := range []string{"model", "regions", BaseKey, IncludeKey} {
+		if _, found := schema.Properties.Get(key); !found {
+			t.Errorf("expected root property '%s'", key)
+		}
+	}
+
+	componentSchema, found := schema.Definitions["ComponentYaml"]
+	if !found {
+		t.Fatal("expected ComponentYaml definition")
+	}
+
+	typeSchema, _ := componentSchema.Properties.Get("type")
+	if len(typeSchema.Enum) != len(model.ListComponentTypes()) {
+		t.Errorf("expected type enum of registered types, got %v", typeSchema.Enum)
+	}
+	if len(componentSchema.AllOf) != len(model.ListComponentTypes()) {
+		t.Errorf("expected a config rule per registered type, got %d", len(componentSchema.AllOf))
+	}
+
+	routerSchema, found := schema.Definitions["component.ziti-router"]
+	if !found {
+		t.Fatal("expected ziti-router config definition")
+	}
+	for _, key := range []string{"version", "mode"} {
+		if _, found := routerSchema.Properties.Get(key); !found {
+			t.Errorf("expected ziti-router config property '%s'", key)
+		}
+	}
+}
+
+func TestComponentTypeSchema_UnknownType(t *testing.T) {
+	if _, err := ComponentTypeSchema("unknown-component"); err == nil {
+		t.Fatal("expected error for unknown component type")
+	}
+}
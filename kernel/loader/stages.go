@@ -0,0 +1,147 @@
+package loader
+
+import (
+	"fmt"
+	"strings"
+
+	// registers the library's stage builders
+	_ "github.com/openziti/fablab/kernel/lib/stages"
+	"github.com/openziti/fablab/kernel/model"
+)
+
+// StagesYaml declares the stages run by each lifecycle phase, e.g. `fablab up` runs the
+// infrastructure, configuration, distribution and activation stages.
+type StagesYaml struct {
+	Infrastructure []StepYaml `yaml:"infrastructure,omitempty"`
+	Configuration  []StepYaml `yaml:"configuration,omitempty"`
+	Distribution   []StepYaml `yaml:"distribution,omitempty"`
+	Activation     []StepYaml `yaml:"activation,omitempty"`
+	Operation      []StepYaml `yaml:"operation,omitempty"`
+	Disposal       []StepYaml `yaml:"disposal,omitempty"`
+}
+
+// StepYaml declares a stage, or a step of an action, built by the stage builder registered
+// under Type from Params.
+type StepYaml struct {
+	Type   string                 `yaml:"type" jsonschema:"required"`
+	Params map[string]interface{} `yaml:"params,omitempty" jsonschema_description:"Parameters of the stage type"`
+}
+
+type stagePhase struct {
+	name  string
+	steps []StepYaml
+}
+
+// phases returns the declared stages of each phase, named as in YAML, in lifecycle order.
+func (s *StagesYaml) phases() []stagePhase {
+	return []stagePhase{
+		{"infrastructure", s.Infrastructure},
+		{"configuration", s.Configuration},
+		{"distribution", s.Distribution},
+		{"activation", s.Activation},
+		{"operation", s.Operation},
+		{"disposal", s.Disposal},
+	}
+}
+
+// validateStages checks that stages and actions use registered stage types, and that the
+// actions steps run are declared.
+func validateStages(config *FablabYaml, result *ValidationResult) {
+	for _, phase := range config.Stages.phases() {
+		validateSteps(config, "stages."+phase.name, phase.steps, result)
+	}
+	for _, name := range sortedKeys(config.Actions) {
+		path := "actions." + name
+		if len(config.Actions[name]) == 0 {
+			result.AddWarning(path, fmt.Sprintf("action '%s' has no steps", name))
+		}
+		validateSteps(config, path, config.Actions[name], result)
+	}
+}
+
+func validateSteps(config *FablabYaml, basePath string, steps []StepYaml, result *ValidationResult) {
+	for i, step := range steps {
+		path := fmt.Sprintf("%s[%d]", basePath, i)
+		if step.Type == "" {
+			result.AddError(path+".type", "stage type is required")
+			continue
+		}
+		if !model.HasStageType(step.Type) {
+			result.AddError(path+".type", fmt.Sprintf("unknown stage type '%s'. Valid types: %s",
+				step.Type, strings.Join(model.ListStageTypes(), ", ")))
+			continue
+		}
+		if step.Type == "action" {
+			if name, ok := step.Params["name"].(string); ok && !model.IsExpression(name) {
+				if _, found := config.Actions[name]; !found {
+					result.AddError(path+".params.name", fmt.Sprintf("action '%s' is not declared", name))
+				}
+			}
+		}
+	}
+}
+
+// validateStageParams builds every stage, reporting the parameters its builder rejects. It runs
+// once expressions in the parameters have been resolved. Stages with parameters referencing values
+// only known after express are built when they're run, and can't be checked before.
+func validateStageParams(config *FablabYaml, result *ValidationResult) {
+	check := func(basePath string, steps []StepYaml) {
+		for i, step := range steps {
+			if model.HasExpressions(step.Params) {
+				continue
+			}
+			path := fmt.Sprintf("%s[%d]", basePath, i)
+			if _, err := model.BuildStage(step.Type, step.Params); err != nil {
+				result.AddError(path+".params", err.Error())
+			}
+		}
+	}
+	for _, phase := range config.Stages.phases() {
+		check("stages."+phase.name, phase.steps)
+	}
+	for _, name := range sortedKeys(config.Actions) {
+		check("actions."+name, config.Actions[name])
+	}
+}
+
+// buildStages adds the declared stages and actions to the model.
+func buildStages(config *FablabYaml, m *model.Model) error {
+	targets := []*model.Stages{
+		&m.Infrastructure, &m.Configuration, &m.Distribution, &m.Activation, &m.Operation, &m.Disposal,
+	}
+	for i, phase := range config.Stages.phases() {
+		stages, err := buildSteps("stages."+phase.name, phase.steps)
+		if err != nil {
+			return err
+		}
+		*targets[i] = append(*targets[i], stages...)
+	}
+
+	if len(config.Actions) > 0 && m.Actions == nil {
+		m.Actions = model.ActionBinders{}
+	}
+	for name, steps := range config.Actions {
+		stages, err := buildSteps("actions."+name, steps)
+		if err != nil {
+			return err
+		}
+		m.AddAction(name, model.StageSequence(stages))
+	}
+	return nil
+}
+
+func buildSteps(basePath string, steps []StepYaml) (model.Stages, error) {
+	var stages model.Stages
+	for i, step := range steps {
+		if model.HasExpressions(step.Params) {
+			stages = append(stages, model.BuildStageWhenRun(step.Type, step.Params))
+			continue
+		}
+		stage, err := model.BuildStage(step.Type, step.Params)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", basePath, i, err)
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
@@ -0,0 +1,167 @@
+package loader
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openziti/fablab/kernel/model"
+)
+
+var recordedSteps []string
+
+func init() {
+	model.RegisterStageBuilder("test-record", func(params model.StageParams) (model.Stage, error) {
+		p := struct {
+			Name string `yaml:"name"`
+		}{}
+		if err := params.Decode(&p); err != nil {
+			return nil, err
+		}
+		return model.StageActionF(func(model.Run) error {
+			recordedSteps = append(recordedSteps, p.Name)
+			return nil
+		}), nil
+	})
+}
+
+func TestLoadModel_Stages(t *testing.T) {
+	yaml := `
+model:
+  id: test-model
+  variables:
+    pause: 10s
+regions:
+  us-east-1:
+    hosts:
+      ctrl:
+        instanceType: t3.micro
+        components:
+          - type: ziti-controller
+stages:
+  infrastructure:
+    - type: aws-ssh-key
+    - type: terraform
+      params:
+        retries: 1
+  configuration:
+    - type: static-config
+      params:
+        configs:
+          - src: ctrl.yml.tmpl
+            name: ctrl.yml
+  distribution:
+    - type: rsync
+  activation:
+    - type: action
+      params:
+        name: bootstrap
+  disposal:
+    - type: terraform-dispose
+    - type: aws-ssh-key-dispose
+actions:
+  bootstrap:
+    - type: test-record
+      params:
+        name: first
+    - type: sleep
+      params:
+        duration: ${model.variables.pause}
+  record:
+    - type: test-record
+      params:
+        name: first
+    - type: test-record
+      params:
+        name: second
+`
+	m, err := LoadModelFromBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("LoadModelFromBytes failed: %v", err)
+	}
+
+	if len(m.Infrastructure) != 2 || len(m.Configuration) != 1 || len(m.Distribution) != 1 ||
+		len(m.Activation) != 1 || len(m.Operation) != 0 || len(m.Disposal) != 2 {
+		t.Errorf("unexpected stage counts: %d/%d/%d/%d/%d/%d", len(m.Infrastructure), len(m.Configuration),
+			len(m.Distribution), len(m.Activation), len(m.Operation), len(m.Disposal))
+	}
+
+	binder, found := m.Actions["record"]
+	if !found {
+		t.Fatalf("expected action 'record', got %v", m.Actions)
+	}
+	if _, found := m.Actions["bootstrap"]; !found {
+		t.Errorf("expected action 'bootstrap'")
+	}
+
+	recordedSteps = nil
+	if err := binder(m).Execute(nil); err != nil {
+		t.Fatalf("executing action failed: %v", err)
+	}
+	if strings.Join(recordedSteps, ",") != "first,second" {
+		t.Errorf("expected steps to run in order, got %v", recordedSteps)
+	}
+}
+
+func TestValidateConfig_Stages(t *testing.T) {
+	yaml := `
+model:
+  id: test-model
+regions:
+  us-east-1:
+    hosts:
+      ctrl:
+        instanceType: t3.micro
+stages:
+  infrastructure:
+    - type: terraform-express
+  activation:
+    - type: action
+      params:
+        name: missing
+actions:
+  pause:
+    - type: sleep
+      params:
+        duration: soon
+    - type: host-exec
+      params:
+        cmd: uptime
+`
+	result, err := ValidateConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ValidateConfigBytes failed: %v", err)
+	}
+
+	messages := map[string]string{}
+	for _, e := range result.Errors {
+		messages[e.Path] = e.Message
+	}
+	if !strings.Contains(messages["stages.infrastructure[0].type"], "unknown stage type 'terraform-express'") {
+		t.Errorf("expected unknown stage type error, got %v", result.Errors)
+	}
+	if !strings.Contains(messages["stages.activation[0].params.name"], "action 'missing' is not declared") {
+		t.Errorf("expected undeclared action error, got %v", result.Errors)
+	}
+
+	// params are only checked once the configuration is structurally valid
+	yaml = strings.Replace(yaml, "terraform-express", "terraform", 1)
+	yaml = strings.Replace(yaml, "name: missing", "name: pause", 1)
+	result, err = ValidateConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ValidateConfigBytes failed: %v", err)
+	}
+
+	messages = map[string]string{}
+	for _, e := range result.Errors {
+		messages[e.Path] = e.Message
+	}
+	if !strings.Contains(messages["actions.pause[0].params"], "invalid params") {
+		t.Errorf("expected invalid duration error, got %v", result.Errors)
+	}
+	if !strings.Contains(messages["actions.pause[1].params"], "field cmd not found") {
+		t.Errorf("expected unknown param error, got %v", result.Errors)
+	}
+	if pos := result.Errors[0].Position; !pos.IsValid() {
+		t.Errorf("expected params errors to carry a position, got %v", result.Errors)
+	}
+}
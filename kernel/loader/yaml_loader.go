@@ -1,29 +1,44 @@
 package loader
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/openziti/fablab/kernel/model"
+	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 )
 
-// ValidationError represents a validation issue.
+// ValidationError represents a validation issue. Position locates the offending value in the
+// source files, or its closest enclosing block if the value is missing.
 type ValidationError struct {
-	Path    string
-	Message string
+	Path     string
+	Message  string
+	Position Position
 }
 
 func (e ValidationError) Error() string {
-	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+	msg := e.Message
+	if e.Path != "" {
+		msg = fmt.Sprintf("%s: %s", e.Path, e.Message)
+	}
+	if e.Position.IsValid() || e.Position.File != "" {
+		return fmt.Sprintf("%s: %s", e.Position, msg)
+	}
+	return msg
 }
 
 // ValidationResult contains all validation errors.
 type ValidationResult struct {
 	Errors   []ValidationError
 	Warnings []ValidationError
+
+	positions positionIndex
 }
 
 func (r *ValidationResult) IsValid() bool {
@@ -31,67 +46,124 @@ func (r *ValidationResult) IsValid() bool {
 }
 
 func (r *ValidationResult) AddError(path, message string) {
-	r.Errors = append(r.Errors, ValidationError{Path: path, Message: message})
+	r.Errors = append(r.Errors, ValidationError{Path: path, Message: message, Position: r.positions.lookup(path)})
 }
 
 func (r *ValidationResult) AddWarning(path, message string) {
-	r.Warnings = append(r.Warnings, ValidationError{Path: path, Message: message})
+	r.Warnings = append(r.Warnings, ValidationError{Path: path, Message: message, Position: r.positions.lookup(path)})
 }
 
 // Valid ID pattern: alphanumeric, hyphens, underscores
 var validIdPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
 
+// Valid tag pattern: tags are used in selectors, so they may not contain selector syntax
+var validTagPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
 // FablabYaml is the root YAML structure
 type FablabYaml struct {
-	Model   ModelYaml            `yaml:"model"`
-	Regions map[string]RegionYaml `yaml:"regions"`
+	Model   ModelYaml             `yaml:"model" jsonschema:"required"`
+	Regions map[string]RegionYaml `yaml:"regions" jsonschema_description:"Regions keyed by id"`
+	Stages  StagesYaml            `yaml:"stages,omitempty" jsonschema_description:"Stages run by each lifecycle phase"`
+	Actions map[string][]StepYaml `yaml:"actions,omitempty" jsonschema_description:"Actions run by fablab exec, keyed by name"`
+
+	positions positionIndex
+}
+
+// ScopeYaml holds the tags, variables and data that can be declared at every level of the model.
+// It maps onto model.Scope, so selectors and variable resolution behave as they do for Go models.
+type ScopeYaml struct {
+	Tags      []string               `yaml:"tags,omitempty" jsonschema_description:"Tags used by selectors such as .edge"`
+	Variables map[string]interface{} `yaml:"variables,omitempty" jsonschema_description:"Variables resolved hierarchically from this level down"`
+	Data      map[string]interface{} `yaml:"data,omitempty" jsonschema_description:"Arbitrary data attached to the entity"`
 }
 
 // ModelYaml contains model-level configuration
 type ModelYaml struct {
-	Id string `yaml:"id"`
+	ScopeYaml `yaml:",inline"`
+	Id        string `yaml:"id" jsonschema:"required"`
 }
 
-// RegionYaml represents a deployment region
+// RegionYaml represents a deployment region. Scale stamps out that many copies of the region,
+// which requires a templated id such as `region-{{ .ScaleIndex }}`. HostDefaults are inherited
+// by the region's hosts.
 type RegionYaml struct {
-	Site  string              `yaml:"site"`
-	Hosts map[string]HostYaml `yaml:"hosts"`
+	ScopeYaml    `yaml:",inline"`
+	Region       string              `yaml:"region,omitempty" jsonschema_description:"Cloud region name, defaults to the region id"`
+	Site         string              `yaml:"site"`
+	Scale        *uint32             `yaml:"scale,omitempty" jsonschema_description:"Number of copies to create, ids must be templated"`
+	HostDefaults HostSpecYaml        `yaml:"hostDefaults,omitempty" jsonschema_description:"Provisioning settings inherited by the region's hosts"`
+	Hosts        map[string]HostYaml `yaml:"hosts" jsonschema_description:"Hosts keyed by id"`
 }
 
 // HostYaml represents a host/VM configuration
 type HostYaml struct {
-	InstanceType string          `yaml:"instanceType"`
+	ScopeYaml    `yaml:",inline"`
+	HostSpecYaml `yaml:",inline"`
+	PublicIp     string          `yaml:"publicIp,omitempty" jsonschema_description:"Static public IP of a host which isn't provisioned"`
+	Scale        *uint32         `yaml:"scale,omitempty" jsonschema_description:"Number of copies to create, ids must be templated"`
 	Components   []ComponentYaml `yaml:"components"`
 }
 
-// ComponentYaml represents a component configuration
+// ComponentYaml represents a component configuration. Config is decoded into the
+// registered ComponentType using its yaml tags.
 type ComponentYaml struct {
-	Type   string `yaml:"type"`
-	Id     string `yaml:"id"`
+	ScopeYaml    `yaml:",inline"`
+	Type         string                 `yaml:"type" jsonschema:"required"`
+	Id           string                 `yaml:"id"`
+	Scale        *uint32                `yaml:"scale,omitempty" jsonschema_description:"Number of copies to create, ids must be templated"`
+	DependsOn    []string               `yaml:"dependsOn,omitempty" jsonschema_description:"Selectors for the components to start before this one and stop after it"`
+	HealthChecks []model.HealthCheck    `yaml:"healthChecks,omitempty" jsonschema_description:"Probes telling whether the component is ready, replacing those of its type"`
+	Config       map[string]interface{} `yaml:"config,omitempty" jsonschema_description:"Configuration decoded into the component type"`
 }
 
 // ValidateConfig validates the YAML configuration without building the model.
-func ValidateConfig(path string) (*ValidationResult, error) {
-	data, err := os.ReadFile(path)
+// Multiple files are composed as they are by LoadModel. Syntax and type errors in the files are
+// reported in the result, other failures such as unreadable files are returned as errors.
+func ValidateConfig(paths ...string) (*ValidationResult, error) {
+	return validateLoaded(LoadConfig(paths...))
+}
+
+// ValidateConfigBytes validates YAML bytes. Includes and base files are resolved relative to
+// the working directory.
+func ValidateConfigBytes(data []byte) (*ValidationResult, error) {
+	return validateLoaded(loadConfigBytes(data))
+}
+
+func validateLoaded(config *FablabYaml, err error) (*ValidationResult, error) {
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		if errs := validationErrors(err); len(errs) > 0 {
+			return &ValidationResult{Errors: errs, Warnings: []ValidationError{}}, nil
+		}
+		return nil, err
 	}
-	return ValidateConfigBytes(data)
+	return validateConfig(config), nil
 }
 
-// ValidateConfigBytes validates YAML bytes.
-func ValidateConfigBytes(data []byte) (*ValidationResult, error) {
-	var config FablabYaml
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+// validationErrors returns the ValidationErrors err consists of, or nil if it has other causes.
+func validationErrors(err error) []ValidationError {
+	var errs []ValidationError
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			var validationErr ValidationError
+			if !errors.As(e, &validationErr) {
+				return nil
+			}
+			errs = append(errs, validationErr)
+		}
+		return errs
 	}
-	return validateConfig(&config), nil
+	var validationErr ValidationError
+	if errors.As(err, &validationErr) {
+		errs = append(errs, validationErr)
+	}
+	return errs
 }
 
 func validateConfig(config *FablabYaml) *ValidationResult {
 	result := &ValidationResult{
-		Errors:   []ValidationError{},
-		Warnings: []ValidationError{},
+		Errors:    []ValidationError{},
+		Warnings:  []ValidationError{},
+		positions: config.positions,
 	}
 
 	// Validate model section
@@ -100,15 +172,75 @@ func validateConfig(config *FablabYaml) *ValidationResult {
 	// Validate regions
 	validateRegions(config, result)
 
+	validateStages(config, result)
+
+	// Expressions and rules contributed by component types need a model, which is only built
+	// if the configuration is structurally valid
+	if result.IsValid() {
+		interpolateConfig(config, result)
+	}
+	if result.IsValid() {
+		validateStageParams(config, result)
+	}
+	if result.IsValid() {
+		validateComponentRules(config, result)
+	}
+
 	return result
 }
 
+// validateComponentRules builds the model and reports the issues found by its components.
+func validateComponentRules(config *FablabYaml, result *ValidationResult) {
+	m, err := buildModel(config)
+	if err != nil {
+		result.AddError("", err.Error())
+		return
+	}
+
+	validateDependencies(m, result)
+
+	for _, issue := range m.ValidateComponents(model.NewValidationContext(m)) {
+		path := sourcePath(issue.Component)
+		if issue.Field != "" {
+			path += ".config." + issue.Field
+		}
+		if issue.Severity == model.ValidationSeverityWarning {
+			result.AddWarning(path, issue.Message)
+		} else {
+			result.AddError(path, issue.Message)
+		}
+	}
+}
+
 func validateModel(config *FablabYaml, result *ValidationResult) {
 	if config.Model.Id == "" {
 		result.AddError("model.id", "model id is required")
 	} else if !validIdPattern.MatchString(config.Model.Id) {
 		result.AddError("model.id", "invalid id format: must start with letter and contain only alphanumeric, hyphens, underscores")
 	}
+
+	validateScope(&config.Model.ScopeYaml, "model", result)
+}
+
+func validateScope(scope *ScopeYaml, basePath string, result *ValidationResult) {
+	seenTags := make(map[string]bool)
+	for i, tag := range scope.Tags {
+		path := fmt.Sprintf("%s.tags[%d]", basePath, i)
+		if !validTagPattern.MatchString(tag) {
+			result.AddError(path, fmt.Sprintf("invalid tag '%s': must contain only alphanumeric, hyphens, underscores", tag))
+		}
+		if seenTags[tag] {
+			result.AddWarning(path, fmt.Sprintf("duplicate tag '%s'", tag))
+		}
+		seenTags[tag] = true
+	}
+
+	for name := range scope.Variables {
+		if strings.Contains(name, ".") {
+			result.AddError(fmt.Sprintf("%s.variables.%s", basePath, name),
+				"variable names may not contain '.', use nested maps instead")
+		}
+	}
 }
 
 func validateRegions(config *FablabYaml, result *ValidationResult) {
@@ -128,10 +260,13 @@ func validateRegions(config *FablabYaml, result *ValidationResult) {
 		seenRegionIds[regionId] = true
 
 		// Validate region ID format
-		if !validIdPattern.MatchString(regionId) {
-			result.AddError(path, "invalid region id format")
+		if msg := validateEntityId("region", regionId, region.Scale); msg != "" {
+			result.AddError(path, msg)
 		}
 
+		validateScope(&region.ScopeYaml, path, result)
+		validateHostSpec(&region.HostDefaults, path+".hostDefaults", result)
+
 		// Validate hosts
 		validateHosts(&region, path, result)
 	}
@@ -154,10 +289,15 @@ func validateHosts(region *RegionYaml, basePath string, result *ValidationResult
 		seenHostIds[hostId] = true
 
 		// Validate host ID format
-		if !validIdPattern.MatchString(hostId) {
-			result.AddError(path, "invalid host id format")
+		if msg := validateEntityId("host", hostId, host.Scale); msg != "" {
+			result.AddError(path, msg)
 		}
 
+		validateScope(&host.ScopeYaml, path, result)
+		validateHostSpec(&host.HostSpecYaml, path, result)
+		spec := host.HostSpecYaml.withDefaults(&region.HostDefaults)
+		validateEffectiveHostSpec(&host.HostSpecYaml, &spec, path, result)
+
 		// Validate components
 		validateComponents(&host, path, result)
 	}
@@ -173,77 +313,207 @@ func validateComponents(host *HostYaml, basePath string, result *ValidationResul
 		if comp.Type == "" {
 			result.AddError(path+".type", "component type is required")
 		} else {
-			if _, err := model.GetComponentType(comp.Type); err != nil {
+			if compType, err := model.GetComponentType(comp.Type); err != nil {
 				validTypes := model.ListComponentTypes()
 				result.AddError(path+".type", fmt.Sprintf("unknown component type '%s'. Valid types: %s",
 					comp.Type, strings.Join(validTypes, ", ")))
+			} else if err := decodeComponentConfig(compType, comp.Config); err != nil {
+				result.AddError(path+".config", err.Error())
 			}
 		}
 
 		// Validate component ID if specified
 		if comp.Id != "" {
-			if !validIdPattern.MatchString(comp.Id) {
-				result.AddError(path+".id", "invalid component id format")
+			if msg := validateEntityId("component", comp.Id, comp.Scale); msg != "" {
+				result.AddError(path+".id", msg)
 			}
 			if seenCompIds[comp.Id] {
 				result.AddError(path+".id", "duplicate component id within host")
 			}
 			seenCompIds[comp.Id] = true
 		}
+
+		for j, check := range comp.HealthChecks {
+			if err := check.Validate(); err != nil {
+				result.AddError(fmt.Sprintf("%s.healthChecks[%d]", path, j), err.Error())
+			}
+		}
+
+		validateScope(&comp.ScopeYaml, path, result)
 	}
 }
 
-// LoadModel creates a Model from a YAML configuration file
-func LoadModel(path string) (*model.Model, error) {
-	data, err := os.ReadFile(path)
+// LoadModel creates a Model from one or more YAML configuration files. Later files are
+// overlaid onto earlier ones, see ComposeConfig.
+func LoadModel(paths ...string) (*model.Model, error) {
+	config, err := LoadConfig(paths...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, err
 	}
+	return loadModel(config)
+}
 
-	var config FablabYaml
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+// MustLoadModel loads a model like LoadModel, exiting if it can't be loaded. It's meant for
+// instance executables, whose model is loaded before any command runs.
+func MustLoadModel(paths ...string) *model.Model {
+	m, err := LoadModel(paths...)
+	if err != nil {
+		logrus.Fatalf("unable to load model from %v (%v)", paths, err)
 	}
-
-	return buildModel(&config)
+	return m
 }
 
-// LoadModelFromBytes creates a Model from YAML bytes
+// LoadModelFromBytes creates a Model from YAML bytes. Includes and base files are resolved
+// relative to the working directory.
 func LoadModelFromBytes(data []byte) (*model.Model, error) {
+	config, err := loadConfigBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return loadModel(config)
+}
+
+// LoadConfig composes the given files into a single configuration.
+func LoadConfig(paths ...string) (*FablabYaml, error) {
+	doc, sources, err := composeConfig(paths...)
+	if err != nil {
+		return nil, err
+	}
+	return decodeConfig(doc, sources)
+}
+
+func loadConfigBytes(data []byte) (*FablabYaml, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	doc, sources, err := composeBytes(data, dir, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeConfig(doc, sources)
+}
+
+func decodeConfig(doc document, sources sourceMap) (*FablabYaml, error) {
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode merged configuration: %w", err)
+	}
+
+	positions := indexPositions(doc, sources)
+
 	var config FablabYaml
 	if err := yaml.Unmarshal(data, &config); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return nil, typeErrors(typeErr, data, positions)
+		}
 		return nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
+	config.positions = positions
+	return &config, nil
+}
+
+// typeErrors converts the errors of decoding the merged configuration, whose line numbers refer
+// to the re-encoded document, into ValidationErrors positioned in the source files.
+func typeErrors(typeErr *yaml.TypeError, data []byte, positions positionIndex) error {
+	linePaths := renderedLinePaths(data)
+
+	var errs []error
+	for _, msg := range typeErr.Errors {
+		var path string
+		if match := yamlErrorLine.FindStringSubmatch(msg); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			path = linePaths[line]
+		}
+		errs = append(errs, ValidationError{
+			Path:     path,
+			Message:  configLinePrefix.ReplaceAllString(msg, ""),
+			Position: positions.lookup(path),
+		})
+	}
+	return errors.Join(errs...)
+}
+
+func loadModel(config *FablabYaml) (*model.Model, error) {
+	result := &ValidationResult{positions: config.positions}
+	if interpolateConfig(config, result); !result.IsValid() {
+		var errs []error
+		for _, e := range result.Errors {
+			errs = append(errs, e)
+		}
+		return nil, errors.Join(errs...)
+	}
 
-	return buildModel(&config)
+	m, err := buildModel(config)
+	if err != nil {
+		return nil, err
+	}
+	clearSourcePaths(m)
+	return m, nil
 }
 
+// buildModel builds the model, recording the YAML path of each entity in its scope data so
+// validation issues found on the model can be reported against the configuration.
 func buildModel(config *FablabYaml) (*model.Model, error) {
 	m := &model.Model{
 		Id:      config.Model.Id,
+		Scope:   buildScope(&config.Model.ScopeYaml),
 		Regions: make(model.Regions),
 	}
 
 	for regionId, regionYaml := range config.Regions {
-		region, err := buildRegion(regionId, &regionYaml)
+		region, err := buildRegion(regionId, "regions."+regionId, &regionYaml)
 		if err != nil {
 			return nil, fmt.Errorf("region '%s': %w", regionId, err)
 		}
 		m.Regions[regionId] = region
 	}
 
+	if err := buildStages(config, m); err != nil {
+		return nil, err
+	}
+
+	m.Init()
+
+	if err := applyScaling(m); err != nil {
+		return nil, fmt.Errorf("scaling: %w", err)
+	}
+
 	return m, nil
 }
 
-func buildRegion(id string, config *RegionYaml) (*model.Region, error) {
+func buildScope(config *ScopeYaml) model.Scope {
+	scope := model.Scope{
+		Defaults: model.Variables{},
+		Data:     model.Data{},
+	}
+	scope.Tags = append(scope.Tags, config.Tags...)
+	for k, v := range config.Variables {
+		scope.Defaults[k] = v
+	}
+	for k, v := range config.Data {
+		scope.Data[k] = v
+	}
+	return scope
+}
+
+func buildRegion(id, path string, config *RegionYaml) (*model.Region, error) {
 	region := &model.Region{
-		Id:    id,
-		Site:  config.Site,
-		Hosts: make(model.Hosts),
+		Scope:  buildScope(&config.ScopeYaml),
+		Id:     id,
+		Region: config.Region,
+		Site:   config.Site,
+		Hosts:  make(model.Hosts),
 	}
+	if region.Region == "" {
+		region.Region = id
+	}
+	setScale(&region.Scope, config.Scale)
+	setSourcePath(&region.Scope, path)
 
 	for hostId, hostYaml := range config.Hosts {
-		host, err := buildHost(hostId, &hostYaml)
+		host, err := buildHost(hostId, path+".hosts."+hostId, &hostYaml, &config.HostDefaults)
 		if err != nil {
 			return nil, fmt.Errorf("host '%s': %w", hostId, err)
 		}
@@ -254,15 +524,19 @@ func buildRegion(id string, config *RegionYaml) (*model.Region, error) {
 	return region, nil
 }
 
-func buildHost(id string, config *HostYaml) (*model.Host, error) {
+func buildHost(id, path string, config *HostYaml, defaults *HostSpecYaml) (*model.Host, error) {
 	host := &model.Host{
-		Id:           id,
-		InstanceType: config.InstanceType,
-		Components:   make(model.Components),
+		Scope:      buildScope(&config.ScopeYaml),
+		Id:         id,
+		PublicIp:   config.PublicIp,
+		Components: make(model.Components),
 	}
+	config.HostSpecYaml.withDefaults(defaults).apply(host)
+	setScale(&host.Scope, config.Scale)
+	setSourcePath(&host.Scope, path)
 
 	for i, compYaml := range config.Components {
-		comp, err := buildComponent(i, &compYaml)
+		comp, err := buildComponent(i, fmt.Sprintf("%s.components[%d]", path, i), &compYaml)
 		if err != nil {
 			return nil, fmt.Errorf("component[%d]: %w", i, err)
 		}
@@ -272,21 +546,77 @@ func buildHost(id string, config *HostYaml) (*model.Host, error) {
 	return host, nil
 }
 
-func buildComponent(index int, config *ComponentYaml) (*model.Component, error) {
+func buildComponent(index int, path string, config *ComponentYaml) (*model.Component, error) {
 	// Get component type from registry
 	compType, err := model.GetComponentType(config.Type)
 	if err != nil {
 		return nil, fmt.Errorf("unknown component type '%s'", config.Type)
 	}
 
-	// Generate component ID if not specified
-	compId := config.Id
-	if compId == "" {
-		compId = fmt.Sprintf("%s-%d", config.Type, index)
+	// config referencing values only known after express is decoded once they're known
+	early := config.Config
+	var binder func(c *model.Component) error
+	if model.HasExpressions(config.Config) {
+		kept, _ := withoutExpressions(config.Config)
+		early = kept.(map[string]interface{})
+		binder = lateConfigBinder(config.Config)
+	}
+	if err := decodeComponentConfig(compType, early); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
 	}
 
-	return &model.Component{
-		Id:   compId,
-		Type: compType,
-	}, nil
+	component := &model.Component{
+		Scope:        buildScope(&config.ScopeYaml),
+		Id:           componentId(index, config),
+		Type:         compType,
+		DependsOn:    config.DependsOn,
+		HealthChecks: config.HealthChecks,
+		ConfigBinder: binder,
+	}
+	setScale(&component.Scope, config.Scale)
+	setSourcePath(&component.Scope, path)
+	return component, nil
 }
+
+// componentId returns the id of the component, generating one from its type and index if it
+// isn't specified.
+func componentId(index int, config *ComponentYaml) string {
+	if config.Id != "" {
+		return config.Id
+	}
+	compId := fmt.Sprintf("%s-%d", config.Type, index)
+	if config.Scale != nil && *config.Scale > 1 {
+		compId += "-{{ .ScaleIndex }}"
+	}
+	return compId
+}
+
+// decodeComponentConfig decodes a component config block into the concrete ComponentType
+// using its yaml tags. Fields the type doesn't declare are reported as errors.
+func decodeComponentConfig(compType model.ComponentType, config map[string]interface{}) error {
+	if len(config) == 0 {
+		return nil
+	}
+
+	data, err := yaml.Marshal(model.TypeResolvedStrings(config, reflect.TypeOf(compType)))
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	if err := yaml.UnmarshalStrict(data, compType); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			// line numbers refer to the re-encoded block rather than the source file, so drop them
+			var msgs []string
+			for _, msg := range typeErr.Errors {
+				msgs = append(msgs, configLinePrefix.ReplaceAllString(msg, ""))
+			}
+			return fmt.Errorf("invalid config for type '%s': %s", compType.Label(), strings.Join(msgs, "; "))
+		}
+		return fmt.Errorf("invalid config for type '%s': %w", compType.Label(), err)
+	}
+
+	return nil
+}
+
+var configLinePrefix = regexp.MustCompile(`^line \d+: `)
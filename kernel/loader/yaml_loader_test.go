@@ -1,8 +1,10 @@
 package loader
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/openziti/fablab/kernel/model"
@@ -128,6 +130,70 @@ regions:
 	}
 }
 
+func TestLoadModel_Scopes(t *testing.T) {
+	yaml := `
+model:
+  id: scope-test
+  variables:
+    environment: dev
+    credentials:
+      ssh:
+        username: ubuntu
+
+regions:
+  us-east-1:
+    tags: [primary]
+    variables:
+      environment: staging
+    hosts:
+      edge-1:
+        tags: [edge]
+        data:
+          rack: r1
+        components:
+          - type: ziti-router
+            id: router
+            tags: [edge, public]
+            variables:
+              listenPort: 3022
+      ctrl:
+        components:
+          - type: ziti-controller
+            id: ctrl
+`
+	m, err := LoadModelFromBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("LoadModelFromBytes failed: %v", err)
+	}
+
+	if hosts := m.SelectHosts(".edge"); len(hosts) != 1 || hosts[0].Id != "edge-1" {
+		t.Errorf("expected '.edge' to select host edge-1, got %v", hosts)
+	}
+	if components := m.SelectComponents("host.edge > .public"); len(components) != 1 || components[0].Id != "router" {
+		t.Errorf("expected 'host.edge > .public' to select router, got %v", components)
+	}
+	if components := m.SelectComponents("region.primary > *"); len(components) != 2 {
+		t.Errorf("expected 'region.primary > *' to select 2 components, got %d", len(components))
+	}
+
+	router := m.MustSelectComponent("router")
+	if val, _ := router.GetVariable("listenPort"); val != 3022 {
+		t.Errorf("expected component variable listenPort=3022, got %v", val)
+	}
+	if val, _ := router.GetVariable("environment"); val != "staging" {
+		t.Errorf("expected region variable to override model, got %v", val)
+	}
+	if val, _ := router.GetVariable("credentials.ssh.username"); val != "ubuntu" {
+		t.Errorf("expected nested model variable, got %v", val)
+	}
+	if val, _ := m.GetVariable("environment"); val != "dev" {
+		t.Errorf("expected model variable 'dev', got %v", val)
+	}
+	if rack := router.GetHost().Data["rack"]; rack != "r1" {
+		t.Errorf("expected host data rack=r1, got %v", rack)
+	}
+}
+
 func TestLoadModel_FileNotFound(t *testing.T) {
 	_, err := LoadModel("/nonexistent/path.yaml")
 	if err == nil {
@@ -304,3 +370,262 @@ regions:
 		t.Error("expected validation errors for duplicate component ids")
 	}
 }
+
+func TestValidateConfig_InvalidScope(t *testing.T) {
+	yaml := `
+model:
+  id: scope-test
+  variables:
+    credentials.ssh.username: ubuntu
+
+regions:
+  us-east-1:
+    hosts:
+      host1:
+        tags: ["bad tag"]
+        components:
+          - type: ziti-controller
+`
+	result, err := ValidateConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ValidateConfigBytes failed: %v", err)
+	}
+
+	paths := map[string]bool{}
+	for _, e := range result.Errors {
+		paths[e.Path] = true
+	}
+	if !paths["model.variables.credentials.ssh.username"] {
+		t.Errorf("expected error for dotted variable name, got %v", result.Errors)
+	}
+	if !paths["regions.us-east-1.hosts.host1.tags[0]"] {
+		t.Errorf("expected error for invalid tag, got %v", result.Errors)
+	}
+}
+
+func TestLoadModel_ComponentConfig(t *testing.T) {
+	yaml := `
+model:
+  id: config-test
+
+regions:
+  us-east-1:
+    hosts:
+      host1:
+        components:
+          - type: ziti-router
+            id: router
+            config:
+              version: v1.2.3
+              mode: fabric
+          - type: ziti-controller
+            id: ctrl
+`
+	m, err := LoadModelFromBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("LoadModelFromBytes failed: %v", err)
+	}
+
+	router, ok := m.MustSelectComponent("router").Type.(*model.ZitiRouterType)
+	if !ok {
+		t.Fatal("expected router to be a ZitiRouterType")
+	}
+	if router.Version != "v1.2.3" || router.Mode != "fabric" {
+		t.Errorf("expected version v1.2.3 and mode fabric, got %+v", router)
+	}
+
+	dump := m.Dump()
+	routerDump := dump.Regions["us-east-1"].Hosts["host1"].Components["router"].Type
+	if routerDump.(map[string]string)["mode"] != "fabric" {
+		t.Errorf("expected dump to include configured mode, got %v", routerDump)
+	}
+
+	// types without config keep their factory defaults
+	if m.MustSelectComponent("ctrl").Type.GetVersion() != "" {
+		t.Error("expected controller to keep default version")
+	}
+}
+
+func TestLoadModel_ComponentConfigUnknownField(t *testing.T) {
+	yaml := `
+model:
+  id: config-test
+
+regions:
+  us-east-1:
+    hosts:
+      host1:
+        components:
+          - type: ziti-router
+            config:
+              listener: tls:0.0.0.0:6262
+`
+	if _, err := LoadModelFromBytes([]byte(yaml)); err == nil {
+		t.Fatal("expected error for unknown config field")
+	}
+
+	result, err := ValidateConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ValidateConfigBytes failed: %v", err)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Path != "regions.us-east-1.hosts.host1.components[0].config" {
+		t.Fatalf("expected a single config error, got %v", result.Errors)
+	}
+	if !strings.Contains(result.Errors[0].Message, "listener") {
+		t.Errorf("expected error to name the unknown field, got %s", result.Errors[0].Message)
+	}
+}
+
+func TestLoadModel_Scale(t *testing.T) {
+	yaml := `
+model:
+  id: scale-test
+
+regions:
+  region-{{ .ScaleIndex }}:
+    scale: 2
+    hosts:
+      router-{{ .ScaleIndex }}:
+        scale: 25
+        tags: [edge]
+        components:
+          - type: ziti-router
+            id: router-{{ .Host.ScaleIndex }}-{{ .ScaleIndex }}
+            scale: 2
+      ctrl:
+        scale: 0
+        components:
+          - type: ziti-controller
+`
+	m, err := LoadModelFromBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("LoadModelFromBytes failed: %v", err)
+	}
+
+	if len(m.Regions) != 2 {
+		t.Fatalf("expected 2 regions, got %d", len(m.Regions))
+	}
+	if hosts := m.SelectHosts(".edge"); len(hosts) != 50 {
+		t.Errorf("expected 50 edge hosts, got %d", len(hosts))
+	}
+	if hosts := m.SelectHosts("ctrl"); len(hosts) != 0 {
+		t.Errorf("expected hosts scaled to 0 to be dropped, got %d", len(hosts))
+	}
+	if components := m.SelectComponents("*"); len(components) != 100 {
+		t.Errorf("expected 100 components, got %d", len(components))
+	}
+
+	host := m.MustSelectHost("region-1 > router-24")
+	if host.ScaleIndex != 24 {
+		t.Errorf("expected scale index 24, got %d", host.ScaleIndex)
+	}
+	if _, found := host.Components["router-24-1"]; !found {
+		t.Errorf("expected component router-24-1, got %v", host.Components)
+	}
+	if _, found := host.Data[scaleDataKey]; found {
+		t.Error("expected scale markers to be removed after scaling")
+	}
+}
+
+func TestLoadModel_ScaleFromLabel(t *testing.T) {
+	yaml := `
+model:
+  id: scale-test
+
+regions:
+  us-east-1:
+    hosts:
+      router-{{ .ScaleIndex }}:
+        scale: 3
+        variables:
+          name: node-{{ .Index }}
+        components:
+          - type: ziti-router
+`
+	dir := t.TempDir()
+	if err := (&model.Label{InstanceId: "scale-test", Model: "scale-test"}).SaveAtPath(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// each run loads the YAML and binds it to the label, as bootstrap does
+	bind := func() *model.Model {
+		m, err := LoadModelFromBytes([]byte(yaml))
+		if err != nil {
+			t.Fatalf("LoadModelFromBytes failed: %v", err)
+		}
+		l, err := model.LoadLabel(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.SeedIndexes(l)
+		if err = m.BindScales(l); err != nil {
+			t.Fatalf("BindScales failed: %v", err)
+		}
+		m.Init()
+		if err = m.BindIndexes(l); err != nil {
+			t.Fatal(err)
+		}
+		if err = l.Save(); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	if hosts := bind().SelectHosts("*"); len(hosts) != 3 {
+		t.Fatalf("expected the declared 3 hosts without a recorded scale, got %d", len(hosts))
+	}
+
+	l, err := model.LoadLabel(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Scales = map[string]uint32{"us-east-1.router-{{ .ScaleIndex }}": 5}
+	if err = l.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 0; run < 2; run++ {
+		m := bind()
+		hosts := m.SelectHosts("*")
+		if len(hosts) != 5 {
+			t.Fatalf("expected the recorded 5 hosts, got %d", len(hosts))
+		}
+		for _, host := range hosts {
+			if name := host.MustStringVariable("name"); name != fmt.Sprintf("node-%d", host.Index) {
+				t.Errorf("expected %s to be templated with index %d, got %s", host.Id, host.Index, name)
+			}
+			if _, found := host.Data[scaleDataKey]; found {
+				t.Errorf("expected scale markers to be removed from %s", host.Id)
+			}
+		}
+		if group := m.GetScaleGroup("us-east-1.router-{{ .ScaleIndex }}"); group == nil || group.Count() != 5 {
+			t.Errorf("expected the scale group to hold 5 hosts, got %v", group)
+		}
+	}
+}
+
+func TestValidateConfig_ScaleRequiresTemplatedId(t *testing.T) {
+	yaml := `
+model:
+  id: scale-test
+
+regions:
+  us-east-1:
+    hosts:
+      router:
+        scale: 3
+        components:
+          - type: ziti-router
+`
+	result, err := ValidateConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ValidateConfigBytes failed: %v", err)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Path != "regions.us-east-1.hosts.router" {
+		t.Fatalf("expected an error for the untemplated scaled host id, got %v", result.Errors)
+	}
+
+	if _, err := LoadModelFromBytes([]byte(yaml)); err == nil {
+		t.Fatal("expected error loading scaled host with untemplated id")
+	}
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/invopop/jsonschema"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/openziti/fablab/kernel/engine"
 	"github.com/openziti/fablab/kernel/loader"
 	"github.com/openziti/fablab/kernel/model"
 	"github.com/openziti/fablab/kernel/store"
+	"github.com/sirupsen/logrus"
 )
 
 // FablabMCPServer provides MCP interface for fablab infrastructure management.
@@ -18,6 +22,8 @@ type FablabMCPServer struct {
 	server     *server.MCPServer
 	store      store.ResourceStore
 	reconciler *engine.Reconciler
+	// bootstrap returns the context of the active instance, for tools operating on its hosts
+	bootstrap func() (*model.Context, error)
 }
 
 // NewFablabMCPServer creates a new MCP server with the given store.
@@ -33,6 +39,7 @@ func NewFablabMCPServer(s store.ResourceStore) *FablabMCPServer {
 		server:     srv,
 		store:      s,
 		reconciler: engine.NewReconciler(s),
+		bootstrap:  model.MustBootstrapContext,
 	}
 
 	fs.registerTools()
@@ -43,9 +50,41 @@ func NewFablabMCPServer(s store.ResourceStore) *FablabMCPServer {
 
 // ServeStdio starts the MCP server on stdio.
 func (fs *FablabMCPServer) ServeStdio() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := fs.watchResources(ctx); err != nil {
+		logrus.WithError(err).Warn("unable to watch store, resource update notifications disabled")
+	}
+
 	return server.ServeStdio(fs.server)
 }
 
+// watchResources forwards store changes to clients as resource updated notifications
+// for fablab://instances/{instance_id}.
+func (fs *FablabMCPServer) watchResources(ctx context.Context) error {
+	events, err := fs.store.Watch(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for event := range events {
+			fs.server.SendNotificationToAllClients(mcp.MethodNotificationResourceUpdated, map[string]any{
+				"uri": instanceURI(event.InstanceId),
+			})
+		}
+	}()
+
+	return nil
+}
+
+func instanceURI(instanceId string) string {
+	return instanceURIPrefix + instanceId
+}
+
+const instanceURIPrefix = "fablab://instances/"
+
 func (fs *FablabMCPServer) registerTools() {
 	// list_instances tool
 	listTool := mcp.NewTool("list_instances",
@@ -122,6 +161,34 @@ func (fs *FablabMCPServer) registerTools() {
 		),
 	)
 	fs.server.AddTool(diffTool, fs.getDiffHandler)
+
+	// get_schema tool
+	schemaTool := mcp.NewTool("get_schema",
+		mcp.WithDescription("Get the JSON Schema for YAML model configurations"),
+		mcp.WithString("component_type",
+			mcp.Description("Only return the config schema of this component type"),
+		),
+	)
+	fs.server.AddTool(schemaTool, fs.getSchemaHandler)
+
+	// get_logs tool
+	logsTool := mcp.NewTool("get_logs",
+		mcp.WithDescription("Get recent log lines of the components of the active instance"),
+		mcp.WithString("component_spec",
+			mcp.Description("Selector for the components, e.g. 'component.router'"),
+			mcp.Required(),
+		),
+		mcp.WithNumber("lines",
+			mcp.Description(fmt.Sprintf("Number of lines to read from the end of each log, defaults to %d", model.DefaultLogLines)),
+		),
+		mcp.WithString("grep",
+			mcp.Description("Only return lines matching this regular expression"),
+		),
+		mcp.WithString("since",
+			mcp.Description("Only return lines logged after this duration ago (e.g. 10m) or RFC 3339 time"),
+		),
+	)
+	fs.server.AddTool(logsTool, fs.getLogsHandler)
 }
 
 func (fs *FablabMCPServer) registerResources() {
@@ -140,6 +207,13 @@ func (fs *FablabMCPServer) registerResources() {
 		mcp.WithTemplateMIMEType("application/json"),
 	)
 	fs.server.AddResourceTemplate(instancesResource, fs.instanceHandler)
+
+	// Schema resource
+	schemaResource := mcp.NewResource("fablab://schema", "Fablab YAML Schema",
+		mcp.WithResourceDescription("JSON Schema for YAML model configurations and registered component types"),
+		mcp.WithMIMEType("application/schema+json"),
+	)
+	fs.server.AddResource(schemaResource, fs.schemaHandler)
 }
 
 // Tool Handlers
@@ -362,6 +436,70 @@ func (fs *FablabMCPServer) getDiffHandler(ctx context.Context, request mcp.CallT
 	return mcp.NewToolResultText(string(result)), nil
 }
 
+func (fs *FablabMCPServer) getLogsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	componentSpec, err := request.RequireString("component_spec")
+	if err != nil {
+		return mcp.NewToolResultError("component_spec is required"), nil
+	}
+	since, err := model.ParseLogSince(request.GetString("since", ""), time.Now())
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	options := model.LogOptions{
+		Lines: request.GetInt("lines", model.DefaultLogLines),
+		Grep:  request.GetString("grep", ""),
+		Since: since,
+	}
+
+	modelCtx, err := fs.bootstrap()
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("no active instance: %v", err)), nil
+	}
+	run, err := modelCtx.MustRun()
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to initialize run: %v", err)), nil
+	}
+
+	if _, err := model.ParseSelector(componentSpec); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	components := modelCtx.GetModel().SelectComponents(componentSpec)
+	if len(components) == 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("'%s' matched no components", componentSpec)), nil
+	}
+
+	lines := make([]string, 0)
+	err = model.StreamLogs(ctx, run, components, options, func(line model.LogLine) {
+		lines = append(lines, model.FormatLogLine(run, line))
+	})
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to read logs: %v", err)), nil
+	}
+
+	response := map[string]interface{}{
+		"component_spec": componentSpec,
+		"count":          len(lines),
+		"lines":          lines,
+	}
+	result, _ := json.MarshalIndent(response, "", "  ")
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func (fs *FablabMCPServer) getSchemaHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var schema *jsonschema.Schema
+	if componentType := request.GetString("component_type", ""); componentType != "" {
+		var err error
+		if schema, err = loader.ComponentTypeSchema(componentType); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to get schema: %v", err)), nil
+		}
+	} else {
+		schema = loader.GenerateSchema()
+	}
+
+	result, _ := json.MarshalIndent(schema, "", "  ")
+	return mcp.NewToolResultText(string(result)), nil
+}
+
 // Resource Handlers
 
 func (fs *FablabMCPServer) statusHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
@@ -386,7 +524,7 @@ func (fs *FablabMCPServer) statusHandler(ctx context.Context, request mcp.ReadRe
 
 func (fs *FablabMCPServer) instanceHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
 	// Extract instance_id from URI
-	instanceId := request.Params.URI[len("fablab://instances/"):]
+	instanceId := strings.TrimPrefix(request.Params.URI, instanceURIPrefix)
 
 	label, err := fs.store.GetStatus(instanceId)
 	if err != nil {
@@ -410,3 +548,18 @@ func (fs *FablabMCPServer) instanceHandler(ctx context.Context, request mcp.Read
 		},
 	}, nil
 }
+
+func (fs *FablabMCPServer) schemaHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	result, err := json.MarshalIndent(loader.GenerateSchema(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema: %w", err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      "fablab://schema",
+			MIMEType: "application/schema+json",
+			Text:     string(result),
+		},
+	}, nil
+}
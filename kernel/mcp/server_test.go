@@ -3,9 +3,12 @@ package mcp
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/openziti/fablab/kernel/model"
@@ -358,3 +361,93 @@ regions:
 		t.Errorf("expected at least 2 creates, got %d", createCount)
 	}
 }
+
+type testSession struct {
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *testSession) Initialize()       {}
+func (s *testSession) Initialized() bool { return true }
+func (s *testSession) SessionID() string { return "test-session" }
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func TestWatchResourcesNotifiesInstanceUpdates(t *testing.T) {
+	memStore := store.NewMemoryStore()
+	server := NewFablabMCPServer(memStore)
+
+	session := &testSession{notifications: make(chan mcp.JSONRPCNotification, 10)}
+	if err := server.server.RegisterSession(context.Background(), session); err != nil {
+		t.Fatalf("failed to register session: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := server.watchResources(ctx); err != nil {
+		t.Fatalf("watchResources failed: %v", err)
+	}
+
+	memStore.SaveResource("watched-instance", store.ResourceState{Id: "host-1", Type: "host"})
+
+	select {
+	case notification := <-session.notifications:
+		if notification.Method != mcp.MethodNotificationResourceUpdated {
+			t.Errorf("expected method %s, got %s", mcp.MethodNotificationResourceUpdated, notification.Method)
+		}
+		if uri := notification.Params.AdditionalFields["uri"]; uri != "fablab://instances/watched-instance" {
+			t.Errorf("unexpected uri %v", uri)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for resource updated notification")
+	}
+}
+
+func TestGetSchemaHandler(t *testing.T) {
+	server := NewFablabMCPServer(store.NewMemoryStore())
+
+	request := mcp.CallToolRequest{
+		Params: mcp.CallToolParams{
+			Arguments: map[string]any{
+				"component_type": "ziti-router",
+			},
+		},
+	}
+
+	result, err := server.getSchemaHandler(context.Background(), request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected error: %v", result.Content)
+	}
+
+	var response map[string]interface{}
+	json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &response)
+
+	if response["title"] != "ziti-router" {
+		t.Errorf("expected ziti-router schema, got %v", response["title"])
+	}
+}
+
+func TestGetLogsHandler_Errors(t *testing.T) {
+	server := NewFablabMCPServer(store.NewMemoryStore())
+	server.bootstrap = func() (*model.Context, error) {
+		return nil, errors.New("no label")
+	}
+
+	for expected, args := range map[string]map[string]any{
+		"component_spec is required":   {},
+		"invalid since 'yesterday'":    {"component_spec": "*", "since": "yesterday"},
+		"no active instance: no label": {"component_spec": "*", "since": "10m"},
+	} {
+		request := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: args}}
+		result, err := server.getLogsHandler(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.IsError || !strings.Contains(result.Content[0].(mcp.TextContent).Text, expected) {
+			t.Errorf("expected error containing %q, got %v", expected, result.Content)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package model
+
+import "sort"
+
+// entityAttributes lists the attributes EntityAttribute knows for each entity type.
+var entityAttributes = map[string][]string{
+	EntityTypeModel:     {"id"},
+	EntityTypeRegion:    {"id", "region", "site"},
+	EntityTypeHost:      {"id", "region", "instanceType", "instanceResourceType", "spotPrice", "spotType", "publicIp", "privateIp"},
+	EntityTypeComponent: {"id", "host", "type", "version"},
+}
+
+// EntityAttributeNames returns the attributes entities of the given type have, sorted.
+func EntityAttributeNames(entityType string) []string {
+	names := append([]string(nil), entityAttributes[entityType]...)
+	sort.Strings(names)
+	return names
+}
+
+// EntityAttribute returns the named attribute of the entity, as used by selector predicates
+// such as `host[instanceType=t3.micro]` and references such as `${hosts.ctrl.publicIp}`. It
+// returns false if entities of its type have no such attribute. Attributes may be empty until
+// the model has been expressed.
+func EntityAttribute(entity Entity, name string) (string, bool) {
+	if name == "id" {
+		return entity.GetId(), true
+	}
+
+	switch e := entity.(type) {
+	case *Region:
+		switch name {
+		case "region":
+			return e.Region, true
+		case "site":
+			return e.Site, true
+		}
+	case *Host:
+		switch name {
+		case "region":
+			if e.Region == nil {
+				return "", true
+			}
+			return e.Region.Id, true
+		case "instanceType":
+			return e.InstanceType, true
+		case "instanceResourceType":
+			return e.InstanceResourceType, true
+		case "spotPrice":
+			return e.SpotPrice, true
+		case "spotType":
+			return e.SpotType, true
+		case "publicIp":
+			return e.PublicIp, true
+		case "privateIp":
+			return e.PrivateIp, true
+		}
+	case *Component:
+		switch name {
+		case "host":
+			if e.Host == nil {
+				return "", true
+			}
+			return e.Host.Id, true
+		case "type":
+			if e.Type == nil {
+				return "", true
+			}
+			return e.Type.Label(), true
+		case "version":
+			if e.Type == nil {
+				return "", true
+			}
+			return e.Type.GetVersion(), true
+		}
+	}
+	return "", false
+}
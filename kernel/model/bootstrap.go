@@ -46,6 +46,12 @@ func Bootstrap() error {
 	}
 	model.VarConfig.BindingResolver.UpdateVariables(bindings)
 
+	secretResolvers, err := GetConfig().NewSecretResolvers()
+	if err != nil {
+		return errors.Wrap(err, "unable to bootstrap secret providers")
+	}
+	model.VarConfig.SecretResolver.UpdateResolvers(secretResolvers)
+
 	for _, ext := range bootstrapExtensions {
 		if err := ext.Bootstrap(model); err != nil {
 			return errors.Wrap(err, "unable to bootstrap extension")
@@ -86,6 +92,10 @@ func bootstrapModel() error {
 			return errors.Errorf("running model '%v' doesn't match project workspace model '%v'", model.GetId(), l.Model)
 		}
 
+		model.SeedIndexes(l)
+		if err := model.BindScales(l); err != nil {
+			return errors.Wrap(err, "unable to apply recorded scales")
+		}
 		for _, factory := range model.StructureFactories {
 			if err := factory.Build(model); err != nil {
 				return errors.Wrapf(err, "error executing factory [%s]", reflect.TypeOf(factory))
@@ -97,6 +107,14 @@ func bootstrapModel() error {
 
 		model.BindLabel(l)
 
+		if err := model.BindComponentConfigs(); err != nil {
+			return err
+		}
+
+		if err := model.BindIndexes(l); err != nil {
+			return errors.Wrap(err, "unable to record entity indexes")
+		}
+
 		for _, factory := range model.Factories {
 			if err := factory.Build(model); err != nil {
 				return errors.Wrapf(err, "error executing factory [%s]", reflect.TypeOf(factory))
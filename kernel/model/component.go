@@ -19,6 +19,7 @@ package model
 import (
 	"fmt"
 	"github.com/pkg/errors"
+	"reflect"
 	"sync/atomic"
 )
 
@@ -56,11 +57,26 @@ type VersionableComponent interface {
 	SetVersion(version string)
 }
 
+// A CloneableComponentType copies itself for each scaled clone of a component using it. Types
+// which don't implement it are shallow copied if they are pointers to structs, which is enough
+// unless they hold state, like maps, which setting the version of one clone would change in the
+// others.
+type CloneableComponentType interface {
+	ComponentType
+	CloneType() ComponentType
+}
+
 // A ServerComponent is one which can be started and left running in the background
 type ServerComponent interface {
 	Start(run Run, c *Component) error
 }
 
+// A DrainableComponent can stop taking on new work and hand off what it holds before it is
+// stopped for good, as when it is scaled away
+type DrainableComponent interface {
+	Drain(run Run, c *Component) error
+}
+
 // A FileStagingComponent is able to contribute files to the staging area, to be synced
 // up to the components host. This may include things like binaries, scripts, configuration
 // files and PKI.
@@ -125,26 +141,70 @@ type ActionsComponent interface {
 
 type Component struct {
 	Scope
-	Id          string
-	Host        *Host
-	Type        ComponentType
-	Index       uint32
-	ScaleIndex  uint32
-	initialized atomic.Bool
+	Id   string
+	Host *Host
+	Type ComponentType
+	// DependsOn holds selectors for the components which must be started before this one and
+	// stopped after it
+	DependsOn []string
+	// HealthChecks tell whether the component is ready, overriding those of its type
+	HealthChecks []HealthCheck
+	// ConfigBinder applies config referencing values only known after express, such as host IPs,
+	// to the component's type. It's set by model loaders, and run by BindConfig.
+	ConfigBinder func(c *Component) error
+	Index        uint32
+	ScaleIndex   uint32
+	initialized  atomic.Bool
 }
 
 func (component *Component) CloneComponent(scaleIndex uint32) *Component {
 	result := &Component{
-		Scope:      *component.CloneScope(),
-		Id:         component.Id,
-		Type:       component.Type,
-		Host:       component.Host,
-		Index:      component.GetModel().GetNextComponentIndex(),
-		ScaleIndex: scaleIndex,
+		Scope:        *component.CloneScope(),
+		Id:           component.Id,
+		Type:         cloneComponentType(component.Type),
+		DependsOn:    component.DependsOn,
+		HealthChecks: component.HealthChecks,
+		ConfigBinder: component.ConfigBinder,
+		Host:         component.Host,
+		Index:        component.GetModel().GetNextComponentIndex(),
+		ScaleIndex:   scaleIndex,
 	}
 	return result
 }
 
+// cloneComponentType copies the type of a component being scaled, so that each clone has its own
+// and changes to one, such as its version, don't apply to the others
+func cloneComponentType(t ComponentType) ComponentType {
+	if cloneable, ok := t.(CloneableComponentType); ok {
+		return cloneable.CloneType()
+	}
+	v := reflect.ValueOf(t)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return t
+	}
+	clone := reflect.New(v.Elem().Type())
+	clone.Elem().Set(v.Elem())
+	if result, ok := clone.Interface().(ComponentType); ok {
+		return result
+	}
+	return t
+}
+
+// BindConfig runs the component's ConfigBinder, if it has one. Unless required is set, config
+// referencing values which aren't known yet is left to be bound later.
+func (component *Component) BindConfig(required bool) error {
+	if component.ConfigBinder == nil {
+		return nil
+	}
+	if err := component.ConfigBinder(component); err != nil {
+		if !required && IsLateReference(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "unable to bind config of component [%s]", component.GetPath())
+	}
+	return nil
+}
+
 func (component *Component) init(id string, host *Host) {
 	if component.initialized.CompareAndSwap(false, true) {
 		component.Id = id
@@ -0,0 +1,232 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"strings"
+
+	"github.com/openziti/fablab/kernel/lib/util"
+	"github.com/pkg/errors"
+)
+
+// GetDependencies returns the components selected by the component's DependsOn specs, excluding
+// the component itself.
+func (component *Component) GetDependencies() ([]*Component, error) {
+	var result []*Component
+	seen := map[*Component]bool{component: true}
+	for _, spec := range component.DependsOn {
+		matcher, err := ParseSelector(spec)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid dependency of component '%s'", component.GetPathId())
+		}
+		for _, dependency := range component.GetModel().selectComponents(matcher) {
+			if !seen[dependency] {
+				seen[dependency] = true
+				result = append(result, dependency)
+			}
+		}
+	}
+	return result, nil
+}
+
+// ComponentPlan orders components by their dependencies. Only dependencies between the planned
+// components are considered, components outside the plan are assumed to be running already.
+type ComponentPlan struct {
+	components   []*Component
+	dependencies map[*Component][]*Component
+	dependents   map[*Component][]*Component
+}
+
+// PlanComponents creates a plan for the given components, returning a DependencyCycleError if
+// their dependencies form a cycle.
+func (m *Model) PlanComponents(components []*Component) (*ComponentPlan, error) {
+	plan := &ComponentPlan{
+		components:   components,
+		dependencies: map[*Component][]*Component{},
+		dependents:   map[*Component][]*Component{},
+	}
+
+	planned := map[*Component]bool{}
+	for _, c := range components {
+		planned[c] = true
+	}
+
+	for _, c := range components {
+		dependencies, err := c.GetDependencies()
+		if err != nil {
+			return nil, err
+		}
+		for _, dependency := range dependencies {
+			if planned[dependency] {
+				plan.dependencies[c] = append(plan.dependencies[c], dependency)
+				plan.dependents[dependency] = append(plan.dependents[dependency], c)
+			}
+		}
+	}
+
+	if cycle := plan.findCycle(); cycle != nil {
+		return nil, &DependencyCycleError{Components: cycle}
+	}
+	return plan, nil
+}
+
+// DependencyCycleError reports components depending on each other. Components starts and ends
+// with the same component.
+type DependencyCycleError struct {
+	Components []*Component
+}
+
+func (e *DependencyCycleError) Error() string {
+	var ids []string
+	for _, c := range e.Components {
+		ids = append(ids, c.GetPathId())
+	}
+	return "dependency cycle: " + strings.Join(ids, " -> ")
+}
+
+// findCycle returns the components of a dependency cycle, starting and ending with the same
+// component, or nil if there is none.
+func (p *ComponentPlan) findCycle() []*Component {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[*Component]int{}
+	var stack []*Component
+
+	var visit func(c *Component) []*Component
+	visit = func(c *Component) []*Component {
+		state[c] = visiting
+		stack = append(stack, c)
+		for _, dependency := range p.dependencies[c] {
+			switch state[dependency] {
+			case visiting:
+				for i, s := range stack {
+					if s == dependency {
+						return append(append([]*Component(nil), stack[i:]...), dependency)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dependency); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[c] = visited
+		return nil
+	}
+
+	for _, c := range p.components {
+		if state[c] == unvisited {
+			if cycle := visit(c); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// Execute calls f for each component once f has completed for all its dependencies, as used to
+// start components. Up to concurrency components are handled at once, so independent branches
+// proceed in parallel. After a failure no further components are started, and the errors of
+// those already running are returned once they complete.
+func (p *ComponentPlan) Execute(concurrency int, f func(c *Component) error) error {
+	return p.execute(concurrency, p.dependencies, p.dependents, f)
+}
+
+// ExecuteReverse is Execute with the order reversed, calling f for a component once it has
+// completed for all components depending on it, as used to stop components.
+func (p *ComponentPlan) ExecuteReverse(concurrency int, f func(c *Component) error) error {
+	return p.execute(concurrency, p.dependents, p.dependencies, f)
+}
+
+func (p *ComponentPlan) execute(concurrency int, prerequisites, followers map[*Component][]*Component, f func(c *Component) error) error {
+	if concurrency < 1 {
+		return errors.Errorf("invalid concurrency %v, must be at least 1", concurrency)
+	}
+
+	type result struct {
+		component *Component
+		err       error
+	}
+
+	waiting := map[*Component]int{}
+	var ready []*Component
+	for _, c := range p.components {
+		waiting[c] = len(prerequisites[c])
+		if waiting[c] == 0 {
+			ready = append(ready, c)
+		}
+	}
+
+	results := make(chan result)
+	running := 0
+	var errList []error
+	for {
+		for len(ready) > 0 && running < concurrency && len(errList) == 0 {
+			c := ready[0]
+			ready = ready[1:]
+			running++
+			go func() {
+				results <- result{component: c, err: f(c)}
+			}()
+		}
+		if running == 0 {
+			break
+		}
+
+		r := <-results
+		running--
+		if r.err != nil {
+			errList = append(errList, r.err)
+			continue
+		}
+		for _, follower := range followers[r.component] {
+			waiting[follower]--
+			if waiting[follower] == 0 {
+				ready = append(ready, follower)
+			}
+		}
+	}
+
+	if len(errList) == 1 {
+		return errList[0]
+	}
+	if len(errList) > 1 {
+		return util.MultipleErrors(errList)
+	}
+	return nil
+}
+
+// ForEachComponentByDependency calls f for the components matching spec, handling dependencies
+// before the components depending on them, or after them if reverse is set.
+func (m *Model) ForEachComponentByDependency(spec string, concurrency int, reverse bool, f func(c *Component) error) error {
+	matcher, err := ParseSelector(spec)
+	if err != nil {
+		return err
+	}
+	plan, err := m.PlanComponents(m.selectComponents(matcher))
+	if err != nil {
+		return err
+	}
+	if reverse {
+		return plan.ExecuteReverse(concurrency, f)
+	}
+	return plan.Execute(concurrency, f)
+}
@@ -0,0 +1,124 @@
+package model
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createDependencyTestModel() *Model {
+	m := &Model{
+		Id: "test",
+		Regions: Regions{
+			"region": {
+				Hosts: Hosts{
+					"ctrl": {
+						Components: Components{
+							"ctrl": {Scope: Scope{Tags: Tags{"ctrl"}}},
+						},
+					},
+					"routers": {
+						Components: Components{
+							"router1": {Scope: Scope{Tags: Tags{"router"}}, DependsOn: []string{"component.ctrl"}},
+							"router2": {Scope: Scope{Tags: Tags{"router"}}, DependsOn: []string{"component.ctrl"}},
+						},
+					},
+					"clients": {
+						Components: Components{
+							"client": {DependsOn: []string{"component.router", "#ctrl"}},
+							"loner":  {},
+						},
+					},
+				},
+			},
+		},
+	}
+	m.init()
+	return m
+}
+
+func TestComponentPlan_Order(t *testing.T) {
+	req := require.New(t)
+	m := createDependencyTestModel()
+
+	var order []string
+	req.NoError(m.ForEachComponentByDependency("*", 1, false, func(c *Component) error {
+		order = append(order, c.Id)
+		return nil
+	}))
+	req.Equal([]string{"loner", "ctrl", "router1", "router2", "client"}, order)
+
+	order = nil
+	req.NoError(m.ForEachComponentByDependency("*", 1, true, func(c *Component) error {
+		order = append(order, c.Id)
+		return nil
+	}))
+	req.Equal([]string{"client", "loner", "router1", "router2", "ctrl"}, order)
+
+	// dependencies outside the selection don't hold up the selected components
+	order = nil
+	req.NoError(m.ForEachComponentByDependency("#client,#router1", 1, false, func(c *Component) error {
+		order = append(order, c.Id)
+		return nil
+	}))
+	req.Equal([]string{"router1", "client"}, order)
+}
+
+func TestComponentPlan_Parallel(t *testing.T) {
+	req := require.New(t)
+	m := createDependencyTestModel()
+
+	var lock sync.Mutex
+	done := map[string]bool{}
+	err := m.ForEachComponentByDependency("*", 10, false, func(c *Component) error {
+		dependencies, err := c.GetDependencies()
+		req.NoError(err)
+
+		lock.Lock()
+		defer lock.Unlock()
+		for _, dependency := range dependencies {
+			req.True(done[dependency.Id], "%s started before %s", c.Id, dependency.Id)
+		}
+		done[c.Id] = true
+		return nil
+	})
+	req.NoError(err)
+	req.Equal(5, len(done))
+}
+
+func TestComponentPlan_Failure(t *testing.T) {
+	req := require.New(t)
+	m := createDependencyTestModel()
+
+	var started []string
+	err := m.ForEachComponentByDependency("*", 1, false, func(c *Component) error {
+		started = append(started, c.Id)
+		if c.Id == "ctrl" {
+			return errors.New("ctrl failed")
+		}
+		return nil
+	})
+	req.EqualError(err, "ctrl failed")
+	req.Equal([]string{"loner", "ctrl"}, started)
+}
+
+func TestComponentPlan_Cycle(t *testing.T) {
+	req := require.New(t)
+	m := createDependencyTestModel()
+	m.Regions["region"].Hosts["ctrl"].Components["ctrl"].DependsOn = []string{"#client"}
+
+	_, err := m.PlanComponents(m.SelectComponents("*"))
+	var cycleErr *DependencyCycleError
+	req.ErrorAs(err, &cycleErr)
+	req.Equal("dependency cycle: region.clients.client -> region.routers.router1 -> region.ctrl.ctrl -> region.clients.client", err.Error())
+
+	// a cycle outside the selection doesn't matter
+	_, err = m.PlanComponents(m.SelectComponents("#ctrl"))
+	req.NoError(err)
+
+	m.Regions["region"].Hosts["ctrl"].Components["ctrl"].DependsOn = []string{"hots.x"}
+	_, err = m.PlanComponents(m.SelectComponents("*"))
+	req.ErrorContains(err, "invalid dependency of component 'region.ctrl.ctrl'")
+}
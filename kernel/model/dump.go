@@ -65,7 +65,7 @@ func dumpVariables(s Scope, vs Variables, secret bool) map[string]interface{} {
 		if val, ok := v.(Variables); ok {
 			dump[kk] = dumpVariables(s, val, currentSecret)
 		} else if currentSecret {
-			dump[kk] = "**secret**"
+			dump[kk] = RedactedValue
 		} else {
 			dump[kk] = fmt.Sprintf("%v", val)
 		}
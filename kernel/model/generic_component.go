@@ -13,6 +13,10 @@ func (c *GenericComponent) GetVersion() string {
 	return c.Version
 }
 
+func (c *GenericComponent) SetVersion(version string) {
+	c.Version = version
+}
+
 func (c *GenericComponent) Dump() any {
 	return c
 }
@@ -0,0 +1,380 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	DefaultHealthCheckTimeout  = 5 * time.Second
+	DefaultHealthCheckInterval = time.Second
+	DefaultReadyTimeout        = time.Minute
+)
+
+// A HealthCheckingComponent declares the health checks telling whether its components are ready,
+// rather than relying on IsRunning. Checks declared on a component take precedence.
+type HealthCheckingComponent interface {
+	ComponentType
+
+	// GetHealthChecks returns the checks for the given component
+	GetHealthChecks(c *Component) []HealthCheck
+}
+
+// HealthCheck declares a probe of a component's health. Exactly one of TCP, HTTP, Exec and Log
+// must be set.
+type HealthCheck struct {
+	Name string     `yaml:"name,omitempty"`
+	TCP  *TCPProbe  `yaml:"tcp,omitempty" jsonschema_description:"Succeeds if the port accepts connections"`
+	HTTP *HTTPProbe `yaml:"http,omitempty" jsonschema_description:"Succeeds if the endpoint responds with the expected status and body"`
+	Exec *ExecProbe `yaml:"exec,omitempty" jsonschema_description:"Succeeds if the command exits with the expected code on the host"`
+	Log  *LogProbe  `yaml:"log,omitempty" jsonschema_description:"Succeeds if a line of the log file on the host matches"`
+
+	// Timeout bounds a single probe, defaulting to DefaultHealthCheckTimeout
+	Timeout time.Duration `yaml:"timeout,omitempty" jsonschema:"type=string"`
+	// Interval is the time between probes, defaulting to DefaultHealthCheckInterval
+	Interval time.Duration `yaml:"interval,omitempty" jsonschema:"type=string"`
+	// SuccessThreshold is the number of consecutive successes for a component to become ready
+	SuccessThreshold int `yaml:"successThreshold,omitempty"`
+	// FailureThreshold is the number of consecutive failures for a component to be unhealthy
+	FailureThreshold int `yaml:"failureThreshold,omitempty"`
+}
+
+// TCPProbe connects to Port on Host, which defaults to the public IP of the component's host.
+type TCPProbe struct {
+	Host string `yaml:"host,omitempty"`
+	Port uint16 `yaml:"port" jsonschema:"required"`
+}
+
+// HTTPProbe requests Path from Port on Host, which defaults to the public IP of the component's
+// host. It succeeds if the response has Status, 200 by default, and its body contains Body.
+type HTTPProbe struct {
+	Scheme             string `yaml:"scheme,omitempty" jsonschema:"enum=http,enum=https"`
+	Host               string `yaml:"host,omitempty"`
+	Port               uint16 `yaml:"port" jsonschema:"required"`
+	Path               string `yaml:"path,omitempty"`
+	Status             int    `yaml:"status,omitempty"`
+	Body               string `yaml:"body,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify,omitempty"`
+}
+
+// ExecProbe runs Cmd on the component's host, succeeding if it exits with ExitCode.
+type ExecProbe struct {
+	Cmd      string `yaml:"cmd" jsonschema:"required"`
+	ExitCode int    `yaml:"exitCode,omitempty"`
+}
+
+// LogProbe succeeds if a line of the file at Path on the component's host matches the regular
+// expression Match. Only the last Lines lines are searched if Lines is set.
+type LogProbe struct {
+	Path  string `yaml:"path" jsonschema:"required"`
+	Match string `yaml:"match" jsonschema:"required"`
+	Lines int    `yaml:"lines,omitempty"`
+}
+
+// Validate checks that exactly one probe is declared, with the settings it requires.
+func (hc *HealthCheck) Validate() error {
+	probes := 0
+	for _, set := range []bool{hc.TCP != nil, hc.HTTP != nil, hc.Exec != nil, hc.Log != nil} {
+		if set {
+			probes++
+		}
+	}
+	if probes != 1 {
+		return errors.Errorf("exactly one of tcp, http, exec or log must be declared, found %d", probes)
+	}
+	if hc.Timeout < 0 || hc.Interval < 0 || hc.SuccessThreshold < 0 || hc.FailureThreshold < 0 {
+		return errors.New("timeout, interval and thresholds may not be negative")
+	}
+
+	switch {
+	case hc.TCP != nil && hc.TCP.Port == 0:
+		return errors.New("tcp port is required")
+	case hc.HTTP != nil && hc.HTTP.Port == 0:
+		return errors.New("http port is required")
+	case hc.HTTP != nil && hc.HTTP.Scheme != "" && hc.HTTP.Scheme != "http" && hc.HTTP.Scheme != "https":
+		return errors.Errorf("invalid http scheme '%s', must be 'http' or 'https'", hc.HTTP.Scheme)
+	case hc.Exec != nil && hc.Exec.Cmd == "":
+		return errors.New("exec cmd is required")
+	case hc.Log != nil && (hc.Log.Path == "" || hc.Log.Match == ""):
+		return errors.New("log path and match are required")
+	case hc.Log != nil:
+		if _, err := regexp.Compile(hc.Log.Match); err != nil {
+			return errors.Wrapf(err, "invalid log match '%s'", hc.Log.Match)
+		}
+	}
+	return nil
+}
+
+// Describe returns the name of the check, or a summary of its probe if it has none.
+func (hc *HealthCheck) Describe(c *Component) string {
+	if hc.Name != "" {
+		return hc.Name
+	}
+	switch {
+	case hc.TCP != nil:
+		return "tcp " + hc.TCP.address(c)
+	case hc.HTTP != nil:
+		return "http " + hc.HTTP.url(c)
+	case hc.Exec != nil:
+		return "exec " + hc.Exec.Cmd
+	case hc.Log != nil:
+		return fmt.Sprintf("log %s ~ %s", hc.Log.Path, hc.Log.Match)
+	}
+	return "invalid"
+}
+
+// Probe runs the check once against the component, returning why it failed.
+func (hc *HealthCheck) Probe(c *Component) error {
+	timeout := durationOrDefault(hc.Timeout, DefaultHealthCheckTimeout)
+	switch {
+	case hc.TCP != nil:
+		return hc.TCP.probe(c, timeout)
+	case hc.HTTP != nil:
+		return hc.HTTP.probe(c, timeout)
+	case hc.Exec != nil:
+		return hc.Exec.probe(c, timeout)
+	case hc.Log != nil:
+		return hc.Log.probe(c, timeout)
+	}
+	return hc.Validate()
+}
+
+func (p *TCPProbe) address(c *Component) string {
+	return net.JoinHostPort(probeHost(p.Host, c), strconv.Itoa(int(p.Port)))
+}
+
+func (p *TCPProbe) probe(c *Component, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", p.address(c), timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (p *HTTPProbe) url(c *Component) string {
+	scheme := p.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	path := p.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(probeHost(p.Host, c), strconv.Itoa(int(p.Port))), path)
+}
+
+func (p *HTTPProbe) probe(c *Component, timeout time.Duration) error {
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: p.InsecureSkipVerify},
+		},
+	}
+	resp, err := client.Get(p.url(c))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	status := p.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if resp.StatusCode != status {
+		return errors.Errorf("expected status %d, got %d", status, resp.StatusCode)
+	}
+	if p.Body != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(string(body), p.Body) {
+			return errors.Errorf("response body doesn't contain '%s'", p.Body)
+		}
+	}
+	return nil
+}
+
+func (p *ExecProbe) probe(c *Component, timeout time.Duration) error {
+	output, err := c.GetHost().ExecLoggedWithTimeout(timeout, p.Cmd)
+	exitCode := 0
+	if err != nil {
+		var exitErr *ssh.ExitError
+		if !errors.As(err, &exitErr) {
+			return err
+		}
+		exitCode = exitErr.ExitStatus()
+	}
+	if exitCode != p.ExitCode {
+		return errors.Errorf("expected exit code %d, got %d: %s", p.ExitCode, exitCode, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+func (p *LogProbe) probe(c *Component, timeout time.Duration) error {
+	cmd := fmt.Sprintf("grep -E -q -e %s %s", shellQuote(p.Match), shellQuote(p.Path))
+	if p.Lines > 0 {
+		cmd = fmt.Sprintf("tail -n %d %s | grep -E -q -e %s", p.Lines, shellQuote(p.Path), shellQuote(p.Match))
+	}
+	if _, err := c.GetHost().ExecLoggedWithTimeout(timeout, cmd); err != nil {
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			return errors.Errorf("no line of %s matches '%s'", p.Path, p.Match)
+		}
+		return err
+	}
+	return nil
+}
+
+func probeHost(host string, c *Component) string {
+	if host != "" {
+		return host
+	}
+	return c.GetHost().PublicIp
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func durationOrDefault(d, defaultValue time.Duration) time.Duration {
+	if d <= 0 {
+		return defaultValue
+	}
+	return d
+}
+
+func thresholdOrDefault(threshold int) int {
+	if threshold <= 0 {
+		return 1
+	}
+	return threshold
+}
+
+// GetHealthChecks returns the health checks declared on the component, or else those of its
+// type if it is a HealthCheckingComponent.
+func (component *Component) GetHealthChecks() []HealthCheck {
+	if len(component.HealthChecks) > 0 {
+		return component.HealthChecks
+	}
+	if hc, ok := component.Type.(HealthCheckingComponent); ok {
+		return hc.GetHealthChecks(component)
+	}
+	return nil
+}
+
+// HealthResult is the outcome of a health check of a component.
+type HealthResult struct {
+	Component *Component
+	Check     string
+	Healthy   bool
+	Attempts  int
+	Err       error
+}
+
+// CheckHealth runs each health check of the component, retrying failed probes until they fail
+// FailureThreshold times in a row. Components without health checks are healthy if they are
+// running.
+func (component *Component) CheckHealth(run Run) []HealthResult {
+	checks := component.GetHealthChecks()
+	if len(checks) == 0 {
+		running, err := component.IsRunning(run)
+		if err == nil && !running {
+			err = errors.New("not running")
+		}
+		return []HealthResult{{Component: component, Check: "running", Healthy: err == nil, Attempts: 1, Err: err}}
+	}
+
+	var results []HealthResult
+	for i := range checks {
+		check := &checks[i]
+		result := HealthResult{Component: component, Check: check.Describe(component)}
+		for result.Attempts < thresholdOrDefault(check.FailureThreshold) {
+			if result.Attempts > 0 {
+				time.Sleep(durationOrDefault(check.Interval, DefaultHealthCheckInterval))
+			}
+			result.Attempts++
+			if result.Err = check.Probe(component); result.Err == nil {
+				break
+			}
+		}
+		result.Healthy = result.Err == nil
+		results = append(results, result)
+	}
+	return results
+}
+
+// WaitReady waits until every health check of the component has succeeded SuccessThreshold
+// times in a row, or the component is running if it has none, returning an error once timeout
+// has passed.
+func (component *Component) WaitReady(run Run, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	log := logrus.WithField("componentId", component.Id)
+
+	checks := component.GetHealthChecks()
+	if len(checks) == 0 {
+		for {
+			running, err := component.IsRunning(run)
+			if err != nil {
+				return errors.Wrapf(err, "unable to check status of component '%s'", component.GetPathId())
+			}
+			if running {
+				return nil
+			}
+			if time.Now().After(deadline) {
+				return errors.Errorf("timed out waiting for component '%s' to be running", component.GetPathId())
+			}
+			log.Info("component not running yet, waiting 1 second")
+			time.Sleep(DefaultHealthCheckInterval)
+		}
+	}
+
+	for i := range checks {
+		check := &checks[i]
+		successes := 0
+		for successes < thresholdOrDefault(check.SuccessThreshold) {
+			if err := check.Probe(component); err != nil {
+				successes = 0
+				if time.Now().After(deadline) {
+					return errors.Wrapf(err, "timed out waiting for component '%s' to pass '%s'",
+						component.GetPathId(), check.Describe(component))
+				}
+				log.Infof("component not ready yet, '%s' failed: %v", check.Describe(component), err)
+			} else {
+				successes++
+				if successes >= thresholdOrDefault(check.SuccessThreshold) {
+					break
+				}
+			}
+			time.Sleep(durationOrDefault(check.Interval, DefaultHealthCheckInterval))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createHealthTestComponent(checks ...HealthCheck) *Component {
+	m := &Model{
+		Id: "test",
+		Regions: Regions{
+			"region": {
+				Hosts: Hosts{
+					"host": {
+						PublicIp: "127.0.0.1",
+						Components: Components{
+							"server": {Type: &GenericComponent{Type: "generic"}, HealthChecks: checks},
+						},
+					},
+				},
+			},
+		},
+	}
+	m.init()
+	return m.Regions["region"].Hosts["host"].Components["server"]
+}
+
+func TestHealthCheck_Validate(t *testing.T) {
+	req := require.New(t)
+
+	req.NoError((&HealthCheck{TCP: &TCPProbe{Port: 80}}).Validate())
+	req.ErrorContains((&HealthCheck{}).Validate(), "found 0")
+	req.ErrorContains((&HealthCheck{TCP: &TCPProbe{Port: 80}, Exec: &ExecProbe{Cmd: "true"}}).Validate(), "found 2")
+	req.ErrorContains((&HealthCheck{TCP: &TCPProbe{}}).Validate(), "tcp port is required")
+	req.ErrorContains((&HealthCheck{HTTP: &HTTPProbe{Port: 80, Scheme: "ftp"}}).Validate(), "invalid http scheme 'ftp'")
+	req.ErrorContains((&HealthCheck{Log: &LogProbe{Path: "app.log", Match: "("}}).Validate(), "invalid log match")
+	req.ErrorContains((&HealthCheck{Exec: &ExecProbe{Cmd: "true"}, Interval: -time.Second}).Validate(), "may not be negative")
+}
+
+func TestHealthCheck_TCP(t *testing.T) {
+	req := require.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	req.NoError(err)
+	port := uint16(listener.Addr().(*net.TCPAddr).Port)
+
+	c := createHealthTestComponent(HealthCheck{TCP: &TCPProbe{Port: port}})
+	results := c.CheckHealth(nil)
+	req.Equal(1, len(results))
+	req.True(results[0].Healthy, "%v", results[0].Err)
+	req.Equal("tcp 127.0.0.1:"+strconv.Itoa(int(port)), results[0].Check)
+	req.NoError(c.WaitReady(nil, time.Second))
+
+	req.NoError(listener.Close())
+	c.HealthChecks[0].FailureThreshold = 2
+	c.HealthChecks[0].Interval = time.Millisecond
+	results = c.CheckHealth(nil)
+	req.False(results[0].Healthy)
+	req.Equal(2, results[0].Attempts)
+	req.ErrorContains(c.WaitReady(nil, 10*time.Millisecond), "timed out waiting for component 'region.host.server'")
+}
+
+func TestHealthCheck_HTTP(t *testing.T) {
+	req := require.New(t)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/health" || calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+	port := uint16(server.Listener.Addr().(*net.TCPAddr).Port)
+
+	check := HealthCheck{
+		Name:             "api",
+		HTTP:             &HTTPProbe{Port: port, Path: "health", Body: `"ok"`},
+		Interval:         time.Millisecond,
+		SuccessThreshold: 2,
+	}
+	c := createHealthTestComponent(check)
+	req.NoError(c.WaitReady(nil, time.Second))
+	req.Equal(4, calls)
+
+	c.HealthChecks[0].HTTP.Body = "ready"
+	results := c.CheckHealth(nil)
+	req.Equal("api", results[0].Check)
+	req.ErrorContains(results[0].Err, "response body doesn't contain 'ready'")
+}
+
+func TestHealthCheck_FallsBackToIsRunning(t *testing.T) {
+	req := require.New(t)
+
+	c := createHealthTestComponent()
+	results := c.CheckHealth(nil)
+	req.Equal("running", results[0].Check)
+	req.False(results[0].Healthy)
+
+	// the built-in types have no default checks, as their ports may not be reachable from here
+	c.Type = &ZitiControllerType{}
+	req.Empty(c.GetHealthChecks())
+
+	c.HealthChecks = []HealthCheck{{Exec: &ExecProbe{Cmd: "true"}}}
+	req.Equal("exec true", c.GetHealthChecks()[0].Describe(c))
+}
@@ -0,0 +1,228 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"sort"
+)
+
+// EntityIndexes records the indexes allocated to regions, hosts and components, keyed by entity
+// path, so that an entity keeps its index across runs however the model is scaled or reordered.
+type EntityIndexes struct {
+	Regions    map[string]uint32 `yaml:"regions,omitempty"`
+	Hosts      map[string]uint32 `yaml:"hosts,omitempty"`
+	Components map[string]uint32 `yaml:"components,omitempty"`
+}
+
+// BindIndexes gives each region, host and component the index recorded for its path in the label.
+// Entities seen for the first time keep the index they have if no other entity holds it, as it
+// may have been rendered into their templates, and are otherwise allocated the lowest free index.
+// Entries for entities no longer in the model are released. The label is saved if its indexes
+// changed. It is called during bootstrap, and should be called again when the structure of the
+// model changes.
+func (m *Model) BindIndexes(l *Label) error {
+	if l.Indexes == nil {
+		l.Indexes = &EntityIndexes{}
+	}
+	indexes := l.Indexes
+	regions, hosts, components := m.indexedEntities()
+
+	changed := false
+	indexes.Regions, m.regionIds = bindIndexes(indexes.Regions, regions, &changed)
+	indexes.Hosts, m.hostIds = bindIndexes(indexes.Hosts, hosts, &changed)
+	indexes.Components, m.componentIds = bindIndexes(indexes.Components, components, &changed)
+	m.indexes = indexes
+
+	if changed && l.path != "" {
+		return l.Save()
+	}
+	return nil
+}
+
+// SeedIndexes gives the entities in the model the indexes recorded for them in the label, and
+// makes indexes allocated afterwards avoid every recorded index. Scaled entities created by the
+// default scale entity factory from then on take their recorded index before they are
+// templated, so that templates using .Index render the index the entity keeps. It is called
+// during bootstrap before the structure factories run, and leaves the label unchanged;
+// BindIndexes records the outcome.
+func (m *Model) SeedIndexes(l *Label) {
+	if l.Indexes == nil {
+		l.Indexes = &EntityIndexes{}
+	}
+	regions, hosts, components := m.indexedEntities()
+	m.regionIds = seedIndexes(l.Indexes.Regions, regions)
+	m.hostIds = seedIndexes(l.Indexes.Hosts, hosts)
+	m.componentIds = seedIndexes(l.Indexes.Components, components)
+	m.indexes = l.Indexes
+}
+
+// claimRecordedIndex gives a newly scaled entity the index recorded for its path, if there is
+// one, returning the index it was allocated to the pool.
+func (m *Model) claimRecordedIndex(entity Entity) {
+	if m.indexes == nil {
+		return
+	}
+	var recorded map[string]uint32
+	var pool *IdPool
+	var index *uint32
+	switch e := entity.(type) {
+	case *Region:
+		recorded, pool, index = m.indexes.Regions, &m.regionIds, &e.Index
+	case *Host:
+		recorded, pool, index = m.indexes.Hosts, &m.hostIds, &e.Index
+	case *Component:
+		recorded, pool, index = m.indexes.Components, &m.componentIds, &e.Index
+	default:
+		return
+	}
+	if claimed, found := recorded[entityPath(entity)]; found && claimed > 0 && claimed != *index {
+		if *index > 0 {
+			pool.ReturnId(*index)
+		}
+		*index = claimed
+	}
+}
+
+// indexedEntities returns the regions, hosts and components of the model, in path order.
+func (m *Model) indexedEntities() (regions, hosts, components []indexedEntity) {
+	m.RangeSortedRegions(func(regionId string, region *Region) {
+		regions = append(regions, indexedEntity{path: regionId, index: &region.Index})
+		region.RangeSortedHosts(func(hostId string, host *Host) {
+			hostPath := regionId + "." + hostId
+			hosts = append(hosts, indexedEntity{path: hostPath, index: &host.Index})
+			host.RangeSortedComponents(func(componentId string, component *Component) {
+				components = append(components, indexedEntity{path: hostPath + "." + componentId, index: &component.Index})
+			})
+		})
+	})
+	return
+}
+
+type indexedEntity struct {
+	path  string
+	index *uint32
+}
+
+// bindIndexes assigns the recorded indexes to the entities, in path order, returning the updated
+// record and a pool which allocates around the indexes in use.
+func bindIndexes(recorded map[string]uint32, entities []indexedEntity, changed *bool) (map[string]uint32, IdPool) {
+	result := map[string]uint32{}
+	used := map[uint32]bool{}
+	var unassigned []indexedEntity
+
+	for _, entity := range entities {
+		if index, found := recorded[entity.path]; found && index > 0 && !used[index] {
+			*entity.index = index
+			result[entity.path] = index
+			used[index] = true
+		} else {
+			unassigned = append(unassigned, entity)
+		}
+	}
+
+	var unallocated []indexedEntity
+	for _, entity := range unassigned {
+		if index := *entity.index; index > 0 && !used[index] {
+			result[entity.path] = index
+			used[index] = true
+		} else {
+			unallocated = append(unallocated, entity)
+		}
+	}
+
+	pool := newIdPool(used)
+	for _, entity := range unallocated {
+		*entity.index = pool.GetNextId()
+		result[entity.path] = *entity.index
+	}
+
+	if len(result) != len(recorded) {
+		*changed = true
+	} else {
+		for path, index := range result {
+			if recorded[path] != index {
+				*changed = true
+				break
+			}
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, pool
+	}
+	return result, pool
+}
+
+// seedIndexes assigns the recorded indexes to the entities, returning a pool which allocates
+// around all the recorded indexes, including those of entities yet to be created.
+func seedIndexes(recorded map[string]uint32, entities []indexedEntity) IdPool {
+	used := map[uint32]bool{}
+	for _, index := range recorded {
+		if index > 0 {
+			used[index] = true
+		}
+	}
+	for _, entity := range entities {
+		*entity.index = 0
+		if index, found := recorded[entity.path]; found {
+			*entity.index = index
+		}
+	}
+	pool := newIdPool(used)
+	for _, entity := range entities {
+		if *entity.index == 0 {
+			*entity.index = pool.GetNextId()
+		}
+	}
+	return pool
+}
+
+// newIdPool creates a pool which hands out the gaps between the used ids, lowest first, before
+// any ids after them.
+func newIdPool(used map[uint32]bool) IdPool {
+	var ids []uint32
+	for id := range used {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	pool := IdPool{}
+	if len(ids) > 0 {
+		pool.nextId = ids[len(ids)-1]
+	}
+	for id := uint32(1); id < pool.nextId; id++ {
+		if !used[id] {
+			pool.returnedIds = append(pool.returnedIds, id)
+		}
+	}
+	return pool
+}
+
+// releaseIndex forgets the recorded index of a removed entity, so that it may be reused.
+func (m *Model) releaseIndex(entityType, path string) {
+	if m.indexes == nil {
+		return
+	}
+	switch entityType {
+	case EntityTypeRegion:
+		delete(m.indexes.Regions, path)
+	case EntityTypeHost:
+		delete(m.indexes.Hosts, path)
+	case EntityTypeComponent:
+		delete(m.indexes.Components, path)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newIndexTestModel(hostIds ...string) *Model {
+	hosts := Hosts{}
+	for _, hostId := range hostIds {
+		hosts[hostId] = &Host{Components: Components{"c": {}}}
+	}
+	m := &Model{Id: "test", Regions: Regions{"region1": {Hosts: hosts}}}
+	m.init()
+	return m
+}
+
+func hostIndexes(m *Model) map[string]uint32 {
+	result := map[string]uint32{}
+	for id, host := range m.Regions["region1"].Hosts {
+		result[id] = host.Index
+	}
+	return result
+}
+
+func TestModel_BindIndexes(t *testing.T) {
+	req := require.New(t)
+	dir := t.TempDir()
+	l := &Label{InstanceId: "test", Model: "test", Bindings: Variables{}, path: dir}
+
+	m := newIndexTestModel("b", "c", "d")
+	req.NoError(m.BindIndexes(l))
+	req.Equal(map[string]uint32{"b": 1, "c": 2, "d": 3}, hostIndexes(m))
+	req.Equal(map[string]uint32{"region1": 1}, l.Indexes.Regions)
+	req.Equal(uint32(2), l.Indexes.Components["region1.c.c"])
+
+	// a new host sorting first, and a removed one, leave the others where they were
+	l, err := LoadLabel(dir)
+	req.NoError(err)
+	m = newIndexTestModel("a", "b", "d")
+	req.NoError(m.BindIndexes(l))
+	req.Equal(map[string]uint32{"a": 2, "b": 1, "d": 3}, hostIndexes(m))
+	req.Equal(map[string]uint32{"region1.a": 2, "region1.b": 1, "region1.d": 3}, l.Indexes.Hosts)
+
+	// indexes allocated afterwards avoid those in use
+	req.Equal(uint32(4), m.GetNextHostIndex())
+
+	m.Regions["region1"].RemoveHost(m.Regions["region1"].Hosts["b"])
+	req.NotContains(l.Indexes.Hosts, "region1.b")
+	req.Equal(uint32(1), m.GetNextHostIndex())
+}
+
+func TestModel_BindIndexesWithoutChanges(t *testing.T) {
+	req := require.New(t)
+	l := &Label{Indexes: &EntityIndexes{Hosts: map[string]uint32{"region1.x": 7, "region1.y": 7}}}
+
+	m := newIndexTestModel("x", "y")
+	req.NoError(m.BindIndexes(l))
+	req.Equal(map[string]uint32{"x": 7, "y": 2}, hostIndexes(m), "duplicate indexes should be reallocated, keeping the index y had")
+
+	req.NoError(m.BindIndexes(l))
+	req.Equal(map[string]uint32{"x": 7, "y": 2}, hostIndexes(m))
+}
+
+func newScaledIndexTestModel(t *testing.T, l *Label) *Model {
+	m := &Model{
+		Id: "test",
+		Regions: Regions{
+			"region1": {
+				Hosts: Hosts{
+					"static": {},
+					"host-{{ .ScaleIndex }}": {
+						Scope: Scope{Tags: Tags{"scaled"}, Defaults: Variables{"name": "node-{{ .Index }}"}},
+					},
+				},
+			},
+		},
+	}
+	m.init()
+	m.SeedIndexes(l)
+	require.NoError(t, NewScaleFactoryWithDefaultEntityFactory(testScaleStrategy{}).Build(m))
+	m.init()
+	require.NoError(t, m.BindIndexes(l))
+	return m
+}
+
+func TestModel_SeedIndexesTemplatesRecordedIndexes(t *testing.T) {
+	req := require.New(t)
+	dir := t.TempDir()
+	l := &Label{InstanceId: "test", Model: "test", Bindings: Variables{}, path: dir}
+
+	assertTemplatedIndexes := func(m *Model) {
+		for _, host := range m.SelectHosts("host") {
+			if host.Id == "static" {
+				continue
+			}
+			req.Equal(fmt.Sprintf("node-%d", host.Index), host.MustStringVariable("name"), host.Id)
+		}
+	}
+
+	m := newScaledIndexTestModel(t, l)
+	assertTemplatedIndexes(m)
+
+	// indexes recorded out of order, as after scaling down and up again, are rendered as recorded
+	l, err := LoadLabel(dir)
+	req.NoError(err)
+	l.Indexes.Hosts["region1.host-0"] = 9
+	l.Indexes.Hosts["region1.host-2"] = 1
+	l.Indexes.Hosts["region1.static"] = 4
+	m = newScaledIndexTestModel(t, l)
+	req.Equal(uint32(9), m.MustSelectHost("#host-0").Index)
+	req.Equal(uint32(1), m.MustSelectHost("#host-2").Index)
+	req.Equal(uint32(4), m.MustSelectHost("#static").Index)
+	assertTemplatedIndexes(m)
+	req.Equal("node-9", m.MustSelectHost("#host-0").MustStringVariable("name"))
+}
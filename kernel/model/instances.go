@@ -18,6 +18,7 @@ var CliInstanceId string
 type FablabConfig struct {
 	Instances  map[string]*InstanceConfig `yaml:"instances"`
 	Default    string                     `yaml:"default"`
+	Secrets    []*SecretProviderConfig    `yaml:"secrets,omitempty"`
 	ConfigPath string                     `yaml:"-"`
 }
 
@@ -0,0 +1,396 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// An Expression is a string containing `${...}` references, which is interpolated each time it is
+// read through Scope.GetVariable. This gives late binding to values which are only known after
+// express, such as host IPs. Component config and stage parameters referencing such values are
+// kept as Expressions too, and resolved by ResolveTypedExpressions once the values are known.
+//
+// References take the form `namespace.name`, optionally followed by `:-default`:
+//
+//	${env.NAME}                          environment variable
+//	${args.NAME}                         command line variable (-VNAME=value)
+//	${bindings.NAME}                     label or global binding
+//	${var.NAME}                          variable, resolved from the entity being interpolated
+//	${model.variables.NAME}              model variable
+//	${regions.ID.ATTR}                   region attribute, e.g. site, or variables.NAME
+//	${hosts.ID.ATTR}                     host attribute, e.g. publicIp, or variables.NAME
+//	${components.ID.ATTR}                component attribute, e.g. version, or variables.NAME
+//
+// The attributes of each entity type are those of EntityAttribute.
+//
+// `$${` produces a literal `${`.
+type Expression string
+
+// maxInterpolationDepth limits how deeply expressions may reference other expressions, which
+// guards against reference cycles
+const maxInterpolationDepth = 16
+
+// IsExpression returns true if the value contains `${...}` references or `$${` escapes, and so
+// needs to be interpolated.
+func IsExpression(value string) bool {
+	return strings.Contains(value, "${")
+}
+
+// UnresolvedReferenceError is returned when an expression references a value which doesn't exist,
+// or isn't known yet.
+type UnresolvedReferenceError struct {
+	Reference string
+	Reason    string
+	// Late is set when the referenced value exists, but is only known after express
+	Late bool
+}
+
+func (e *UnresolvedReferenceError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("unable to resolve '${%s}': %s", e.Reference, e.Reason)
+	}
+	return fmt.Sprintf("unable to resolve '${%s}'", e.Reference)
+}
+
+// Interpolate replaces the references in value, resolved relative to entity. A value consisting
+// of a single reference evaluates to the referenced value, which may not be a string.
+func Interpolate(entity Entity, value string) (interface{}, error) {
+	return interpolate(entity, value, 0)
+}
+
+// InterpolateString is Interpolate, formatting the result as a string.
+func InterpolateString(entity Entity, value string) (string, error) {
+	result, err := Interpolate(entity, value)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := result.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(result), nil
+}
+
+func interpolate(entity Entity, value string, depth int) (interface{}, error) {
+	if depth > maxInterpolationDepth {
+		return nil, &UnresolvedReferenceError{Reference: value, Reason: "references are nested too deeply, check for cycles"}
+	}
+
+	var result strings.Builder
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			result.WriteString(value)
+			break
+		}
+		if start > 0 && value[start-1] == '$' {
+			result.WriteString(value[:start-1])
+			result.WriteString("${")
+			value = value[start+2:]
+			continue
+		}
+
+		end := matchingBrace(value, start+2)
+		if end < 0 {
+			return nil, &UnresolvedReferenceError{Reference: value[start+2:], Reason: "missing closing '}'"}
+		}
+
+		val, err := resolveReference(entity, value[start+2:end], depth)
+		if err != nil {
+			return nil, err
+		}
+
+		// a value consisting of a single reference keeps the type of the referenced value
+		if start == 0 && end == len(value)-1 && result.Len() == 0 {
+			return val, nil
+		}
+		result.WriteString(value[:start])
+		result.WriteString(fmt.Sprint(val))
+		value = value[end+1:]
+	}
+	return result.String(), nil
+}
+
+// matchingBrace returns the index of the '}' closing the reference starting at offset, allowing
+// for references nested in defaults, or -1 if there is none
+func matchingBrace(value string, offset int) int {
+	depth := 0
+	for i := offset; i < len(value); i++ {
+		switch {
+		case strings.HasPrefix(value[i:], "${"):
+			depth++
+			i++
+		case value[i] == '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+func resolveReference(entity Entity, ref string, depth int) (interface{}, error) {
+	name, defaultValue, hasDefault := strings.Cut(ref, ":-")
+	name = strings.TrimSpace(name)
+
+	val, err := lookupReference(entity, name)
+	if err == nil {
+		switch v := val.(type) {
+		case Expression:
+			val, err = interpolate(entity, string(v), depth+1)
+		case string:
+			if IsExpression(v) {
+				val, err = interpolate(entity, v, depth+1)
+			}
+		}
+	}
+
+	if err != nil {
+		if hasDefault {
+			if _, unresolved := err.(*UnresolvedReferenceError); unresolved {
+				return interpolate(entity, defaultValue, depth+1)
+			}
+		}
+		return nil, err
+	}
+	return val, nil
+}
+
+func lookupReference(entity Entity, ref string) (interface{}, error) {
+	namespace, name, _ := strings.Cut(ref, ".")
+	if name == "" {
+		return nil, &UnresolvedReferenceError{Reference: ref, Reason: "expected namespace.name"}
+	}
+
+	m := entity.GetModel()
+	found := false
+	var val interface{}
+
+	switch namespace {
+	case "env":
+		val, found = EnvVariableResolver{}.Resolve(entity, name, false)
+	case "args":
+		val, found = CmdLineArgVariableResolver{}.Resolve(entity, name, false)
+	case "bindings":
+		if val, found = m.VarConfig.LabelResolver.Resolve(entity, name, false); !found {
+			val, found = m.VarConfig.BindingResolver.Resolve(entity, name, false)
+		}
+	case "var":
+		val, found = entity.GetScope().VariableResolver.Resolve(entity, name, false)
+	case "model":
+		varName, ok := strings.CutPrefix(name, "variables.")
+		if !ok {
+			return nil, &UnresolvedReferenceError{Reference: ref, Reason: "expected model.variables.NAME"}
+		}
+		val, found = m.GetScope().VariableResolver.Resolve(m, varName, false)
+	case "regions", "hosts", "components":
+		return lookupEntityAttribute(m, ref, namespace, name)
+	default:
+		return nil, &UnresolvedReferenceError{Reference: ref, Reason: fmt.Sprintf("unknown namespace '%s'", namespace)}
+	}
+
+	if !found {
+		return nil, &UnresolvedReferenceError{Reference: ref, Reason: "not found"}
+	}
+	return val, nil
+}
+
+func lookupEntityAttribute(m *Model, ref, namespace, name string) (interface{}, error) {
+	id, attr, _ := strings.Cut(name, ".")
+	if attr == "" {
+		return nil, &UnresolvedReferenceError{Reference: ref, Reason: fmt.Sprintf("expected %s.ID.ATTRIBUTE", namespace)}
+	}
+
+	var matches []Entity
+	m.Accept(func(entity Entity) {
+		if entity.GetId() != id {
+			return
+		}
+		if namespace == "regions" && entity.GetType() == EntityTypeRegion ||
+			namespace == "hosts" && entity.GetType() == EntityTypeHost ||
+			namespace == "components" && entity.GetType() == EntityTypeComponent {
+			matches = append(matches, entity)
+		}
+	})
+	if len(matches) == 0 {
+		return nil, &UnresolvedReferenceError{Reference: ref, Reason: fmt.Sprintf("no %s with id '%s'", strings.TrimSuffix(namespace, "s"), id)}
+	}
+	if len(matches) > 1 {
+		var paths []string
+		for _, match := range matches {
+			paths = append(paths, strings.Join(GetScopedEntityPath(match), "."))
+		}
+		sort.Strings(paths)
+		return nil, &UnresolvedReferenceError{Reference: ref, Reason: fmt.Sprintf("id '%s' is ambiguous, matches %v", id, paths)}
+	}
+	entity := matches[0]
+
+	if varName, ok := strings.CutPrefix(attr, "variables."); ok {
+		if val, found := entity.GetScope().VariableResolver.Resolve(entity, varName, false); found {
+			return val, nil
+		}
+		return nil, &UnresolvedReferenceError{Reference: ref, Reason: "not found"}
+	}
+
+	val, known := EntityAttribute(entity, attr)
+	if !known {
+		return nil, &UnresolvedReferenceError{Reference: ref, Reason: fmt.Sprintf("unknown %s attribute '%s'", entity.GetType(), attr)}
+	}
+
+	if val == "" {
+		return nil, &UnresolvedReferenceError{Reference: ref, Reason: "not known yet, it may only be available after express", Late: true}
+	}
+	return val, nil
+}
+
+// IsLateReference returns true if err is an UnresolvedReferenceError for a value which is only
+// known after express
+func IsLateReference(err error) bool {
+	var unresolved *UnresolvedReferenceError
+	return errors.As(err, &unresolved) && unresolved.Late
+}
+
+// ResolveTypedExpressions interpolates the Expressions in a config value or stage parameter,
+// including those nested in maps and lists, leaving value as it is. Expressions consisting of a
+// single reference which resolve to a string become ResolvedStrings, typed by the field they're
+// decoded into.
+func ResolveTypedExpressions(entity Entity, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case Expression:
+		result, err := Interpolate(entity, string(v))
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := result.(string); ok {
+			return ResolvedString(s), nil
+		}
+		return result, nil
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			resolved, err := ResolveTypedExpressions(entity, child)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = resolved
+		}
+		return result, nil
+	case map[interface{}]interface{}:
+		result := make(map[interface{}]interface{}, len(v))
+		for k, child := range v {
+			resolved, err := ResolveTypedExpressions(entity, child)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = resolved
+		}
+		return result, nil
+	case StageParams:
+		resolved, err := ResolveTypedExpressions(entity, map[string]interface{}(v))
+		if err != nil {
+			return nil, err
+		}
+		return StageParams(resolved.(map[string]interface{})), nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, child := range v {
+			resolved, err := ResolveTypedExpressions(entity, child)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = resolved
+		}
+		return result, nil
+	}
+	return value, nil
+}
+
+// HasExpressions returns true if the value is or contains an Expression
+func HasExpressions(value interface{}) bool {
+	return hasExpressions(value)
+}
+
+// hasExpressions returns true if the variable value is or contains an Expression
+func hasExpressions(value interface{}) bool {
+	switch v := value.(type) {
+	case Expression:
+		return true
+	case map[string]interface{}:
+		for _, child := range v {
+			if hasExpressions(child) {
+				return true
+			}
+		}
+	case map[interface{}]interface{}:
+		for _, child := range v {
+			if hasExpressions(child) {
+				return true
+			}
+		}
+	case Variables:
+		return hasExpressions(map[string]interface{}(v))
+	case StageParams:
+		return hasExpressions(map[string]interface{}(v))
+	case []interface{}:
+		for _, child := range v {
+			if hasExpressions(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveExpressions interpolates the expressions in a variable value, including those nested
+// in maps and lists
+func resolveExpressions(entity Entity, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case Expression:
+		return Interpolate(entity, string(v))
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			resolved, err := resolveExpressions(entity, child)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = resolved
+		}
+		return result, nil
+	case Variables:
+		resolved, err := resolveExpressions(entity, map[string]interface{}(v))
+		if err != nil {
+			return nil, err
+		}
+		return Variables(resolved.(map[string]interface{})), nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, child := range v {
+			resolved, err := resolveExpressions(entity, child)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = resolved
+		}
+		return result, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("FABLAB_TEST_NAME", "world")
+
+	m := &Model{
+		Id: "test",
+		Scope: Scope{
+			Defaults: Variables{
+				"greeting": "hello",
+				"message":  Expression("${var.greeting} ${env.FABLAB_TEST_NAME}"),
+				"count":    3,
+				"loop":     Expression("${var.loop}"),
+			},
+		},
+	}
+	m.Init()
+
+	cases := map[string]interface{}{
+		"${var.greeting}, $${var.greeting}":      "hello, ${var.greeting}",
+		"${model.variables.message}!":            "hello world!",
+		"${var.count}":                           3,
+		"${env.FABLAB_TEST_UNSET:-${var.count}}": 3,
+	}
+	for expr, expected := range cases {
+		val, err := Interpolate(m, expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", expr, err)
+		} else if val != expected {
+			t.Errorf("%s: expected %v, got %v", expr, expected, val)
+		}
+	}
+
+	for _, expr := range []string{"${var.missing}", "${unknown.name}", "${var.loop}"} {
+		if _, err := Interpolate(m, expr); err == nil {
+			t.Errorf("%s: expected error", expr)
+		}
+	}
+
+	if val, _ := m.GetVariable("message"); val != "hello world" {
+		t.Errorf("expected expression variable to be interpolated on read, got %v", val)
+	}
+}
@@ -149,10 +149,13 @@ func labelPath(path string) string {
 }
 
 type Label struct {
-	InstanceId string        `yaml:"id"`
-	Model      string        `yaml:"model"`
-	State      InstanceState `yaml:"state"`
-	Bindings   Variables     `yaml:"bindings"`
+	InstanceId string            `yaml:"id"`
+	Model      string            `yaml:"model"`
+	State      InstanceState     `yaml:"state"`
+	Bindings   Variables         `yaml:"bindings"`
+	History    []StateTransition `yaml:"history,omitempty"`
+	Indexes    *EntityIndexes    `yaml:"indexes,omitempty"`
+	Scales     map[string]uint32 `yaml:"scales,omitempty"`
 	path       string
 }
 
@@ -0,0 +1,140 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MaxStateHistory is the number of transitions kept in the history of a label.
+const MaxStateHistory = 100
+
+// ForceTransitions allows lifecycle transitions the instance's state would otherwise refuse.
+var ForceTransitions bool
+
+// LifecycleCommand is the command recorded with lifecycle transitions.
+var LifecycleCommand string
+
+// allowedTransitions maps each state to the states it may move to. Earlier stages may be run
+// again on an instance which has progressed further, but a disposed instance stays disposed.
+var allowedTransitions = map[InstanceState][]InstanceState{
+	Created:     {Expressed, Disposed},
+	Expressed:   {Expressed, Configured, Disposed},
+	Configured:  {Expressed, Configured, Distributed, Disposed},
+	Distributed: {Expressed, Configured, Distributed, Activated, Operating, Disposed},
+	Activated:   {Expressed, Configured, Distributed, Activated, Operating, Disposed},
+	Operating:   {Expressed, Configured, Distributed, Activated, Operating, Disposed},
+	Disposed:    {Disposed},
+}
+
+// CanTransitionTo returns true if an instance in this state may move to the given state.
+func (instanceState InstanceState) CanTransitionTo(to InstanceState) bool {
+	for _, allowed := range allowedTransitions[instanceState] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionError reports a lifecycle transition refused by the instance's state.
+type TransitionError struct {
+	From InstanceState
+	To   InstanceState
+}
+
+func (e *TransitionError) Error() string {
+	var allowed []string
+	for _, state := range allowedTransitions[e.From] {
+		allowed = append(allowed, state.String())
+	}
+	return fmt.Sprintf("instance is %s and cannot become %s, only %s (force the transition to override)",
+		e.From, e.To, strings.Join(allowed, ", "))
+}
+
+// StateTransition records a lifecycle transition of an instance, successful or not.
+type StateTransition struct {
+	From     InstanceState `yaml:"from"`
+	To       InstanceState `yaml:"to"`
+	Time     time.Time     `yaml:"time"`
+	Duration time.Duration `yaml:"duration"`
+	Command  string        `yaml:"command,omitempty"`
+	User     string        `yaml:"user,omitempty"`
+	Success  bool          `yaml:"success"`
+	Forced   bool          `yaml:"forced,omitempty"`
+	Error    string        `yaml:"error,omitempty"`
+}
+
+// Transition moves the instance to the given state by running f, refusing with a
+// TransitionError if the current state doesn't allow it, unless ForceTransitions is set. The
+// attempt is added to the history and the label saved whether or not f succeeds, the state only
+// changes if it does.
+func (label *Label) Transition(to InstanceState, f func() error) error {
+	from := label.State
+	forced := false
+	if !from.CanTransitionTo(to) {
+		if !ForceTransitions {
+			return &TransitionError{From: from, To: to}
+		}
+		forced = true
+	}
+
+	start := time.Now()
+	err := f()
+
+	transition := StateTransition{
+		From:     from,
+		To:       to,
+		Time:     start,
+		Duration: time.Since(start),
+		Command:  LifecycleCommand,
+		User:     currentUserName(),
+		Success:  err == nil,
+		Forced:   forced,
+	}
+	if err != nil {
+		transition.Error = err.Error()
+	} else {
+		label.State = to
+	}
+	label.History = append(label.History, transition)
+	if len(label.History) > MaxStateHistory {
+		label.History = label.History[len(label.History)-MaxStateHistory:]
+	}
+
+	if saveErr := label.Save(); saveErr != nil {
+		if err != nil {
+			logrus.WithError(saveErr).Error("error updating instance label")
+			return err
+		}
+		return fmt.Errorf("error updating instance label (%w)", saveErr)
+	}
+	return err
+}
+
+func currentUserName() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,95 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceState_CanTransitionTo(t *testing.T) {
+	req := require.New(t)
+	req.True(Created.CanTransitionTo(Expressed))
+	req.False(Created.CanTransitionTo(Activated))
+	req.True(Activated.CanTransitionTo(Configured))
+	req.True(Distributed.CanTransitionTo(Operating))
+	req.False(Expressed.CanTransitionTo(Operating))
+	req.False(Disposed.CanTransitionTo(Expressed))
+	req.True(Disposed.CanTransitionTo(Disposed))
+
+	for from := Created; from <= Disposed; from++ {
+		req.True(from.CanTransitionTo(Disposed), "%s should be disposable", from)
+	}
+}
+
+func TestLabel_Transition(t *testing.T) {
+	req := require.New(t)
+	path := t.TempDir()
+	l := &Label{InstanceId: "test", State: Created, Bindings: Variables{}, path: path}
+
+	LifecycleCommand = "fablab express"
+	defer func() { LifecycleCommand = "" }()
+
+	req.NoError(l.Transition(Expressed, func() error { return nil }))
+	req.Equal(Expressed, l.State)
+
+	err := l.Transition(Activated, func() error {
+		req.Fail("refused transition must not run")
+		return nil
+	})
+	var transitionErr *TransitionError
+	req.ErrorAs(err, &transitionErr)
+	req.Equal(Expressed, transitionErr.From)
+	req.Equal(Activated, transitionErr.To)
+	req.Contains(err.Error(), "only Expressed, Configured, Disposed")
+
+	req.EqualError(l.Transition(Configured, func() error { return errors.New("no kit") }), "no kit")
+	req.Equal(Expressed, l.State)
+
+	ForceTransitions = true
+	defer func() { ForceTransitions = false }()
+	req.NoError(l.Transition(Activated, func() error { return nil }))
+	req.Equal(Activated, l.State)
+
+	loaded, err := LoadLabel(path)
+	req.NoError(err)
+	req.Equal(Activated, loaded.State)
+	req.Len(loaded.History, 3)
+
+	req.Equal(Created, loaded.History[0].From)
+	req.Equal(Expressed, loaded.History[0].To)
+	req.True(loaded.History[0].Success)
+	req.Equal("fablab express", loaded.History[0].Command)
+	req.False(loaded.History[0].Time.IsZero())
+
+	req.False(loaded.History[1].Success)
+	req.Equal("no kit", loaded.History[1].Error)
+
+	req.True(loaded.History[2].Forced)
+	req.True(loaded.History[2].Success)
+}
+
+func TestLabel_TransitionHistoryLimit(t *testing.T) {
+	req := require.New(t)
+	l := &Label{State: Expressed, Bindings: Variables{}, path: t.TempDir()}
+	for i := 0; i < MaxStateHistory+5; i++ {
+		req.NoError(l.Transition(Expressed, func() error { return nil }))
+	}
+	req.Len(l.History, MaxStateHistory)
+}
@@ -0,0 +1,245 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/openziti/fablab/kernel/lib/parallel"
+	"github.com/pkg/errors"
+)
+
+const DefaultLogLines = 100
+
+// A LogProvidingComponent knows where its components write their logs on their hosts.
+type LogProvidingComponent interface {
+	ComponentType
+
+	// GetLogPaths returns the paths of the component's log files. Relative paths are resolved
+	// against the home directory of the ssh user
+	GetLogPaths(run Run, c *Component) []string
+}
+
+// LogOptions selects the log lines returned by StreamLogs.
+type LogOptions struct {
+	// Lines is the number of lines read from the end of each log, all of them if 0
+	Lines int
+	// Follow keeps reading lines as they are written
+	Follow bool
+	// Grep, if set, is a regular expression lines must match
+	Grep string
+	// Since, if set, drops lines with timestamps before it. Lines without a timestamp, such as
+	// stack traces, share the timestamp of the line before them
+	Since time.Time
+}
+
+// LogLine is a line read from a component's log.
+type LogLine struct {
+	Component *Component
+	Path      string
+	Text      string
+}
+
+// GetLogPaths returns the log paths of the component if its type is a LogProvidingComponent.
+func (component *Component) GetLogPaths(run Run) []string {
+	if lp, ok := component.Type.(LogProvidingComponent); ok {
+		return lp.GetLogPaths(run, component)
+	}
+	return nil
+}
+
+// StreamLogs reads the logs of the components over ssh, concurrently, calling f for each
+// selected line. Calls to f are serialized. Components without logs are skipped. If follow is
+// set it returns once ctx is done.
+func StreamLogs(ctx context.Context, run Run, components []*Component, options LogOptions, f func(LogLine)) error {
+	filter, err := newLogFilter(options)
+	if err != nil {
+		return err
+	}
+
+	var lock sync.Mutex
+	var tasks []parallel.Task
+	for _, c := range components {
+		for _, logPath := range c.GetLogPaths(run) {
+			boundComponent, boundPath := c, logPath
+			tasks = append(tasks, func() error {
+				lines := &logLineWriter{filter: filter.clone(), emit: func(text string) {
+					lock.Lock()
+					defer lock.Unlock()
+					f(LogLine{Component: boundComponent, Path: boundPath, Text: text})
+				}}
+				err := boundComponent.GetHost().ExecStream(ctx, lines, tailCommand(boundPath, options))
+				lines.flush()
+				if err != nil && ctx.Err() == nil {
+					return errors.Wrapf(err, "unable to read %s of component '%s'", boundPath, boundComponent.GetPathId())
+				}
+				return nil
+			})
+		}
+	}
+	if len(tasks) == 0 {
+		return errors.New("none of the selected components provide logs")
+	}
+	return parallel.Execute(tasks, int64(len(tasks)))
+}
+
+func tailCommand(path string, options LogOptions) string {
+	lines := "+1"
+	if options.Lines > 0 {
+		lines = fmt.Sprintf("%d", options.Lines)
+	}
+	if options.Follow {
+		return fmt.Sprintf("tail -n %s -F %s", lines, shellQuote(path))
+	}
+	return fmt.Sprintf("tail -n %s %s", lines, shellQuote(path))
+}
+
+// logTimestamp matches RFC 3339 and similar timestamps, as found at the start of text logs
+// and in the time field of JSON logs.
+var logTimestamp = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`)
+
+var logTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// parseLogTimestamp returns the first timestamp in the line. Timestamps without a zone are
+// taken to be UTC.
+func parseLogTimestamp(line string) (time.Time, bool) {
+	match := logTimestamp.FindString(line)
+	if match == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range logTimestampLayouts {
+		if t, err := time.Parse(layout, match); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// logFilter applies the grep and since options to the lines of a single log.
+type logFilter struct {
+	grep   *regexp.Regexp
+	since  time.Time
+	recent bool
+}
+
+func newLogFilter(options LogOptions) (*logFilter, error) {
+	result := &logFilter{since: options.Since}
+	if options.Grep != "" {
+		grep, err := regexp.Compile(options.Grep)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid grep pattern '%s'", options.Grep)
+		}
+		result.grep = grep
+	}
+	return result, nil
+}
+
+func (f *logFilter) clone() *logFilter {
+	result := *f
+	return &result
+}
+
+func (f *logFilter) accept(line string) bool {
+	if !f.since.IsZero() {
+		if t, found := parseLogTimestamp(line); found {
+			f.recent = !t.Before(f.since)
+		}
+		if !f.recent {
+			return false
+		}
+	}
+	return f.grep == nil || f.grep.MatchString(line)
+}
+
+// logLineWriter splits output into lines, emitting those the filter accepts. It is written to
+// concurrently by the stdout and stderr of the ssh session.
+type logLineWriter struct {
+	lock    sync.Mutex
+	filter  *logFilter
+	emit    func(string)
+	partial []byte
+}
+
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.line(string(w.partial[:i]))
+		w.partial = w.partial[i+1:]
+	}
+}
+
+func (w *logLineWriter) flush() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if len(w.partial) > 0 {
+		w.line(string(w.partial))
+		w.partial = nil
+	}
+}
+
+func (w *logLineWriter) line(text string) {
+	if len(text) > 0 && text[len(text)-1] == '\r' {
+		text = text[:len(text)-1]
+	}
+	if w.filter.accept(text) {
+		w.emit(text)
+	}
+}
+
+// ParseLogSince parses a since option given either as a duration before now, such as `10m`, or
+// as an RFC 3339 timestamp.
+func ParseLogSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.Errorf("invalid since '%s', expected a duration such as 10m or an RFC 3339 timestamp", value)
+}
+
+// FormatLogLine prefixes the line with the path of its component, and the name of its log file
+// if the component has several.
+func FormatLogLine(run Run, line LogLine) string {
+	prefix := line.Component.GetPathId()
+	if len(line.Component.GetLogPaths(run)) > 1 {
+		prefix += " " + path.Base(line.Path)
+	}
+	return fmt.Sprintf("[%s] %s", prefix, line.Text)
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func filterLogLines(t *testing.T, options LogOptions, chunks ...string) []string {
+	filter, err := newLogFilter(options)
+	require.NoError(t, err)
+
+	var result []string
+	w := &logLineWriter{filter: filter, emit: func(line string) {
+		result = append(result, line)
+	}}
+	for _, chunk := range chunks {
+		_, err := w.Write([]byte(chunk))
+		require.NoError(t, err)
+	}
+	w.flush()
+	return result
+}
+
+func TestLogFilter(t *testing.T) {
+	req := require.New(t)
+
+	log := strings.Join([]string{
+		`[2024-05-01T10:00:00.000Z] INFO starting`,
+		`[2024-05-01T10:05:00.000Z] ERROR link failed`,
+		`    at dial`,
+		`{"level":"info","time":"2024-05-01T10:10:00.123+02:00","msg":"retrying"}`,
+		`2024-05-01 10:15:00 ERROR link failed again`,
+	}, "\n")
+
+	req.Equal(5, len(filterLogLines(t, LogOptions{}, log[:17], log[17:])))
+	req.Equal([]string{
+		`[2024-05-01T10:05:00.000Z] ERROR link failed`,
+		`2024-05-01 10:15:00 ERROR link failed again`,
+	}, filterLogLines(t, LogOptions{Grep: "ERROR"}, log))
+
+	since := time.Date(2024, 5, 1, 10, 1, 0, 0, time.UTC)
+	req.Equal([]string{
+		`[2024-05-01T10:05:00.000Z] ERROR link failed`,
+		`    at dial`,
+		`2024-05-01 10:15:00 ERROR link failed again`,
+	}, filterLogLines(t, LogOptions{Since: since}, log+"\r\n"))
+
+	_, err := newLogFilter(LogOptions{Grep: "("})
+	req.ErrorContains(err, "invalid grep pattern '('")
+}
+
+func TestParseLogSince(t *testing.T) {
+	req := require.New(t)
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	since, err := ParseLogSince("15m", now)
+	req.NoError(err)
+	req.Equal(now.Add(-15*time.Minute), since)
+
+	since, err = ParseLogSince("2024-04-30T08:00:00Z", now)
+	req.NoError(err)
+	req.Equal(time.Date(2024, 4, 30, 8, 0, 0, 0, time.UTC), since)
+
+	since, err = ParseLogSince("", now)
+	req.NoError(err)
+	req.True(since.IsZero())
+
+	_, err = ParseLogSince("yesterday", now)
+	req.ErrorContains(err, "invalid since 'yesterday'")
+}
+
+func TestTailCommand(t *testing.T) {
+	req := require.New(t)
+	req.Equal("tail -n 100 -F '/home/ubuntu/fablab/logs/it'\\''s.log'",
+		tailCommand("/home/ubuntu/fablab/logs/it's.log", LogOptions{Lines: 100, Follow: true}))
+	req.Equal("tail -n +1 'app.log'", tailCommand("app.log", LogOptions{}))
+}
@@ -17,6 +17,7 @@
 package model
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	"io"
@@ -129,6 +130,7 @@ type VarConfig struct {
 	ResolverLogger                func(resolver string, entity Entity, name string, result interface{}, found bool, msgAndArgs ...interface{})
 	BindingResolver               *MapVariableResolver
 	LabelResolver                 *MapVariableResolver
+	SecretResolver                *SecretVariableResolver
 }
 
 func (self *VarConfig) SetDefaults() {
@@ -156,6 +158,9 @@ func (self *VarConfig) SetDefaults() {
 
 	self.BindingResolver = NewMapVariableResolver("bindings", bindings)
 	self.LabelResolver = NewMapVariableResolver("label", nil)
+	if self.SecretResolver == nil {
+		self.SecretResolver = NewSecretVariableResolver()
+	}
 
 	if self.DefaultVariableResolver == nil {
 		defaultResolverSet := &ChainedVariableResolver{}
@@ -163,6 +168,7 @@ func (self *VarConfig) SetDefaults() {
 		defaultResolverSet.AppendResolver(EnvVariableResolver{})
 		defaultResolverSet.AppendResolver(self.LabelResolver)
 		defaultResolverSet.AppendResolver(self.BindingResolver)
+		defaultResolverSet.AppendResolver(self.SecretResolver)
 		defaultResolverSet.AppendResolver(HierarchicalVariableResolver{})
 		self.DefaultVariableResolver = defaultResolverSet
 
@@ -198,10 +204,7 @@ func (self *VarConfig) EnableDebugLogger() {
 	self.ResolverLogger = func(resolver string, entity Entity, name string, result interface{}, found bool, msgAndArgs ...interface{}) {
 		msg := ""
 		if len(msgAndArgs) > 0 {
-			msg = fmt.Sprintf(", ctx=%v", msgAndArgs[0])
-			if len(msg) > 1 {
-				msg = fmt.Sprintf(msg, msgAndArgs[1:]...)
-			}
+			msg = ", ctx=" + formatResolverContext(msgAndArgs)
 		}
 		fmt.Printf("%v: %v[id=%v] key=%v result=%v, found=%v%v\n", resolver, entity.GetType(), entity.GetId(), name, result, found, msg)
 	}
@@ -237,6 +240,9 @@ type Model struct {
 	regionIds    IdPool
 	hostIds      IdPool
 	componentIds IdPool
+	indexes      *EntityIndexes
+	scales       map[string]uint32
+	scaleGroups  map[string]*ScaleGroup
 }
 
 func (m *Model) GetModel() *Model {
@@ -306,6 +312,13 @@ func (m *Model) GetChildren() []Entity {
 	return result
 }
 
+// Init wires up parent references, indices and variable resolvers for the model and any
+// regions, hosts and components which haven't been initialized yet. Bootstrap does this for
+// Go-defined models; models built elsewhere, such as by the YAML loader, call it directly.
+func (m *Model) Init() {
+	m.init()
+}
+
 func (m *Model) init() {
 	if m.initialized.CompareAndSwap(false, true) {
 		m.VarConfig.SetDefaults()
@@ -330,6 +343,7 @@ func (m *Model) Accept(visitor EntityVisitor) {
 func (m *Model) RemoveRegion(region *Region) {
 	delete(m.Regions, region.Id)
 	m.regionIds.ReturnId(region.Index)
+	m.releaseIndex(EntityTypeRegion, region.Id)
 }
 
 func (m *Model) RangeSortedRegions(f func(id string, region *Region)) {
@@ -470,6 +484,7 @@ func (region *Region) Accept(visitor EntityVisitor) {
 func (region *Region) RemoveHost(host *Host) {
 	delete(region.Hosts, host.Id)
 	region.Model.hostIds.ReturnId(host.Index)
+	region.Model.releaseIndex(EntityTypeHost, region.Id+"."+host.Id)
 }
 
 func (region *Region) RangeSortedHosts(f func(id string, host *Host)) {
@@ -612,6 +627,51 @@ func (host *Host) Exec(out io.Writer, cmds ...string) error {
 	return nil
 }
 
+// ExecStream runs cmd on the host, writing its output to out until it exits or ctx is done.
+// Unlike Exec it doesn't hold the host's ssh lock while the command runs, so long-running
+// commands such as `tail -F` don't block other commands on the host.
+func (host *Host) ExecStream(ctx context.Context, out io.Writer, cmd string) error {
+	host.sshLock.Lock()
+	if host.sshClient == nil {
+		if host.sshConfigFactory == nil {
+			host.sshConfigFactory = host.NewSshConfigFactory()
+		}
+
+		client, err := ssh.Dial("tcp", host.sshConfigFactory.Address(), host.sshConfigFactory.Config())
+		if err != nil {
+			host.sshLock.Unlock()
+			return err
+		}
+		host.sshClient = client
+	}
+	session, err := host.sshClient.NewSession()
+	host.sshLock.Unlock()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = session.Close() }()
+
+	session.Stdout = out
+	session.Stderr = out
+	if err := session.Start(cmd); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		_ = session.Signal(ssh.SIGTERM)
+		_ = session.Close()
+		return ctx.Err()
+	}
+}
+
 func (host *Host) SendFile(localPath string, remotePath string) error {
 	localFile, err := os.ReadFile(localPath)
 
@@ -811,6 +871,7 @@ func (host *Host) Matches(entityType string, matcher EntityMatcher) bool {
 func (host *Host) RemoveComponent(component *Component) {
 	delete(host.Components, component.Id)
 	host.GetModel().componentIds.ReturnId(component.Index)
+	host.GetModel().releaseIndex(EntityTypeComponent, component.GetPathId())
 }
 
 func (host *Host) RangeSortedComponents(f func(id string, component *Component)) {
@@ -1034,6 +1095,13 @@ type Stage interface {
 	Execute(run Run) error
 }
 
+// HostStage is a Stage which can be limited to some of the hosts it visits, so that changes such
+// as upgrades and scaling leave the other hosts alone.
+type HostStage interface {
+	Stage
+	ExecuteOnHosts(run Run, hosts []*Host) error
+}
+
 type StageActionF func(run Run) error
 
 func (self StageActionF) Execute(run Run) error {
@@ -1124,20 +1192,47 @@ func (m *Model) AddOperatingActions(actions ...string) {
 }
 
 func (m *Model) Express(run Run) error {
+	return run.GetLabel().Transition(Expressed, func() error {
+		return m.ExpressStages(run)
+	})
+}
+
+// ExpressStages runs the infrastructure stages without changing the state of the instance, for
+// changes such as scaling which leave the instance where it was.
+func (m *Model) ExpressStages(run Run) error {
 	for _, stage := range m.Infrastructure {
 		if err := stage.Execute(run); err != nil {
 			return fmt.Errorf("error expressing infrastructure (%w)", err)
 		}
 	}
-	run.GetLabel().State = Expressed
-	if err := run.GetLabel().Save(); err != nil {
-		return fmt.Errorf("error updating instance label (%w)", err)
-	}
 	return nil
 }
 
+// BindComponentConfigs binds the late config of every component whose referenced values are known,
+// leaving the others to be bound when they're built
+func (m *Model) BindComponentConfigs() error {
+	var err error
+	m.Accept(func(entity Entity) {
+		if c, ok := entity.(*Component); ok && err == nil {
+			err = c.BindConfig(false)
+		}
+	})
+	return err
+}
+
 func (m *Model) Build(run Run) error {
+	return run.GetLabel().Transition(Configured, func() error {
+		return m.BuildStages(run)
+	})
+}
+
+// BuildStages binds late component config, stages component files and runs the configuration
+// stages without changing the state of the instance.
+func (m *Model) BuildStages(run Run) error {
 	err := m.ForEachComponent("*", 1, func(c *Component) error {
+		if err := c.BindConfig(true); err != nil {
+			return err
+		}
 		if stageable, ok := c.Type.(FileStagingComponent); ok {
 			return stageable.StageFiles(run, c)
 		}
@@ -1153,30 +1248,39 @@ func (m *Model) Build(run Run) error {
 			return fmt.Errorf("error building configuration (%w)", err)
 		}
 	}
-	run.GetLabel().State = Configured
-	if err := run.GetLabel().Save(); err != nil {
-		return fmt.Errorf("error updating instance label (%w)", err)
-	}
 	return nil
 }
 
 func (m *Model) Sync(run Run) error {
+	return run.GetLabel().Transition(Distributed, func() error {
+		return m.SyncStages(run)
+	})
+}
+
+// SyncStages runs the distribution stages and initializes the hosts without changing the state
+// of the instance.
+func (m *Model) SyncStages(run Run) error {
 	for idx, stage := range m.Distribution {
 		if err := stage.Execute(run); err != nil {
 			return fmt.Errorf("error distributing stage %d - %T, (%w)", idx+1, stage, err)
 		}
 	}
 
-	err := m.ForEachHost("*", 100, func(host *Host) error {
-		for _, c := range host.Components {
-			hostInitializer, ok := c.Type.(HostInitializingComponent)
-			if !ok {
-				continue
-			}
+	return m.ForEachHost("*", 100, func(host *Host) error {
+		return initializeHost(run, host, nil)
+	})
+}
 
-			if err := hostInitializer.InitializeHost(run, c); err != nil {
-				return err
-			}
+// BuildComponentStages stages the files of the given components and runs the configuration
+// stages, without changing the state of the instance. The configuration stages only write to
+// the local kit, so they aren't limited.
+func (m *Model) BuildComponentStages(run Run, components []*Component) error {
+	err := m.ForEachComponentIn(components, 1, func(c *Component) error {
+		if err := c.BindConfig(true); err != nil {
+			return err
+		}
+		if stageable, ok := c.Type.(FileStagingComponent); ok {
+			return stageable.StageFiles(run, c)
 		}
 		return nil
 	})
@@ -1185,51 +1289,90 @@ func (m *Model) Sync(run Run) error {
 		return err
 	}
 
-	run.GetLabel().State = Distributed
-	if err := run.GetLabel().Save(); err != nil {
-		return fmt.Errorf("error updating instance label (%w)", err)
+	for _, stage := range m.Configuration {
+		if err := stage.Execute(run); err != nil {
+			return fmt.Errorf("error building configuration (%w)", err)
+		}
 	}
-
 	return nil
 }
 
-func (m *Model) Activate(run Run) error {
-	for _, stage := range m.Activation {
-		if err := stage.Execute(run); err != nil {
-			return fmt.Errorf("error activating (%w)", err)
+// SyncHostStages runs the distribution stages on the given hosts and initializes them for the
+// given components, without changing the state of the instance. Distribution stages which
+// aren't a HostStage can't be limited, and are run on every host they select.
+func (m *Model) SyncHostStages(run Run, hosts []*Host, components []*Component) error {
+	for idx, stage := range m.Distribution {
+		var err error
+		if hostStage, ok := stage.(HostStage); ok {
+			err = hostStage.ExecuteOnHosts(run, hosts)
+		} else {
+			logrus.Warnf("distribution stage %d - %T can't be limited to hosts, running it for the whole model", idx+1, stage)
+			err = stage.Execute(run)
+		}
+		if err != nil {
+			return fmt.Errorf("error distributing stage %d - %T, (%w)", idx+1, stage, err)
 		}
 	}
-	run.GetLabel().State = Activated
-	if err := run.GetLabel().Save(); err != nil {
-		return fmt.Errorf("error updating instance label (%w)", err)
+
+	initialize := map[*Component]bool{}
+	for _, c := range components {
+		initialize[c] = true
 	}
-	return nil
+	return m.ForEachHostIn("*", hosts, 100, func(host *Host) error {
+		return initializeHost(run, host, initialize)
+	})
 }
 
-func (m *Model) Operate(run Run) error {
-	for _, stage := range m.Operation {
-		if err := stage.Execute(run); err != nil {
-			return fmt.Errorf("error operating (%w)", err)
+// initializeHost initializes the host for its components, or for those of them in include if
+// include isn't nil.
+func initializeHost(run Run, host *Host, include map[*Component]bool) error {
+	for _, c := range host.Components {
+		if include != nil && !include[c] {
+			continue
+		}
+		hostInitializer, ok := c.Type.(HostInitializingComponent)
+		if !ok {
+			continue
+		}
+
+		if err := hostInitializer.InitializeHost(run, c); err != nil {
+			return err
 		}
-	}
-	run.GetLabel().State = Operating
-	if err := run.GetLabel().Save(); err != nil {
-		return fmt.Errorf("error updating instance label (%w)", err)
 	}
 	return nil
 }
 
+func (m *Model) Activate(run Run) error {
+	return run.GetLabel().Transition(Activated, func() error {
+		for _, stage := range m.Activation {
+			if err := stage.Execute(run); err != nil {
+				return fmt.Errorf("error activating (%w)", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Model) Operate(run Run) error {
+	return run.GetLabel().Transition(Operating, func() error {
+		for _, stage := range m.Operation {
+			if err := stage.Execute(run); err != nil {
+				return fmt.Errorf("error operating (%w)", err)
+			}
+		}
+		return nil
+	})
+}
+
 func (m *Model) Dispose(run Run) error {
-	for _, stage := range m.Disposal {
-		if err := stage.Execute(run); err != nil {
-			return fmt.Errorf("error disposing (%w)", err)
+	return run.GetLabel().Transition(Disposed, func() error {
+		for _, stage := range m.Disposal {
+			if err := stage.Execute(run); err != nil {
+				return fmt.Errorf("error disposing (%w)", err)
+			}
 		}
-	}
-	run.GetLabel().State = Disposed
-	if err := run.GetLabel().Save(); err != nil {
-		return fmt.Errorf("error updating instance label (%w)", err)
-	}
-	return nil
+		return nil
+	})
 }
 
 func (m *Model) AcceptHostMetrics(host *Host, event *MetricsEvent) {
@@ -19,6 +19,8 @@ package model
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
 	"testing"
 )
 
@@ -49,3 +51,111 @@ func TestGetVariable(t *testing.T) {
 	_, found = m.GetVariable("d.e.f")
 	assert.False(t, found)
 }
+
+// initializingType records the components it initializes hosts for
+type initializingType struct {
+	GenericComponent
+	initialized *[]string
+}
+
+func (t *initializingType) InitializeHost(_ Run, c *Component) error {
+	*t.initialized = append(*t.initialized, c.Id)
+	return nil
+}
+
+// recordingHostStage records the hosts it is run on
+type recordingHostStage struct {
+	synced *[]string
+}
+
+func (stage recordingHostStage) Execute(run Run) error {
+	return stage.ExecuteOnHosts(run, run.GetModel().SelectHosts("*"))
+}
+
+func (stage recordingHostStage) ExecuteOnHosts(_ Run, hosts []*Host) error {
+	var ids []string
+	for _, host := range hosts {
+		ids = append(ids, host.Id)
+	}
+	*stage.synced = append(*stage.synced, strings.Join(ids, " "))
+	return nil
+}
+
+func TestModel_SyncHostStages(t *testing.T) {
+	req := require.New(t)
+	var initialized, synced []string
+	unlimited := 0
+	m := &Model{
+		Id: "test",
+		Regions: Regions{
+			"region": {
+				Hosts: Hosts{
+					"host1": {Components: Components{
+						"a": {Type: &initializingType{initialized: &initialized}},
+						"b": {Type: &initializingType{initialized: &initialized}},
+					}},
+					"host2": {Components: Components{
+						"c": {Type: &initializingType{initialized: &initialized}},
+					}},
+				},
+			},
+		},
+		Distribution: Stages{
+			recordingHostStage{synced: &synced},
+			StageActionF(func(Run) error {
+				unlimited++
+				return nil
+			}),
+		},
+	}
+	m.init()
+
+	run := NewContext(m, nil, nil).NewRun()
+	a := m.MustSelectComponent("#a")
+	req.NoError(m.SyncHostStages(run, []*Host{a.GetHost()}, []*Component{a}))
+	req.Equal([]string{"host1"}, synced)
+	req.Equal(1, unlimited)
+	req.Equal([]string{"a"}, initialized)
+}
+
+// stagingType records the components it stages files for
+type stagingType struct {
+	GenericComponent
+	staged *[]string
+}
+
+func (t *stagingType) StageFiles(_ Run, c *Component) error {
+	*t.staged = append(*t.staged, c.Id)
+	return nil
+}
+
+func TestModel_BuildComponentStages(t *testing.T) {
+	req := require.New(t)
+	var staged []string
+	configured := 0
+	m := &Model{
+		Id: "test",
+		Regions: Regions{
+			"region": {
+				Hosts: Hosts{
+					"host1": {Components: Components{
+						"a": {Type: &stagingType{staged: &staged}},
+						"b": {Type: &stagingType{staged: &staged}},
+					}},
+				},
+			},
+		},
+		Configuration: Stages{
+			StageActionF(func(Run) error {
+				configured++
+				return nil
+			}),
+		},
+	}
+	m.init()
+
+	run := NewContext(m, nil, nil).NewRun()
+	req.NoError(m.BuildComponentStages(run, []*Component{m.MustSelectComponent("#b")}))
+	req.Equal([]string{"b"}, staged)
+	req.Equal(1, configured)
+}
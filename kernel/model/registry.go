@@ -11,6 +11,9 @@ type ComponentFactory func() ComponentType
 var (
 	registryMu sync.RWMutex
 	registry   = make(map[string]ComponentFactory)
+
+	loadersMu sync.Mutex
+	loaders   []func()
 )
 
 // RegisterComponentType registers a factory for a given component type name.
@@ -24,19 +27,49 @@ func RegisterComponentType(typeName string, factory ComponentFactory) {
 	registry[typeName] = factory
 }
 
-// GetComponentType creates a new instance of the component type by name.
-func GetComponentType(typeName string) (ComponentType, error) {
+// RegisterComponentTypeLoader registers a function which registers further component types, such
+// as those of plugins. Loaders run once, the first time a component type isn't found or the types
+// are listed, so types can be looked up before the command line has been processed.
+func RegisterComponentTypeLoader(loader func()) {
+	loadersMu.Lock()
+	defer loadersMu.Unlock()
+	loaders = append(loaders, loader)
+}
+
+// runComponentTypeLoaders runs the loaders which haven't run yet. Callers loading concurrently
+// wait for the loaders to finish.
+func runComponentTypeLoaders() {
+	loadersMu.Lock()
+	defer loadersMu.Unlock()
+	pending := loaders
+	loaders = nil
+	for _, loader := range pending {
+		loader()
+	}
+}
+
+func getComponentFactory(typeName string) (ComponentFactory, bool) {
 	registryMu.RLock()
 	defer registryMu.RUnlock()
 	factory, ok := registry[typeName]
+	return factory, ok
+}
+
+// GetComponentType creates a new instance of the component type by name.
+func GetComponentType(typeName string) (ComponentType, error) {
+	factory, ok := getComponentFactory(typeName)
 	if !ok {
-		return nil, fmt.Errorf("component type '%s' not found in registry", typeName)
+		runComponentTypeLoaders()
+		if factory, ok = getComponentFactory(typeName); !ok {
+			return nil, fmt.Errorf("component type '%s' not found in registry", typeName)
+		}
 	}
 	return factory(), nil
 }
 
 // ListComponentTypes returns all registered component type names.
 func ListComponentTypes() []string {
+	runComponentTypeLoaders()
 	registryMu.RLock()
 	defer registryMu.RUnlock()
 	names := make([]string, 0, len(registry))
@@ -46,7 +79,8 @@ func ListComponentTypes() []string {
 	return names
 }
 
-// HasComponentType checks if a component type is registered.
+// HasComponentType checks if a component type is registered. It doesn't run the loaders, so
+// loaders can use it to avoid registering a type twice.
 func HasComponentType(typeName string) bool {
 	registryMu.RLock()
 	defer registryMu.RUnlock()
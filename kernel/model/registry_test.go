@@ -55,3 +55,19 @@ func TestComponentType_Interfaces(t *testing.T) {
 		t.Error("ziti-router should implement ServerComponent")
 	}
 }
+
+func TestGetComponentType_SystemdService(t *testing.T) {
+	comp, err := GetComponentType("systemd-service")
+	if err != nil {
+		t.Fatalf("expected systemd-service to be registered, got error: %v", err)
+	}
+	if _, ok := comp.(ServerComponent); !ok {
+		t.Error("systemd-service should implement ServerComponent")
+	}
+	if _, ok := comp.(FileStagingComponent); !ok {
+		t.Error("systemd-service should implement FileStagingComponent")
+	}
+	if _, ok := comp.(HostInitializingComponent); !ok {
+		t.Error("systemd-service should implement HostInitializingComponent")
+	}
+}
@@ -0,0 +1,127 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// A ResolvedString is the string a config value or stage parameter consisting of a single `${...}`
+// reference resolved to. It's decoded as the YAML it spells into fields which aren't strings, so
+// `port: ${env.PORT}` fills a numeric field, and kept as written into string fields, so a version
+// of 1.10 doesn't become 1.1.
+type ResolvedString string
+
+var yamlUnmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// TypeResolvedStrings replaces the ResolvedStrings in value, a config or params map, by what they
+// decode to in the fields of target they're decoded into. Values decoded into strings, interfaces
+// or types unmarshaling themselves stay strings.
+func TypeResolvedStrings(value interface{}, target reflect.Type) interface{} {
+	for target != nil && target.Kind() == reflect.Ptr {
+		target = target.Elem()
+	}
+	if target != nil && (target.Implements(yamlUnmarshalerType) || reflect.PointerTo(target).Implements(yamlUnmarshalerType)) {
+		target = nil
+	}
+
+	switch v := value.(type) {
+	case ResolvedString:
+		return typeResolvedString(string(v), target)
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = TypeResolvedStrings(child, fieldType(target, k))
+		}
+	case map[interface{}]interface{}:
+		for k, child := range v {
+			key, _ := k.(string)
+			v[k] = TypeResolvedStrings(child, fieldType(target, key))
+		}
+	case []interface{}:
+		var elem reflect.Type
+		if target != nil && (target.Kind() == reflect.Slice || target.Kind() == reflect.Array) {
+			elem = target.Elem()
+		}
+		for idx, child := range v {
+			v[idx] = TypeResolvedStrings(child, elem)
+		}
+	}
+	return value
+}
+
+func typeResolvedString(s string, target reflect.Type) interface{} {
+	if target == nil || s == "" {
+		return s
+	}
+	switch target.Kind() {
+	case reflect.String, reflect.Interface:
+		return s
+	}
+	var parsed interface{}
+	if err := yaml.Unmarshal([]byte(s), &parsed); err != nil {
+		return s
+	}
+	switch parsed.(type) {
+	case map[interface{}]interface{}, []interface{}:
+		return s
+	}
+	return parsed
+}
+
+// fieldType returns the type of the value stored under key in target, following the yaml tags of
+// structs, including inlined ones. It returns nil if target doesn't declare the key.
+func fieldType(target reflect.Type, key string) reflect.Type {
+	if target == nil {
+		return nil
+	}
+	switch target.Kind() {
+	case reflect.Map:
+		return target.Elem()
+	case reflect.Struct:
+		for i := 0; i < target.NumField(); i++ {
+			field := target.Field(i)
+			if field.PkgPath != "" && !field.Anonymous {
+				continue
+			}
+			tag := field.Tag.Get("yaml")
+			name, options, _ := strings.Cut(tag, ",")
+			if name == "-" {
+				continue
+			}
+			if strings.Contains(","+options+",", ",inline,") {
+				inlined := field.Type
+				for inlined.Kind() == reflect.Ptr {
+					inlined = inlined.Elem()
+				}
+				if result := fieldType(inlined, key); result != nil {
+					return result
+				}
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			if name == key {
+				return field.Type
+			}
+		}
+	}
+	return nil
+}
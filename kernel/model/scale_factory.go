@@ -9,6 +9,12 @@ type ScaleStrategy interface {
 	GetEntityCount(entity Entity) uint32
 }
 
+// ScaleMarkerClearer is implemented by strategies which mark the entities to scale themselves,
+// such as in their scope data, to remove the marks from the model once it has been scaled.
+type ScaleMarkerClearer interface {
+	ClearScaleMarker(entity Entity)
+}
+
 type ScaleEntityFactory interface {
 	CreateScaledRegion(source *Region, scaleIndex uint32) (*Region, error)
 	CreateScaledHost(source *Host, scaleIndex uint32) (*Host, error)
@@ -32,9 +38,7 @@ type ScaleFactory struct {
 }
 
 func (factory *ScaleFactory) Build(m *Model) error {
-	defer m.Accept(func(entity Entity) {
-		delete(entity.GetScope().Defaults, "__scaled__")
-	})
+	defer m.Accept(factory.clearScaled)
 
 	if err := factory.ProcessRegions(m); err != nil {
 		return err
@@ -58,23 +62,37 @@ func (factory *ScaleFactory) ProcessRegions(m *Model) error {
 	})
 
 	for _, region := range scaledRegions {
-		scaleFactor := factory.Strategy.GetEntityCount(region)
-		for idx := uint32(0); idx < scaleFactor; idx++ {
-			cloned, err := factory.EntityFactory.CreateScaledRegion(region, idx)
-			if err != nil {
-				return err
-			}
+		group := factory.newScaleGroup(m, EntityTypeRegion, region.Id, region)
+		if _, err := factory.scaleRegion(group, 0, factory.getEntityCount(m, group.Key, region)); err != nil {
+			return err
+		}
+	}
 
-			if _, found := m.Regions[cloned.Id]; found {
-				return errors.Errorf("region with id %v already exists. Either set scale to 1 instead of %v or change the id", cloned.Id, scaleFactor)
-			}
+	return nil
+}
 
-			m.Regions[cloned.Id] = cloned
-			factory.markScaled(cloned)
+func (factory *ScaleFactory) scaleRegion(group *ScaleGroup, from, to uint32) ([]*Region, error) {
+	region := group.Source.(*Region)
+	m := region.GetModel()
+
+	var result []*Region
+	for idx := from; idx < to; idx++ {
+		cloned, err := factory.EntityFactory.CreateScaledRegion(region, idx)
+		if err != nil {
+			return nil, err
 		}
+
+		if _, found := m.Regions[cloned.Id]; found {
+			return nil, errors.Errorf("region with id %v already exists. Either set scale to 1 instead of %v or change the id", cloned.Id, to)
+		}
+
+		m.Regions[cloned.Id] = cloned
+		factory.markScaled(cloned)
+		group.members = append(group.members, cloned)
+		result = append(result, cloned)
 	}
 
-	return nil
+	return result, nil
 }
 
 func (factory *ScaleFactory) isParentScaled(entity Entity) bool {
@@ -86,78 +104,137 @@ func (factory *ScaleFactory) markScaled(entity Entity) {
 	entity.GetScope().Defaults["__scaled__"] = struct{}{}
 }
 
+func (factory *ScaleFactory) clearScaled(entity Entity) {
+	delete(entity.GetScope().Defaults, "__scaled__")
+	if clearer, ok := factory.Strategy.(ScaleMarkerClearer); ok {
+		clearer.ClearScaleMarker(entity)
+	}
+}
+
 func (factory *ScaleFactory) ProcessHosts(m *Model) error {
+	var regions []*Region
+	m.RangeSortedRegions(func(id string, region *Region) {
+		regions = append(regions, region)
+	})
+	_, err := factory.processHosts(regions)
+	return err
+}
+
+// processHosts scales the hosts of the given regions, returning the hosts created.
+func (factory *ScaleFactory) processHosts(regions []*Region) ([]*Host, error) {
 	var scaledHosts []*Host
 
-	m.RangeSortedRegions(func(id string, region *Region) {
+	for _, region := range regions {
 		region.RangeSortedHosts(func(id string, host *Host) {
 			if factory.isParentScaled(host) || factory.Strategy.IsScaled(host) {
 				region.RemoveHost(host)
 				scaledHosts = append(scaledHosts, host)
 			}
 		})
-	})
+	}
 
+	var result []*Host
 	for _, host := range scaledHosts {
+		group := &ScaleGroup{Source: host}
 		var scaleFactor uint32 = 1
 		if factory.Strategy.IsScaled(host) {
-			scaleFactor = factory.Strategy.GetEntityCount(host)
+			group = factory.newScaleGroup(host.GetModel(), EntityTypeHost, host.Region.Id+"."+host.Id, host)
+			scaleFactor = factory.getEntityCount(host.GetModel(), group.Key, host)
 		}
-		for idx := uint32(0); idx < scaleFactor; idx++ {
-			cloned, err := factory.EntityFactory.CreateScaledHost(host, idx)
-			if err != nil {
-				return err
-			}
+		hosts, err := factory.scaleHost(group, 0, scaleFactor)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, hosts...)
+	}
 
-			if _, found := host.Region.Hosts[cloned.Id]; found {
-				return errors.Errorf("host with id %v > %v already exists. Either set scale to 1 instead of %v or change the id",
-					host.Region.Id, cloned.Id, scaleFactor)
-			}
-			host.Region.Hosts[cloned.Id] = cloned
-			factory.markScaled(cloned)
+	return result, nil
+}
+
+func (factory *ScaleFactory) scaleHost(group *ScaleGroup, from, to uint32) ([]*Host, error) {
+	host := group.Source.(*Host)
+
+	var result []*Host
+	for idx := from; idx < to; idx++ {
+		cloned, err := factory.EntityFactory.CreateScaledHost(host, idx)
+		if err != nil {
+			return nil, err
 		}
+
+		if _, found := host.Region.Hosts[cloned.Id]; found {
+			return nil, errors.Errorf("host with id %v > %v already exists. Either set scale to 1 instead of %v or change the id",
+				host.Region.Id, cloned.Id, to)
+		}
+		host.Region.Hosts[cloned.Id] = cloned
+		factory.markScaled(cloned)
+		group.members = append(group.members, cloned)
+		result = append(result, cloned)
 	}
 
-	return nil
+	return result, nil
 }
 
 func (factory *ScaleFactory) ProcessComponents(m *Model) error {
-	var scaledComponents []*Component
-
+	var hosts []*Host
 	m.RangeSortedRegions(func(id string, region *Region) {
 		region.RangeSortedHosts(func(id string, host *Host) {
-			host.RangeSortedComponents(func(id string, component *Component) {
-				if factory.isParentScaled(component) || factory.Strategy.IsScaled(component) {
-					host.RemoveComponent(component)
-					scaledComponents = append(scaledComponents, component)
-				}
-			})
+			hosts = append(hosts, host)
 		})
 	})
+	return factory.processComponents(hosts)
+}
+
+// processComponents scales the components of the given hosts.
+func (factory *ScaleFactory) processComponents(hosts []*Host) error {
+	var scaledComponents []*Component
+
+	for _, host := range hosts {
+		host.RangeSortedComponents(func(id string, component *Component) {
+			if factory.isParentScaled(component) || factory.Strategy.IsScaled(component) {
+				host.RemoveComponent(component)
+				scaledComponents = append(scaledComponents, component)
+			}
+		})
+	}
 
 	for _, component := range scaledComponents {
+		group := &ScaleGroup{Source: component}
 		var scaleFactor uint32 = 1
 		if factory.Strategy.IsScaled(component) {
-			scaleFactor = factory.Strategy.GetEntityCount(component)
+			group = factory.newScaleGroup(component.GetModel(), EntityTypeComponent, component.GetPathId(), component)
+			scaleFactor = factory.getEntityCount(component.GetModel(), group.Key, component)
 		}
-		for idx := uint32(0); idx < scaleFactor; idx++ {
-			cloned, err := factory.EntityFactory.CreateScaledComponent(component, idx)
-			if err != nil {
-				return err
-			}
-
-			if _, found := component.Host.Components[cloned.Id]; found {
-				return errors.Errorf("component with id %v > %v > %v already exists. Either set scale to 1 instead of %v or change the id",
-					component.Host.Region.Id, component.Host.Id, cloned.Id, scaleFactor)
-			}
-			component.Host.Components[cloned.Id] = cloned
-			factory.markScaled(cloned)
+		if _, err := factory.scaleComponent(group, 0, scaleFactor); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+func (factory *ScaleFactory) scaleComponent(group *ScaleGroup, from, to uint32) ([]*Component, error) {
+	component := group.Source.(*Component)
+
+	var result []*Component
+	for idx := from; idx < to; idx++ {
+		cloned, err := factory.EntityFactory.CreateScaledComponent(component, idx)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, found := component.Host.Components[cloned.Id]; found {
+			return nil, errors.Errorf("component with id %v > %v > %v already exists. Either set scale to 1 instead of %v or change the id",
+				component.Host.Region.Id, component.Host.Id, cloned.Id, to)
+		}
+		component.Host.Components[cloned.Id] = cloned
+		factory.markScaled(cloned)
+		group.members = append(group.members, cloned)
+		result = append(result, cloned)
+	}
+
+	return result, nil
+}
+
 type DefaultScaleEntityFactory struct{}
 
 func (self DefaultScaleEntityFactory) CreateScaledRegion(source *Region, scaleIndex uint32) (*Region, error) {
@@ -166,6 +243,7 @@ func (self DefaultScaleEntityFactory) CreateScaledRegion(source *Region, scaleIn
 	templater := &Templater{data: cloned}
 	newKey := templater.TemplatizeString(source.Id)
 	cloned.init(newKey, source.GetModel())
+	source.GetModel().claimRecordedIndex(cloned)
 	templater.TemplatizeRegion(cloned)
 
 	if templater.HasError() {
@@ -181,6 +259,7 @@ func (self DefaultScaleEntityFactory) CreateScaledHost(source *Host, scaleIndex
 	templater := &Templater{data: cloned}
 	newKey := templater.TemplatizeString(source.Id)
 	cloned.init(newKey, source.GetRegion())
+	source.GetModel().claimRecordedIndex(cloned)
 	templater.TemplatizeHost(cloned)
 
 	if templater.HasError() {
@@ -196,6 +275,7 @@ func (self DefaultScaleEntityFactory) CreateScaledComponent(source *Component, s
 	templater := &Templater{data: cloned}
 	newKey := templater.TemplatizeString(source.Id)
 	cloned.init(newKey, source.GetHost())
+	source.GetModel().claimRecordedIndex(cloned)
 	templater.TemplatizeComponent(cloned)
 
 	if templater.HasError() {
@@ -0,0 +1,221 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A ScaleGroup is the set of regions, hosts or components stamped out from one scaled entity by a
+// ScaleFactory. Its desired count can be changed while the model is in use, see Grow and Shrink.
+type ScaleGroup struct {
+	// Key identifies the group: the path of the scaled entity, with its templated id, such as
+	// us-east-1.router-{{ .ScaleIndex }}
+	Key        string
+	EntityType string
+	// Source is the scaled entity the members are cloned from. It is not part of the model
+	Source  Entity
+	factory *ScaleFactory
+	members []Entity
+}
+
+// Members returns the entities of the group, in scale index order.
+func (group *ScaleGroup) Members() []Entity {
+	return append([]Entity(nil), group.members...)
+}
+
+// Count returns the number of entities in the group.
+func (group *ScaleGroup) Count() uint32 {
+	return uint32(len(group.members))
+}
+
+// Grow clones the source until the group has count entities, scaling any scaled entities nested
+// in the new ones, and returns the new entities. The new entities are initialized with the
+// indexes recorded for them, if any, and Model.BindIndexes records the indexes of the others.
+func (group *ScaleGroup) Grow(count uint32) ([]Entity, error) {
+	from := group.Count()
+	if count <= from {
+		return nil, nil
+	}
+
+	var result []Entity
+	var err error
+	switch group.EntityType {
+	case EntityTypeRegion:
+		var regions []*Region
+		if regions, err = group.factory.scaleRegion(group, from, count); err == nil {
+			var hosts []*Host
+			if hosts, err = group.factory.processHosts(regions); err == nil {
+				err = group.factory.processComponents(hosts)
+			}
+		}
+		for _, region := range regions {
+			result = append(result, region)
+		}
+	case EntityTypeHost:
+		var hosts []*Host
+		if hosts, err = group.factory.scaleHost(group, from, count); err == nil {
+			err = group.factory.processComponents(hosts)
+		}
+		for _, host := range hosts {
+			result = append(result, host)
+		}
+	case EntityTypeComponent:
+		var components []*Component
+		components, err = group.factory.scaleComponent(group, from, count)
+		for _, component := range components {
+			result = append(result, component)
+		}
+	default:
+		return nil, errors.Errorf("unable to scale entities of type %s", group.EntityType)
+	}
+
+	for _, entity := range result {
+		entity.Accept(group.factory.clearScaled)
+	}
+	if err != nil {
+		return result, errors.Wrapf(err, "unable to scale [%s] to %d", group.Key, count)
+	}
+	return result, nil
+}
+
+// Shrink removes the entities beyond count from the model, highest scale index first, along with
+// the scale groups nested in them, and returns the removed entities.
+func (group *ScaleGroup) Shrink(count uint32) []Entity {
+	if count >= group.Count() {
+		return nil
+	}
+
+	removed := group.members[count:]
+	group.members = group.members[:count]
+
+	m := group.Source.GetModel()
+	for i := len(removed) - 1; i >= 0; i-- {
+		path := entityPath(removed[i])
+		switch entity := removed[i].(type) {
+		case *Region:
+			m.RemoveRegion(entity)
+		case *Host:
+			entity.Region.RemoveHost(entity)
+		case *Component:
+			entity.Host.RemoveComponent(entity)
+		}
+		for key := range m.scaleGroups {
+			if strings.HasPrefix(key, path+".") {
+				delete(m.scaleGroups, key)
+			}
+		}
+	}
+	return removed
+}
+
+// GetScaleGroups returns the scale groups of the model, sorted by key.
+func (m *Model) GetScaleGroups() []*ScaleGroup {
+	var result []*ScaleGroup
+	for _, group := range m.scaleGroups {
+		result = append(result, group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// GetScaleGroup returns the scale group with the given key, or nil if there is none.
+func (m *Model) GetScaleGroup(key string) *ScaleGroup {
+	return m.scaleGroups[key]
+}
+
+// BindScales makes the scale groups use the counts recorded in the label, instead of those of the
+// ScaleStrategy. It must be called before the structure factories run. Groups built before it
+// is called, as the YAML loader builds them, are built again at the recorded counts, so that
+// their entities are also templated with the indexes seeded by SeedIndexes.
+func (m *Model) BindScales(l *Label) error {
+	if l.Scales == nil {
+		l.Scales = map[string]uint32{}
+	}
+	m.scales = l.Scales
+
+	groups := m.GetScaleGroups()
+	sort.SliceStable(groups, func(i, j int) bool {
+		return scaleDepth(groups[i].EntityType) < scaleDepth(groups[j].EntityType)
+	})
+	for _, group := range groups {
+		if m.scaleGroups[group.Key] != group {
+			continue // nested in a group built again already
+		}
+		count := group.Count()
+		if recorded, found := m.scales[group.Key]; found {
+			count = recorded
+		}
+		group.Shrink(0)
+		if _, err := group.Grow(count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scaleDepth orders scale groups so that those of regions come before those of the hosts and
+// components which may be nested in them.
+func scaleDepth(entityType string) int {
+	switch entityType {
+	case EntityTypeRegion:
+		return 0
+	case EntityTypeHost:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (factory *ScaleFactory) newScaleGroup(m *Model, entityType, key string, source Entity) *ScaleGroup {
+	group := &ScaleGroup{
+		Key:        key,
+		EntityType: entityType,
+		Source:     source,
+		factory:    factory,
+	}
+	if m.scaleGroups == nil {
+		m.scaleGroups = map[string]*ScaleGroup{}
+	}
+	m.scaleGroups[key] = group
+	return group
+}
+
+func (factory *ScaleFactory) getEntityCount(m *Model, key string, entity Entity) uint32 {
+	if count, found := m.scales[key]; found {
+		return count
+	}
+	return factory.Strategy.GetEntityCount(entity)
+}
+
+// entityPath returns the dotted path of a region, host or component, as used to key scale groups
+// and indexes.
+func entityPath(entity Entity) string {
+	switch e := entity.(type) {
+	case *Host:
+		return e.Region.Id + "." + e.Id
+	case *Component:
+		return e.GetPathId()
+	default:
+		return entity.GetId()
+	}
+}
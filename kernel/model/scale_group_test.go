@@ -0,0 +1,105 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newScaleGroupTestModel() *Model {
+	m := &Model{
+		Id: "test",
+		Regions: Regions{
+			"region-{{ .ScaleIndex }}": {
+				Scope: Scope{Tags: Tags{"scaled"}},
+				Hosts: Hosts{
+					"host-{{ .ScaleIndex }}": {
+						Scope: Scope{Tags: Tags{"scaled"}},
+						Components: Components{
+							"c": {},
+						},
+					},
+				},
+			},
+		},
+	}
+	m.init()
+	return m
+}
+
+func TestScaleGroup_GrowAndShrink(t *testing.T) {
+	req := require.New(t)
+	m := newScaleGroupTestModel()
+	req.NoError(NewScaleFactoryWithDefaultEntityFactory(testScaleStrategy{}).Build(m))
+
+	var keys []string
+	for _, group := range m.GetScaleGroups() {
+		keys = append(keys, group.Key)
+	}
+	req.Equal([]string{
+		"region-0.host-{{ .ScaleIndex }}",
+		"region-1.host-{{ .ScaleIndex }}",
+		"region-2.host-{{ .ScaleIndex }}",
+		"region-{{ .ScaleIndex }}",
+	}, keys)
+
+	hosts := m.GetScaleGroup("region-1.host-{{ .ScaleIndex }}")
+	added, err := hosts.Grow(5)
+	req.NoError(err)
+	req.Len(added, 2)
+	req.Equal("host-4", added[1].GetId())
+	req.Len(m.SelectComponents("region-1 > host-4 > c"), 1)
+	req.Equal(uint32(5), hosts.Count())
+	_, scaled := added[0].GetScope().Defaults["__scaled__"]
+	req.False(scaled)
+
+	removed := hosts.Shrink(1)
+	req.Len(removed, 4)
+	req.Equal("host-1", removed[0].GetId())
+	req.Len(m.Regions["region-1"].Hosts, 1)
+
+	regions := m.GetScaleGroup("region-{{ .ScaleIndex }}")
+	added, err = regions.Grow(4)
+	req.NoError(err)
+	req.Len(added, 1)
+	req.Len(m.Regions["region-3"].Hosts, 3)
+	req.NotNil(m.GetScaleGroup("region-3.host-{{ .ScaleIndex }}"))
+
+	regions.Shrink(1)
+	req.Len(m.Regions, 1)
+	req.Nil(m.GetScaleGroup("region-1.host-{{ .ScaleIndex }}"))
+	req.Nil(m.GetScaleGroup("region-3.host-{{ .ScaleIndex }}"))
+	req.NotNil(m.GetScaleGroup("region-0.host-{{ .ScaleIndex }}"))
+}
+
+func TestScaleGroup_LabelScales(t *testing.T) {
+	req := require.New(t)
+	m := newScaleGroupTestModel()
+	l := &Label{Scales: map[string]uint32{
+		"region-{{ .ScaleIndex }}":        2,
+		"region-1.host-{{ .ScaleIndex }}": 0,
+	}}
+	req.NoError(m.BindScales(l))
+	req.NoError(NewScaleFactoryWithDefaultEntityFactory(testScaleStrategy{}).Build(m))
+
+	req.Len(m.Regions, 2)
+	req.Len(m.Regions["region-0"].Hosts, 3)
+	req.Len(m.Regions["region-1"].Hosts, 0)
+	req.Equal(uint32(0), m.GetScaleGroup("region-1.host-{{ .ScaleIndex }}").Count())
+}
@@ -103,7 +103,16 @@ func (scope *Scope) HasVariable(name string) bool {
 }
 
 func (scope *Scope) GetVariable(name string) (interface{}, bool) {
-	return scope.VariableResolver.Resolve(scope.entity, name, false)
+	val, found := scope.VariableResolver.Resolve(scope.entity, name, false)
+	if found && hasExpressions(val) {
+		resolved, err := resolveExpressions(scope.entity, val)
+		if err != nil {
+			logrus.Warnf("unable to resolve variable [%s] for %s [%s]: %v", name, scope.entity.GetType(), scope.entity.GetId(), err)
+			return nil, false
+		}
+		return resolved, true
+	}
+	return val, found
 }
 
 func (scope *Scope) PutVariable(name string, value interface{}) {
@@ -448,11 +457,12 @@ func (self CmdLineArgVariableResolver) Resolve(entity Entity, name string, _ boo
 			argPrefix := prefix + key + "="
 			if strings.HasPrefix(arg, argPrefix) {
 				result := strings.TrimPrefix(arg, argPrefix)
-				entity.GetModel().VarConfig.ResolverLogger("cmd-line", entity, name, result, true, "prefix=", argPrefix)
+				entity.GetModel().VarConfig.ResolverLogger("cmd-line", entity, name, result, true, "prefix=%v", argPrefix)
 				return result, true
 			}
 		}
 	}
+	entity.GetModel().VarConfig.ResolverLogger("cmd-line", entity, name, nil, false, "arg=%v", key)
 	return nil, false
 }
 
@@ -0,0 +1,355 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	SecretProviderVault   = "vault"
+	SecretProviderDir     = "dir"
+	SecretProviderCommand = "command"
+
+	DefaultVaultPasswordEnv     = "FABLAB_VAULT_PASSWORD"
+	DefaultSecretCommandTimeout = 30 * time.Second
+
+	// secretNamePlaceholder is replaced by the secret name in the arguments of a command provider
+	secretNamePlaceholder = "{name}"
+)
+
+// SecretProviderConfig configures a secret provider in the fablab config. Providers are
+// consulted in the order they are configured, after the bindings and before the model defaults.
+// As the defaults are looked up level by level, from the entity up to the model, providers are
+// asked for every such variable at every level, which is why command providers need a prefix.
+type SecretProviderConfig struct {
+	// Type is one of vault, dir or command
+	Type string `yaml:"type"`
+	// Prefix, if set, limits the provider to variables under it, which are looked up with the
+	// prefix removed. Command providers require one.
+	Prefix string `yaml:"prefix,omitempty"`
+	// Path is the vault file, or the directory of secret files
+	Path string `yaml:"path,omitempty"`
+	// PasswordEnv names the environment variable holding the vault password,
+	// FABLAB_VAULT_PASSWORD by default
+	PasswordEnv string `yaml:"password_env,omitempty"`
+	// PasswordFile, if set, is read for the vault password instead
+	PasswordFile string `yaml:"password_file,omitempty"`
+	// Command is run to look up a secret, with {name} replaced by the secret name, or the name
+	// appended if there is no {name} argument
+	Command []string `yaml:"command,omitempty"`
+}
+
+// NewSecretResolvers creates the configured secret providers.
+func (self *FablabConfig) NewSecretResolvers() ([]VariableResolver, error) {
+	var result []VariableResolver
+	for i, providerConfig := range self.Secrets {
+		resolver, err := providerConfig.NewResolver()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid secret provider %d", i+1)
+		}
+		result = append(result, resolver)
+	}
+	return result, nil
+}
+
+// NewResolver creates the provider described by the config.
+func (self *SecretProviderConfig) NewResolver() (VariableResolver, error) {
+	switch self.Type {
+	case SecretProviderVault:
+		if self.Path == "" {
+			return nil, errors.New("vault secret provider requires a path")
+		}
+		resolver := &VaultSecretResolver{
+			Prefix:       self.Prefix,
+			Path:         expandHome(self.Path),
+			PasswordEnv:  self.PasswordEnv,
+			PasswordFile: expandHome(self.PasswordFile),
+		}
+		if err := resolver.Load(); err != nil {
+			return nil, errors.Wrapf(err, "unable to load secrets from vault [%s]", resolver.Path)
+		}
+		return resolver, nil
+	case SecretProviderDir:
+		if self.Path == "" {
+			return nil, errors.New("dir secret provider requires a path")
+		}
+		return &DirSecretResolver{Prefix: self.Prefix, Path: expandHome(self.Path)}, nil
+	case SecretProviderCommand:
+		if len(self.Command) == 0 {
+			return nil, errors.New("command secret provider requires a command")
+		}
+		if self.Prefix == "" {
+			return nil, errors.New("command secret provider requires a prefix, otherwise it is run for every variable looked up")
+		}
+		return &CommandSecretResolver{Prefix: self.Prefix, Command: self.Command}, nil
+	default:
+		return nil, errors.Errorf("unknown secret provider type '%s', must be one of %s, %s or %s",
+			self.Type, SecretProviderVault, SecretProviderDir, SecretProviderCommand)
+	}
+}
+
+// SecretVariableResolver consults the configured secret providers in order. It holds its
+// providers so that they can be replaced once the fablab config has been loaded.
+type SecretVariableResolver struct {
+	resolvers []VariableResolver
+}
+
+func NewSecretVariableResolver() *SecretVariableResolver {
+	return &SecretVariableResolver{}
+}
+
+func (self *SecretVariableResolver) UpdateResolvers(resolvers []VariableResolver) {
+	self.resolvers = resolvers
+}
+
+func (self *SecretVariableResolver) Resolve(entity Entity, name string, scoped bool) (interface{}, bool) {
+	for _, resolver := range self.resolvers {
+		if val, found := resolver.Resolve(entity, name, scoped); found {
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+// IsSecretResolver returns true if the resolver, as reported to VarConfig.ResolverLogger, is a
+// secret provider, whose values are always redacted.
+func IsSecretResolver(resolver string) bool {
+	return strings.HasPrefix(resolver, "secret-")
+}
+
+// secretKey strips the provider prefix from the name, returning false if the name isn't under it.
+func secretKey(prefix, name string) (string, bool) {
+	if prefix == "" {
+		return name, true
+	}
+	if strings.HasPrefix(name, prefix+".") {
+		return strings.TrimPrefix(name, prefix+"."), true
+	}
+	return "", false
+}
+
+// VaultSecretResolver reads secrets from a vault file, as written by EncryptVault, holding YAML
+// variables. The vault is decrypted by Load, which NewResolver calls so that a missing password
+// or a damaged vault is reported when the provider is created, rather than as missing secrets.
+type VaultSecretResolver struct {
+	Prefix       string
+	Path         string
+	PasswordEnv  string
+	PasswordFile string
+
+	variables Variables
+}
+
+// Load decrypts the vault, replacing the secrets read before.
+func (self *VaultSecretResolver) Load() error {
+	variables, err := self.load()
+	if err != nil {
+		return err
+	}
+	self.variables = variables
+	return nil
+}
+
+func (self *VaultSecretResolver) Resolve(entity Entity, name string, _ bool) (interface{}, bool) {
+	config := entity.GetModel().VarConfig
+	key, ok := secretKey(self.Prefix, name)
+	if !ok {
+		config.ResolverLogger("secret-vault", entity, name, nil, false, "outside prefix=%v", self.Prefix)
+		return nil, false
+	}
+
+	if self.variables == nil {
+		config.ResolverLogger("secret-vault", entity, name, nil, false, "not loaded, path=%v", self.Path)
+		return nil, false
+	}
+
+	val, found := self.variables.Get(config.VariableNameParser(key))
+	config.ResolverLogger("secret-vault", entity, name, val, found, "path=%v", self.Path)
+	return val, found
+}
+
+func (self *VaultSecretResolver) load() (Variables, error) {
+	password, err := self.password()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(self.Path)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := DecryptVault(data, password)
+	if err != nil {
+		return nil, err
+	}
+	variables := Variables{}
+	if err = yaml.Unmarshal(plaintext, &variables); err != nil {
+		return nil, errors.Wrap(err, "vault does not hold YAML variables")
+	}
+	variables.Canonicalize()
+	return variables, nil
+}
+
+func (self *VaultSecretResolver) password() (string, error) {
+	if self.PasswordFile != "" {
+		data, err := os.ReadFile(self.PasswordFile)
+		if err != nil {
+			return "", errors.Wrap(err, "unable to read vault password file")
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	env := self.PasswordEnv
+	if env == "" {
+		env = DefaultVaultPasswordEnv
+	}
+	if password, found := os.LookupEnv(env); found {
+		return password, nil
+	}
+	return "", errors.Errorf("no vault password, set %s", env)
+}
+
+// DirSecretResolver reads secrets from a directory holding one file per secret, as mounted
+// secrets are. A secret named a.b is read from a.b, or failing that from a/b. Trailing newlines
+// are removed.
+type DirSecretResolver struct {
+	Prefix string
+	Path   string
+}
+
+func (self *DirSecretResolver) Resolve(entity Entity, name string, _ bool) (interface{}, bool) {
+	config := entity.GetModel().VarConfig
+	key, ok := secretKey(self.Prefix, name)
+	if !ok {
+		config.ResolverLogger("secret-dir", entity, name, nil, false, "outside prefix=%v", self.Prefix)
+		return nil, false
+	}
+
+	parts := config.VariableNameParser(key)
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			config.ResolverLogger("secret-dir", entity, name, nil, false, "invalid secret name")
+			return nil, false
+		}
+	}
+
+	for _, candidate := range []string{filepath.Join(self.Path, key), filepath.Join(append([]string{self.Path}, parts...)...)} {
+		data, err := os.ReadFile(candidate)
+		if err == nil {
+			val := strings.TrimRight(string(data), "\r\n")
+			config.ResolverLogger("secret-dir", entity, name, val, true, "file=%v", candidate)
+			return val, true
+		}
+		if !os.IsNotExist(err) {
+			logrus.WithError(err).Errorf("unable to read secret [%s]", candidate)
+		}
+	}
+	config.ResolverLogger("secret-dir", entity, name, nil, false, "path=%v", self.Path)
+	return nil, false
+}
+
+// CommandSecretResolver runs an external command, such as pass, to look up secrets. The command
+// prints the secret on stdout, with trailing newlines removed, and exits non-zero if it has no
+// such secret. Results are cached, so each secret is looked up at most once.
+type CommandSecretResolver struct {
+	Prefix  string
+	Command []string
+
+	lock  sync.Mutex
+	cache map[string]*string
+}
+
+func (self *CommandSecretResolver) Resolve(entity Entity, name string, _ bool) (interface{}, bool) {
+	config := entity.GetModel().VarConfig
+	key, ok := secretKey(self.Prefix, name)
+	if !ok {
+		config.ResolverLogger("secret-command", entity, name, nil, false, "outside prefix=%v", self.Prefix)
+		return nil, false
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	result, cached := self.cache[key]
+	if !cached {
+		result = self.run(key)
+		if self.cache == nil {
+			self.cache = map[string]*string{}
+		}
+		self.cache[key] = result
+	}
+
+	if result == nil {
+		config.ResolverLogger("secret-command", entity, name, nil, false, "command=%v", self.Command[0])
+		return nil, false
+	}
+	config.ResolverLogger("secret-command", entity, name, *result, true, "command=%v", self.Command[0])
+	return *result, true
+}
+
+func (self *CommandSecretResolver) run(key string) *string {
+	var args []string
+	substituted := false
+	for _, arg := range self.Command[1:] {
+		if strings.Contains(arg, secretNamePlaceholder) {
+			arg = strings.ReplaceAll(arg, secretNamePlaceholder, key)
+			substituted = true
+		}
+		args = append(args, arg)
+	}
+	if !substituted {
+		args = append(args, key)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultSecretCommandTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, self.Command[0], args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && ctx.Err() == nil {
+			logrus.Debugf("secret command %s found no secret '%s': %s", self.Command[0], key, strings.TrimSpace(stderr.String()))
+		} else {
+			logrus.WithError(err).Errorf("unable to run secret command %s", self.Command[0])
+		}
+		return nil
+	}
+	result := strings.TrimRight(stdout.String(), "\r\n")
+	return &result
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
@@ -0,0 +1,141 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVault_RoundTrip(t *testing.T) {
+	req := require.New(t)
+	plaintext := []byte("db:\n  pass: hunter2\n")
+
+	data, err := EncryptVault(plaintext, "open sesame")
+	req.NoError(err)
+	req.True(strings.HasPrefix(string(data), vaultHeader+"\n"))
+	req.NotContains(string(data), "hunter2")
+
+	decrypted, err := DecryptVault(data, "open sesame")
+	req.NoError(err)
+	req.Equal(plaintext, decrypted)
+
+	_, err = DecryptVault(data, "wrong")
+	req.Error(err)
+
+	tampered := []byte(strings.Replace(string(data), "\n", "\nA", 1))
+	_, err = DecryptVault(tampered, "open sesame")
+	req.Error(err)
+
+	_, err = DecryptVault(plaintext, "open sesame")
+	req.Error(err)
+}
+
+func TestSecretResolvers(t *testing.T) {
+	req := require.New(t)
+	dir := t.TempDir()
+
+	vaultData, err := EncryptVault([]byte("db:\n  pass: hunter2\n"), "open sesame")
+	req.NoError(err)
+	vaultPath := filepath.Join(dir, "secrets.vault")
+	req.NoError(os.WriteFile(vaultPath, vaultData, 0600))
+	passwordPath := filepath.Join(dir, "password")
+	req.NoError(os.WriteFile(passwordPath, []byte("open sesame\n"), 0600))
+
+	secretsDir := filepath.Join(dir, "mounted")
+	req.NoError(os.MkdirAll(filepath.Join(secretsDir, "db"), 0700))
+	req.NoError(os.WriteFile(filepath.Join(secretsDir, "api.token"), []byte("t0ken\n"), 0600))
+	req.NoError(os.WriteFile(filepath.Join(secretsDir, "db", "user"), []byte("admin"), 0600))
+	req.NoError(os.WriteFile(filepath.Join(dir, "outside"), []byte("nope"), 0600))
+
+	config := &FablabConfig{Secrets: []*SecretProviderConfig{
+		{Type: SecretProviderVault, Prefix: "vault", Path: vaultPath, PasswordFile: passwordPath},
+		{Type: SecretProviderDir, Path: secretsDir},
+		{Type: SecretProviderCommand, Prefix: "cmd", Command: []string{"sh", "-c", `[ "$1" = value ] && echo from-command`, "sh", "{name}"}},
+	}}
+	resolvers, err := config.NewSecretResolvers()
+	req.NoError(err)
+
+	m := newTestModel()
+	m.Defaults["db"] = Variables{"user": "default-user"}
+	m.init()
+	m.VarConfig.SecretResolver.UpdateResolvers(resolvers)
+
+	val, found := m.GetVariable("vault.db.pass")
+	req.True(found)
+	req.Equal("hunter2", val)
+
+	val, found = m.GetVariable("api.token")
+	req.True(found)
+	req.Equal("t0ken", val)
+
+	val, found = m.GetVariable("db.user")
+	req.True(found)
+	req.Equal("admin", val, "secret providers should take precedence over model defaults")
+
+	val, found = m.GetVariable("cmd.value")
+	req.True(found)
+	req.Equal("from-command", val)
+
+	_, found = m.GetVariable("db.pass")
+	req.False(found, "vault secrets should only resolve under the vault prefix")
+
+	_, found = m.GetVariable("...outside")
+	req.False(found)
+
+	trace := m.GetScope().TraceVariable("cmd.value")
+	req.True(trace.Secret)
+	req.Equal(RedactedValue, trace.Value)
+	req.Equal("secret-command", trace.Answer().Resolver)
+}
+
+func TestSecretProviderConfig_NewResolver(t *testing.T) {
+	req := require.New(t)
+
+	for _, providerConfig := range []*SecretProviderConfig{
+		{Type: "keyring"},
+		{Type: SecretProviderVault},
+		{Type: SecretProviderDir},
+		{Type: SecretProviderCommand},
+		{Type: SecretProviderCommand, Command: []string{"pass", "show"}},
+	} {
+		_, err := providerConfig.NewResolver()
+		req.Error(err, "expected %+v to be rejected", providerConfig)
+	}
+}
+
+func TestSecretProviderConfig_VaultLoadError(t *testing.T) {
+	req := require.New(t)
+	dir := t.TempDir()
+
+	vaultData, err := EncryptVault([]byte("db:\n  pass: hunter2\n"), "open sesame")
+	req.NoError(err)
+	vaultPath := filepath.Join(dir, "secrets.vault")
+	req.NoError(os.WriteFile(vaultPath, vaultData, 0600))
+	passwordPath := filepath.Join(dir, "password")
+	req.NoError(os.WriteFile(passwordPath, []byte("wrong"), 0600))
+
+	_, err = (&SecretProviderConfig{Type: SecretProviderVault, Path: vaultPath, PasswordFile: passwordPath}).NewResolver()
+	req.ErrorContains(err, "unable to load secrets from vault")
+
+	_, err = (&SecretProviderConfig{Type: SecretProviderVault, Path: vaultPath, PasswordEnv: "FABLAB_TEST_NO_SUCH_PASSWORD"}).NewResolver()
+	req.ErrorContains(err, "no vault password, set FABLAB_TEST_NO_SUCH_PASSWORD")
+}
@@ -19,8 +19,8 @@ package model
 import (
 	"fmt"
 	"github.com/openziti/fablab/kernel/lib/parallel"
-	"github.com/openziti/foundation/v2/stringz"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"sort"
 	"strings"
 )
@@ -68,7 +68,10 @@ func (m *Model) GetActions() []string {
 }
 
 func (m *Model) SelectRegions(spec string) []*Region {
-	matcher := compileSelector(spec)
+	return m.selectRegions(compileSelector(spec))
+}
+
+func (m *Model) selectRegions(matcher EntityMatcher) []*Region {
 	var regions []*Region
 	m.RangeSortedRegions(func(id string, region *Region) {
 		if matcher(region) {
@@ -79,7 +82,11 @@ func (m *Model) SelectRegions(spec string) []*Region {
 }
 
 func (m *Model) SelectRegion(spec string) (*Region, error) {
-	regions := m.SelectRegions(spec)
+	matcher, err := ParseSelector(spec)
+	if err != nil {
+		return nil, err
+	}
+	regions := m.selectRegions(matcher)
 	if len(regions) == 1 {
 		return regions[0], nil
 	} else {
@@ -96,7 +103,10 @@ func (m *Model) MustSelectRegion(spec string) *Region {
 }
 
 func (m *Model) SelectHosts(spec string) []*Host {
-	matcher := compileSelector(spec)
+	return m.selectHosts(compileSelector(spec))
+}
+
+func (m *Model) selectHosts(matcher EntityMatcher) []*Host {
 	var hosts []*Host
 	m.RangeSortedRegions(func(id string, region *Region) {
 		region.RangeSortedHosts(func(id string, host *Host) {
@@ -109,7 +119,11 @@ func (m *Model) SelectHosts(spec string) []*Host {
 }
 
 func (m *Model) MustSelectHosts(spec string, minCount int) ([]*Host, error) {
-	hosts := m.SelectHosts(spec)
+	matcher, err := ParseSelector(spec)
+	if err != nil {
+		return nil, err
+	}
+	hosts := m.selectHosts(matcher)
 	if len(hosts) < minCount {
 		return nil, errors.Errorf("[%s] matched [%d] hosts, expected at least %v", spec, len(hosts), minCount)
 	}
@@ -117,7 +131,11 @@ func (m *Model) MustSelectHosts(spec string, minCount int) ([]*Host, error) {
 }
 
 func (m *Model) SelectHost(spec string) (*Host, error) {
-	hosts := m.SelectHosts(spec)
+	matcher, err := ParseSelector(spec)
+	if err != nil {
+		return nil, err
+	}
+	hosts := m.selectHosts(matcher)
 	if len(hosts) == 1 {
 		return hosts[0], nil
 	} else {
@@ -134,7 +152,10 @@ func (m *Model) MustSelectHost(spec string) *Host {
 }
 
 func (m *Model) SelectComponents(spec string) []*Component {
-	matcher := compileSelector(spec)
+	return m.selectComponents(compileSelector(spec))
+}
+
+func (m *Model) selectComponents(matcher EntityMatcher) []*Component {
 	var components []*Component
 	m.RangeSortedRegions(func(id string, region *Region) {
 		region.RangeSortedHosts(func(id string, host *Host) {
@@ -172,7 +193,11 @@ func (m *Model) MustSelectComponent(spec string) *Component {
 }
 
 func (m *Model) SelectComponent(spec string) (*Component, error) {
-	components := m.SelectComponents(spec)
+	matcher, err := ParseSelector(spec)
+	if err != nil {
+		return nil, err
+	}
+	components := m.selectComponents(matcher)
 	if len(components) == 1 {
 		return components[0], nil
 	} else {
@@ -181,7 +206,42 @@ func (m *Model) SelectComponent(spec string) (*Component, error) {
 }
 
 func (m *Model) ForEachHost(spec string, concurrency int, f func(host *Host) error) error {
-	hosts := m.SelectHosts(spec)
+	matcher, err := ParseSelector(spec)
+	if err != nil {
+		return err
+	}
+	return forEachHost(m.selectHosts(matcher), concurrency, f)
+}
+
+// SelectHostsIn returns the hosts matching spec which are among the given hosts
+func (m *Model) SelectHostsIn(spec string, among []*Host) []*Host {
+	return m.selectHostsIn(compileSelector(spec), among)
+}
+
+func (m *Model) selectHostsIn(matcher EntityMatcher, among []*Host) []*Host {
+	included := map[*Host]bool{}
+	for _, host := range among {
+		included[host] = true
+	}
+	var hosts []*Host
+	for _, host := range m.selectHosts(matcher) {
+		if included[host] {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// ForEachHostIn is ForEachHost limited to the given hosts
+func (m *Model) ForEachHostIn(spec string, among []*Host, concurrency int, f func(host *Host) error) error {
+	matcher, err := ParseSelector(spec)
+	if err != nil {
+		return err
+	}
+	return forEachHost(m.selectHostsIn(matcher, among), concurrency, f)
+}
+
+func forEachHost(hosts []*Host, concurrency int, f func(host *Host) error) error {
 	var tasks []parallel.Task
 	for _, host := range hosts {
 		boundHost := host
@@ -193,7 +253,11 @@ func (m *Model) ForEachHost(spec string, concurrency int, f func(host *Host) err
 }
 
 func (m *Model) ForEachComponent(spec string, concurrency int, f func(c *Component) error) error {
-	components := m.SelectComponents(spec)
+	matcher, err := ParseSelector(spec)
+	if err != nil {
+		return err
+	}
+	components := m.selectComponents(matcher)
 	return m.ForEachComponentIn(components, concurrency, f)
 }
 
@@ -231,124 +295,68 @@ func (m EntityMatcher) And(m2 EntityMatcher) EntityMatcher {
 	}
 }
 
+// compileSelector parses a selector for the Select functions which don't return errors. Malformed
+// selectors are logged and match nothing.
 func compileSelector(in string) EntityMatcher {
-	parts := strings.Split(in, ">")
-
-	// stack them in reverse order so we can evaluate target entity up the parents
-	matchers := make([]EntityMatcher, len(parts))
-	for idx, part := range parts {
-		matcher := compileLevelSelector(part)
-		matchers[len(parts)-(idx+1)] = matcher
-	}
-
-	return func(entity Entity) bool {
-		current := entity
-		for _, matcher := range matchers {
-			if current == nil || !matcher(current) {
-				return false
-			}
-			current = current.GetParentEntity()
+	matcher, err := ParseSelector(in)
+	if err != nil {
+		logrus.Error(err)
+		return func(Entity) bool {
+			return false
 		}
-		return true
 	}
+	return matcher
 }
 
-func compileLevelSelector(in string) EntityMatcher {
-	specs := strings.Split(in, ",")
-	result := compileSelectorGroup(specs[0])
-	for _, spec := range specs[1:] {
-		result = result.Or(compileSelectorGroup(spec))
-	}
-	return result
+func Selector(levels ...string) string {
+	return strings.Join(levels, " > ")
 }
 
-func compileSelectorGroup(in string) EntityMatcher {
-	parts := strings.Split(in, " ")
-	parts = stringz.Remove(parts, "")
-	result := specToMatcher(parts[0])
-	for _, part := range parts[1:] {
-		result = result.And(specToMatcher(part))
-	}
-	return result
+// SelectorMatch is an entity a selector matched, with how each of its levels matched.
+type SelectorMatch struct {
+	Entity  Entity
+	Reasons []SelectorReason
 }
 
-func specToMatcher(spec string) EntityMatcher {
-	spec = strings.TrimSpace(spec)
-	if spec == "*" {
-		return func(Entity) bool {
-			return true
-		}
+// ExplainSelector returns the regions, hosts or components, as given by entityType, the selector
+// matches, in sorted order, with the reasons each matched.
+func (m *Model) ExplainSelector(spec string, entityType string) ([]SelectorMatch, error) {
+	switch entityType {
+	case EntityTypeRegion, EntityTypeHost, EntityTypeComponent:
+	default:
+		return nil, errors.Errorf("unsupported entity type '%s', expected %s, %s or %s",
+			entityType, EntityTypeRegion, EntityTypeHost, EntityTypeComponent)
 	}
 
-	var entityType string
-	var entityId string
-	var entityTags []string
-
-	if !strings.HasPrefix(spec, SelectorTagPrefix) && !strings.HasPrefix(spec, SelectorIdPrefix) {
-		if idx := strings.Index(spec, SelectorIdPrefix); idx > 0 {
-			entityType = spec[0:idx]
-			spec = spec[idx:]
-		} else if idx := strings.Index(spec, SelectorTagPrefix); idx > 0 {
-			entityType = spec[0:idx]
-			spec = spec[idx:]
-		} else {
-			entityId = spec
-			spec = ""
-		}
-	}
-
-	if strings.HasPrefix(spec, SelectorIdPrefix) {
-		if idx := strings.Index(spec, SelectorTagPrefix); idx > 0 {
-			entityId = spec[1:idx]
-			spec = spec[idx:]
-		} else {
-			entityId = spec[1:]
-			spec = ""
-		}
-	}
-
-	if strings.HasPrefix(spec, SelectorTagPrefix) {
-		entityTags = strings.Split(spec, SelectorTagPrefix)
-		entityTags = stringz.Remove(entityTags, "")
+	selector, err := CompileSelector(spec)
+	if err != nil {
+		return nil, err
 	}
 
-	var matcher EntityMatcher
-	if entityId != "" {
-		matcher = func(e Entity) bool {
-			return e.GetId() == entityId
+	var candidates []Entity
+	m.RangeSortedRegions(func(_ string, region *Region) {
+		if entityType == EntityTypeRegion {
+			candidates = append(candidates, region)
+			return
 		}
-	}
-
-	for _, tag := range entityTags {
-		tagMatcher := newTagSelector(tag)
-		if matcher == nil {
-			matcher = tagMatcher
-		} else {
-			matcher = matcher.And(tagMatcher)
-		}
-	}
+		region.RangeSortedHosts(func(_ string, host *Host) {
+			if entityType == EntityTypeHost {
+				candidates = append(candidates, host)
+				return
+			}
+			if entityType == EntityTypeComponent {
+				host.RangeSortedComponents(func(_ string, component *Component) {
+					candidates = append(candidates, component)
+				})
+			}
+		})
+	})
 
-	if matcher == nil {
-		return func(e Entity) bool {
-			return false
+	var result []SelectorMatch
+	for _, candidate := range candidates {
+		if reasons, matched := selector.Explain(candidate); matched {
+			result = append(result, SelectorMatch{Entity: candidate, Reasons: reasons})
 		}
 	}
-
-	if entityType == "" {
-		return matcher
-	}
-
-	return func(e Entity) bool {
-		return e.Matches(entityType, matcher)
-	}
-}
-
-func newTagSelector(tag string) EntityMatcher {
-	return func(e Entity) bool {
-		return stringz.Contains(e.GetScope().Tags, tag)
-	}
-}
-
-func Selector(levels ...string) string {
-	return strings.Join(levels, " > ")
+	return result, nil
 }
@@ -0,0 +1,631 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SelectorError reports a syntax error in a selector. Column is the 1-based position of the
+// offending character.
+type SelectorError struct {
+	Selector string
+	Column   int
+	Message  string
+}
+
+func (e *SelectorError) Error() string {
+	return fmt.Sprintf("invalid selector '%s' at column %d: %s", e.Selector, e.Column, e.Message)
+}
+
+// ParseSelector compiles a selector into a matcher, returning a *SelectorError if it's malformed.
+//
+// A selector is a list of levels separated by '>', each matching a parent of the entity matched
+// by the level after it. A level is a list of alternatives separated by ',', and an alternative
+// is a list of terms separated by spaces, all of which must match. A term is
+//
+//   - any entity
+//     !TERM                      entities TERM doesn't match
+//     [TYPE]ID[.TAG...][PRED...] entities with the id and tags satisfying the predicates
+//
+// TYPE is one of model, region, host, component, parent, child, selfOrParent (or ^),
+// selfOrChild or *, and applies the rest of the term to the related entities of that type, so
+// `host.edge` selects the components of hosts tagged edge. ID is either a glob such as
+// `router-*`, a regex such as `/router-\d+/`, or either preceded by '#'. TAG is a tag or a glob
+// matching one. PRED tests an attribute, see EntityAttribute, or a variable:
+//
+//	[instanceType=t3.*]        attribute matches the glob
+//	[instanceType!=t3.*]       attribute doesn't match the glob
+//	[version~=^v1\.]           attribute matches the regex
+//	[var.site=us-east-1a]      variable site matches the glob
+//	[publicIp]                 attribute is set
+//
+// Values containing spaces or ']' can be quoted with double or single quotes.
+func ParseSelector(spec string) (EntityMatcher, error) {
+	selector, err := CompileSelector(spec)
+	if err != nil {
+		return nil, err
+	}
+	return selector.Matches, nil
+}
+
+// CompileSelector parses a selector like ParseSelector, keeping its structure so matches can be
+// explained.
+func CompileSelector(spec string) (*CompiledSelector, error) {
+	p := &selectorParser{spec: spec}
+	return p.parse()
+}
+
+// CompiledSelector is a parsed selector.
+type CompiledSelector struct {
+	spec   string
+	levels []*selectorLevel
+}
+
+// selectorLevel holds the alternatives of one level, any of which must match.
+type selectorLevel struct {
+	text         string
+	alternatives []selectorAlternative
+}
+
+// selectorAlternative holds terms which must all match.
+type selectorAlternative []*selectorTerm
+
+// selectorTerm applies matcher to the entity, or to its related entities of entityType.
+type selectorTerm struct {
+	text       string
+	entityType string
+	negated    bool
+	matcher    EntityMatcher
+	tags       []func(string) bool
+}
+
+// SelectorReason explains how a level of a selector matched. Entity is the entity the level was
+// evaluated against, the selected entity for the last level and its ancestors for the ones before.
+type SelectorReason struct {
+	Level  string
+	Entity Entity
+	Terms  []SelectorTermMatch
+}
+
+// SelectorTermMatch names a term and the entity satisfying it, which is a related entity for
+// terms such as `host.edge`, along with the entity's tags the term's tag patterns matched.
+// Entity is nil for negated terms.
+type SelectorTermMatch struct {
+	Term   string
+	Entity Entity
+	Tags   []string
+}
+
+func (s *CompiledSelector) String() string {
+	return s.spec
+}
+
+// Matches returns true if the selector matches the entity. Unlike Explain, it doesn't collect
+// how the levels matched.
+func (s *CompiledSelector) Matches(entity Entity) bool {
+	current := entity
+	for i := len(s.levels) - 1; i >= 0; i-- {
+		if current == nil || !s.levels[i].matches(current) {
+			return false
+		}
+		current = current.GetParentEntity()
+	}
+	return true
+}
+
+// Explain returns how each level of the selector matched the entity, or false if it doesn't.
+func (s *CompiledSelector) Explain(entity Entity) ([]SelectorReason, bool) {
+	reasons := make([]SelectorReason, len(s.levels))
+	current := entity
+	for i := len(s.levels) - 1; i >= 0; i-- {
+		if current == nil {
+			return nil, false
+		}
+		terms, matched := s.levels[i].match(current)
+		if !matched {
+			return nil, false
+		}
+		reasons[i] = SelectorReason{Level: s.levels[i].text, Entity: current, Terms: terms}
+		current = current.GetParentEntity()
+	}
+	return reasons, true
+}
+
+// matches returns true if any of the level's alternatives matches the entity.
+func (l *selectorLevel) matches(entity Entity) bool {
+	for _, alternative := range l.alternatives {
+		matched := true
+		for _, term := range alternative {
+			if _, ok := term.match(entity); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *selectorLevel) match(entity Entity) ([]SelectorTermMatch, bool) {
+	for _, alternative := range l.alternatives {
+		var terms []SelectorTermMatch
+		matched := true
+		for _, term := range alternative {
+			related, ok := term.match(entity)
+			if !ok {
+				matched = false
+				break
+			}
+			terms = append(terms, SelectorTermMatch{Term: term.text, Entity: related, Tags: term.matchedTags(related)})
+		}
+		if matched {
+			return terms, true
+		}
+	}
+	return nil, false
+}
+
+// matchedTags returns the tags of the entity the term's tag patterns match.
+func (t *selectorTerm) matchedTags(entity Entity) []string {
+	if entity == nil {
+		return nil
+	}
+	var result []string
+	for _, tag := range entity.GetScope().Tags {
+		for _, matches := range t.tags {
+			if matches(tag) {
+				result = append(result, tag)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// match returns the entity satisfying the term, or nil if the term is negated.
+func (t *selectorTerm) match(entity Entity) (Entity, bool) {
+	var related Entity
+	if t.entityType == "" {
+		if t.matcher(entity) {
+			related = entity
+		}
+	} else {
+		entity.Matches(t.entityType, func(e Entity) bool {
+			if t.matcher(e) {
+				related = e
+				return true
+			}
+			return false
+		})
+	}
+	if t.negated {
+		return nil, related == nil
+	}
+	return related, related != nil
+}
+
+// selectorTypes are the entity types a term can be prefixed with.
+var selectorTypes = []string{
+	EntityTypeModel, EntityTypeRegion, EntityTypeHost, EntityTypeComponent,
+	EntityTypeParent, EntityTypeChild, EntityTypeSelfOrParent, EntityTypeSelfOrChild,
+}
+
+type selectorParser struct {
+	spec string
+	pos  int
+}
+
+func (p *selectorParser) errorf(pos int, format string, args ...interface{}) error {
+	return &SelectorError{Selector: p.spec, Column: pos + 1, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *selectorParser) atEnd() bool {
+	return p.pos >= len(p.spec)
+}
+
+func (p *selectorParser) peek() byte {
+	if p.atEnd() {
+		return 0
+	}
+	return p.spec[p.pos]
+}
+
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for !p.atEnd() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+// describe names the character at pos for error messages.
+func (p *selectorParser) describe() string {
+	if p.atEnd() {
+		return "end of selector"
+	}
+	return fmt.Sprintf("'%c'", p.peek())
+}
+
+func (p *selectorParser) parse() (*CompiledSelector, error) {
+	p.skipSpace()
+	if p.atEnd() {
+		return nil, p.errorf(p.pos, "empty selector")
+	}
+
+	result := &CompiledSelector{spec: p.spec}
+	for {
+		level, err := p.parseLevel()
+		if err != nil {
+			return nil, err
+		}
+		result.levels = append(result.levels, level)
+
+		p.skipSpace()
+		if p.atEnd() {
+			break
+		}
+		if p.peek() != '>' {
+			return nil, p.errorf(p.pos, "unexpected %s", p.describe())
+		}
+		p.pos++
+	}
+	return result, nil
+}
+
+func (p *selectorParser) parseLevel() (*selectorLevel, error) {
+	p.skipSpace()
+	start := p.pos
+	level := &selectorLevel{}
+	for {
+		alternative, err := p.parseAlternative()
+		if err != nil {
+			return nil, err
+		}
+		level.alternatives = append(level.alternatives, alternative)
+
+		p.skipSpace()
+		if p.peek() != ',' {
+			level.text = strings.TrimSpace(p.spec[start:p.pos])
+			return level, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *selectorParser) parseAlternative() (selectorAlternative, error) {
+	p.skipSpace()
+	term, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	result := selectorAlternative{term}
+	for {
+		start := p.pos
+		spaced := p.skipSpace()
+		if p.atEnd() || p.peek() == '>' || p.peek() == ',' {
+			return result, nil
+		}
+		if !spaced {
+			return nil, p.errorf(start, "unexpected %s", p.describe())
+		}
+		term, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, term)
+	}
+}
+
+func (p *selectorParser) parseTerm() (*selectorTerm, error) {
+	start := p.pos
+	negated := false
+	for p.peek() == '!' {
+		negated = !negated
+		p.pos++
+	}
+	term, err := p.parseSimple()
+	if err != nil {
+		return nil, err
+	}
+	term.negated = negated
+	term.text = p.spec[start:p.pos]
+	return term, nil
+}
+
+func isQualifierStart(c byte) bool {
+	return c == '#' || c == '.' || c == '['
+}
+
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '-' || c == '_' || c == '*' || c == '?'
+}
+
+func (p *selectorParser) parseSimple() (*selectorTerm, error) {
+	start := p.pos
+	var entityType string
+	var matchers []EntityMatcher
+	var tags []func(string) bool
+
+	switch c := p.peek(); {
+	case c == '*' && (p.pos+1 >= len(p.spec) || !isWordChar(p.spec[p.pos+1])):
+		p.pos++
+		if !isQualifierStart(p.peek()) {
+			return &selectorTerm{matcher: func(Entity) bool { return true }}, nil
+		}
+		entityType = EntityTypeAny
+	case c == '^':
+		p.pos++
+		if !isQualifierStart(p.peek()) {
+			return nil, p.errorf(p.pos, "expected '#', '.' or '[' after '^'")
+		}
+		entityType = EntityTypeSelfOrParentSymbol
+	case c == '/':
+		idMatcher, err := p.parseIdPattern()
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, idMatcher)
+	case isWordChar(c):
+		word := p.readWord()
+		if isQualifierStart(p.peek()) {
+			if !containsString(selectorTypes, word) {
+				return nil, p.errorf(start, "unknown entity type '%s', expected one of %s, or '#%s' to match an id",
+					word, strings.Join(selectorTypes, ", "), word)
+			}
+			entityType = word
+		} else {
+			matchers = append(matchers, idMatcher(globMatcher(word)))
+		}
+	case isQualifierStart(c):
+		// untyped term such as #ctrl or .edge
+	default:
+		return nil, p.errorf(p.pos, "expected a selector but found %s", p.describe())
+	}
+
+	hasId := len(matchers) > 0
+	for isQualifierStart(p.peek()) {
+		switch p.peek() {
+		case '#':
+			if hasId {
+				return nil, p.errorf(p.pos, "only one id may be given")
+			}
+			p.pos++
+			idMatcher, err := p.parseIdPattern()
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, idMatcher)
+			hasId = true
+		case '.':
+			p.pos++
+			tagStart := p.pos
+			tag := p.readWord()
+			if tag == "" {
+				return nil, p.errorf(tagStart, "expected a tag after '.' but found %s", p.describe())
+			}
+			tags = append(tags, globMatcher(tag))
+			matchers = append(matchers, tagMatcher(globMatcher(tag)))
+		case '[':
+			predicate, err := p.parsePredicate(entityType)
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, predicate)
+		}
+	}
+
+	if len(matchers) == 0 {
+		return nil, p.errorf(p.pos, "expected an id, tag or predicate after '%s'", p.spec[start:p.pos])
+	}
+
+	matcher := matchers[0]
+	for _, m := range matchers[1:] {
+		matcher = matcher.And(m)
+	}
+	return &selectorTerm{entityType: entityType, matcher: matcher, tags: tags}, nil
+}
+
+func (p *selectorParser) readWord() string {
+	start := p.pos
+	for !p.atEnd() && isWordChar(p.peek()) {
+		p.pos++
+	}
+	return p.spec[start:p.pos]
+}
+
+// parseIdPattern parses a glob or a /regex/ matched against entity ids.
+func (p *selectorParser) parseIdPattern() (EntityMatcher, error) {
+	if p.peek() == '/' {
+		re, err := p.parseRegex()
+		if err != nil {
+			return nil, err
+		}
+		return idMatcher(re.MatchString), nil
+	}
+	start := p.pos
+	word := p.readWord()
+	if word == "" {
+		return nil, p.errorf(start, "expected an id but found %s", p.describe())
+	}
+	return idMatcher(globMatcher(word)), nil
+}
+
+func (p *selectorParser) parseRegex() (*regexp.Regexp, error) {
+	start := p.pos
+	p.pos++ // opening '/'
+	var pattern strings.Builder
+	for {
+		if p.atEnd() {
+			return nil, p.errorf(start, "unterminated regex")
+		}
+		c := p.peek()
+		p.pos++
+		if c == '/' {
+			break
+		}
+		if c == '\\' && p.peek() == '/' {
+			c = '/'
+			p.pos++
+		} else if c == '\\' && !p.atEnd() {
+			pattern.WriteByte(c)
+			c = p.peek()
+			p.pos++
+		}
+		pattern.WriteByte(c)
+	}
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, p.errorf(start, "invalid regex: %v", err)
+	}
+	return re, nil
+}
+
+func (p *selectorParser) parsePredicate(entityType string) (EntityMatcher, error) {
+	open := p.pos
+	p.pos++ // '['
+	p.skipSpace()
+
+	keyStart := p.pos
+	for !p.atEnd() && (isWordChar(p.peek()) || p.peek() == '.') && p.peek() != '*' && p.peek() != '?' {
+		p.pos++
+	}
+	key := p.spec[keyStart:p.pos]
+	if key == "" {
+		return nil, p.errorf(keyStart, "expected an attribute name but found %s", p.describe())
+	}
+	varName, isVar := strings.CutPrefix(key, "var.")
+	if !isVar {
+		if known, ok := entityAttributes[entityType]; ok && !containsString(known, key) {
+			return nil, p.errorf(keyStart, "unknown %s attribute '%s', expected one of %s or var.NAME",
+				entityType, key, strings.Join(EntityAttributeNames(entityType), ", "))
+		}
+	} else if varName == "" {
+		return nil, p.errorf(keyStart, "expected a variable name after 'var.'")
+	}
+
+	value := func(e Entity) (string, bool) {
+		if isVar {
+			if v, found := e.GetScope().GetVariable(varName); found && v != nil {
+				return fmt.Sprint(v), true
+			}
+			return "", false
+		}
+		return EntityAttribute(e, key)
+	}
+
+	p.skipSpace()
+	var op string
+	switch {
+	case strings.HasPrefix(p.spec[p.pos:], "!="), strings.HasPrefix(p.spec[p.pos:], "~="):
+		op = p.spec[p.pos : p.pos+2]
+	case p.peek() == '=':
+		op = "="
+	case p.peek() == ']':
+		p.pos++
+		return func(e Entity) bool {
+			v, found := value(e)
+			return found && v != ""
+		}, nil
+	default:
+		return nil, p.errorf(p.pos, "expected '=', '!=', '~=' or ']' but found %s", p.describe())
+	}
+	p.pos += len(op)
+	p.skipSpace()
+
+	valueStart := p.pos
+	expected, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.peek() != ']' {
+		return nil, p.errorf(open, "unterminated predicate, expected ']'")
+	}
+	p.pos++
+
+	var matches func(string) bool
+	if op == "~=" {
+		re, err := regexp.Compile(expected)
+		if err != nil {
+			return nil, p.errorf(valueStart, "invalid regex: %v", err)
+		}
+		matches = re.MatchString
+	} else {
+		matches = globMatcher(expected)
+	}
+
+	return func(e Entity) bool {
+		v, found := value(e)
+		if !found {
+			return false
+		}
+		if op == "!=" {
+			return !matches(v)
+		}
+		return matches(v)
+	}, nil
+}
+
+func (p *selectorParser) parseValue() (string, error) {
+	if quote := p.peek(); quote == '"' || quote == '\'' {
+		start := p.pos
+		p.pos++
+		end := strings.IndexByte(p.spec[p.pos:], quote)
+		if end < 0 {
+			return "", p.errorf(start, "unterminated quoted value")
+		}
+		value := p.spec[p.pos : p.pos+end]
+		p.pos += end + 1
+		return value, nil
+	}
+	start := p.pos
+	for !p.atEnd() && p.peek() != ']' {
+		p.pos++
+	}
+	return strings.TrimSpace(p.spec[start:p.pos]), nil
+}
+
+func idMatcher(matches func(string) bool) EntityMatcher {
+	return func(e Entity) bool {
+		return matches(e.GetId())
+	}
+}
+
+func tagMatcher(matches func(string) bool) EntityMatcher {
+	return func(e Entity) bool {
+		for _, tag := range e.GetScope().Tags {
+			if matches(tag) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// globMatcher matches whole values against a pattern in which '*' matches any run of characters
+// and '?' any single character.
+func globMatcher(pattern string) func(string) bool {
+	if !strings.ContainsAny(pattern, "*?") {
+		return func(s string) bool {
+			return s == pattern
+		}
+	}
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	re := regexp.MustCompile("^" + expr + "$")
+	return re.MatchString
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -273,3 +273,144 @@ func TestModel_SelectComponents(t *testing.T) {
 	components = model.SelectComponents("region#initiator component.ctrl")
 	req.Equal(1, len(components))
 }
+
+func componentIds(components []*Component) []string {
+	var ids []string
+	for _, c := range components {
+		ids = append(ids, c.GetId())
+	}
+	return ids
+}
+
+func TestModel_SelectExtendedSyntax(t *testing.T) {
+	req := require.New(t)
+	model := createTestModel()
+	model.Regions["initiator"].Hosts["ctrl"].InstanceType = "c5.large"
+	model.Regions["initiator"].Hosts["initiator"].InstanceType = "t3.micro"
+	model.Regions["terminator"].Hosts["terminator"].InstanceType = "t3.small"
+	model.Regions["initiator"].Hosts["ctrl"].Components["ctrl"].Type = &GenericComponent{Type: "generic", Version: "v1.2.0"}
+	model.Regions["terminator"].Defaults = Variables{"site": "west"}
+	model.init()
+
+	req.Equal([]string{"client1", "server1"}, componentIds(model.SelectComponents("!.edge-router !#ctrl")))
+	req.Equal([]string{"client1", "initiator", "server1", "terminator"}, componentIds(model.SelectComponents("!host.ctrl")))
+	req.Equal([]string{"client1"}, componentIds(model.SelectComponents("client*")))
+	req.Equal([]string{"client1", "server1"}, componentIds(model.SelectComponents("#/^(client|server)\\d$/")))
+	req.Equal([]string{"initiator", "terminator"}, componentIds(model.SelectComponents(".edge-*")))
+
+	req.Equal([]string{"ctrl"}, componentIds(model.SelectComponents("component[type=generic]")))
+	req.Equal([]string{"ctrl"}, componentIds(model.SelectComponents("[version~=^v1\\.]")))
+	req.Equal([]string{"initiator", "terminator"}, componentIds(model.SelectComponents("host[instanceType=t3.*]")))
+	req.Equal([]string{"ctrl", "initiator", "terminator"}, componentIds(model.SelectComponents("host[instanceType]")))
+	req.Equal([]string{"client1", "ctrl", "server1"}, componentIds(model.SelectComponents("host[instanceType != 't3.*']")))
+	req.Equal([]string{"server1", "terminator"}, componentIds(model.SelectComponents("host[var.site=west]")))
+	req.Equal([]string{"client1", "ctrl", "initiator"}, componentIds(model.SelectComponents("region[site=us-east-*] > * > *")))
+
+	hosts := model.SelectHosts("region[region=us-west-1] !.sdk-app")
+	req.Equal(1, len(hosts))
+	req.Equal("terminator", hosts[0].GetId())
+}
+
+func TestParseSelector_Errors(t *testing.T) {
+	req := require.New(t)
+
+	for spec, expected := range map[string]string{
+		"":                          "column 1: empty selector",
+		"hots.edge":                 "column 1: unknown entity type 'hots'",
+		"#a#b":                      "column 3: only one id may be given",
+		".edge >":                   "column 8: expected a selector but found end of selector",
+		"host[instanceType=t3":      "column 5: unterminated predicate",
+		"host[type=ziti-router]":    "column 6: unknown host attribute 'type'",
+		"component[type ziti]":      "column 16: expected '=', '!=', '~=' or ']'",
+		"#/router-(/":               "column 2: invalid regex",
+		"[version~=(]":              "column 11: invalid regex",
+		"region.a>host.b)":          "column 16: unexpected ')'",
+		"!":                         "column 2: expected a selector",
+		"host.":                     "column 6: expected a tag after '.'",
+		"host[var.=x]":              "column 6: expected a variable name",
+		"component[type='ziti]":     "column 16: unterminated quoted value",
+		"host#ctrl[publicIp] ^":     "column 22: expected '#', '.' or '[' after '^'",
+		"host#ctrl[publicIp]^.edge": "column 20: unexpected '^'",
+	} {
+		_, err := ParseSelector(spec)
+		req.Error(err, spec)
+		var selectorErr *SelectorError
+		req.ErrorAs(err, &selectorErr, spec)
+		req.Contains(err.Error(), expected, spec)
+	}
+
+	model := createTestModel()
+	model.init()
+	_, err := model.SelectHost("hots#ctrl")
+	req.ErrorContains(err, "unknown entity type 'hots'")
+	req.Empty(model.SelectHosts("hots#ctrl"))
+	req.Error(model.ForEachComponent("#ctrl >", 1, func(*Component) error { return nil }))
+}
+
+func TestModel_ExplainSelector(t *testing.T) {
+	req := require.New(t)
+	model := createTestModel()
+	model.init()
+
+	matches, err := model.ExplainSelector("region.region-first > host.edge-*,host.ctrl > !#ctrl", EntityTypeComponent)
+	req.NoError(err)
+	req.Equal(1, len(matches))
+	req.Equal("initiator", matches[0].Entity.GetId())
+
+	reasons := matches[0].Reasons
+	req.Equal(3, len(reasons))
+	req.Equal("region.region-first", reasons[0].Level)
+	req.Equal("initiator", reasons[0].Entity.GetId())
+	req.Equal([]string{"region-first"}, reasons[0].Terms[0].Tags)
+	req.Equal("host.edge-*,host.ctrl", reasons[1].Level)
+	req.Equal(EntityTypeHost, reasons[1].Entity.GetType())
+	req.Equal([]string{"edge-router"}, reasons[1].Terms[0].Tags)
+	req.Equal("!#ctrl", reasons[2].Terms[0].Term)
+	req.Nil(reasons[2].Terms[0].Entity)
+
+	matches, err = model.ExplainSelector("host.edge-router", EntityTypeComponent)
+	req.NoError(err)
+	req.Equal(2, len(matches))
+	req.Equal(EntityTypeHost, matches[0].Reasons[0].Terms[0].Entity.GetType())
+
+	matches, err = model.ExplainSelector(".sdk-app", EntityTypeHost)
+	req.NoError(err)
+	req.Equal(2, len(matches))
+
+	matches, err = model.ExplainSelector("host.none", EntityTypeRegion)
+	req.NoError(err)
+	req.Empty(matches)
+
+	_, err = model.ExplainSelector(".sdk-app", "model")
+	req.ErrorContains(err, "unsupported entity type 'model'")
+}
+
+func TestCompiledSelector_MatchesAgreesWithExplain(t *testing.T) {
+	req := require.New(t)
+	model := createTestModel()
+	model.init()
+
+	for _, spec := range []string{
+		"*",
+		"region.region-first > host.edge-*,host.ctrl > !#ctrl",
+		"host.edge-router",
+		".sdk-app",
+		"region[site=us-east-*] > * > *",
+		"!host.ctrl",
+	} {
+		selector, err := CompileSelector(spec)
+		req.NoError(err, spec)
+		model.RangeSortedRegions(func(_ string, region *Region) {
+			region.RangeSortedHosts(func(_ string, host *Host) {
+				for _, entity := range []Entity{region, host} {
+					_, explained := selector.Explain(entity)
+					req.Equal(explained, selector.Matches(entity), "%s: %s", spec, entity.GetId())
+				}
+				host.RangeSortedComponents(func(_ string, component *Component) {
+					_, explained := selector.Explain(component)
+					req.Equal(explained, selector.Matches(component), "%s: %s", spec, component.GetId())
+				})
+			})
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// StageParams holds the parameters a stage is declared with in a YAML model. Parameters consisting
+// of a single `${...}` reference hold a ResolvedString, typed by Decode.
+type StageParams map[string]interface{}
+
+// Decode decodes the parameters into target using its yaml tags. Parameters target doesn't
+// declare are reported as errors.
+func (p StageParams) Decode(target interface{}) error {
+	if len(p) == 0 {
+		return nil
+	}
+	data, err := yaml.Marshal(TypeResolvedStrings(map[string]interface{}(p), reflect.TypeOf(target)))
+	if err != nil {
+		return fmt.Errorf("failed to encode params: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(data, target); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			// line numbers refer to the re-encoded params rather than the source file
+			var msgs []string
+			for _, msg := range typeErr.Errors {
+				msgs = append(msgs, paramsLinePrefix.ReplaceAllString(msg, ""))
+			}
+			return fmt.Errorf("invalid params: %s", strings.Join(msgs, "; "))
+		}
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	return nil
+}
+
+var paramsLinePrefix = regexp.MustCompile(`^line \d+: `)
+
+// StageBuilder creates a stage from its declared parameters. Actions are declared as a list of
+// stages, which are executed in order, so builders serve both.
+type StageBuilder func(params StageParams) (Stage, error)
+
+var (
+	stageRegistryMu sync.RWMutex
+	stageRegistry   = make(map[string]StageBuilder)
+)
+
+// RegisterStageBuilder registers a builder for a given stage type name.
+// e.g. RegisterStageBuilder("sleep", func(p StageParams) (Stage, error) { ... })
+func RegisterStageBuilder(typeName string, builder StageBuilder) {
+	stageRegistryMu.Lock()
+	defer stageRegistryMu.Unlock()
+	if _, dup := stageRegistry[typeName]; dup {
+		panic("RegisterStageBuilder called twice for " + typeName)
+	}
+	stageRegistry[typeName] = builder
+}
+
+// BuildStage creates a stage of the named type from the given parameters.
+func BuildStage(typeName string, params StageParams) (Stage, error) {
+	stageRegistryMu.RLock()
+	builder, ok := stageRegistry[typeName]
+	stageRegistryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("stage type '%s' not found in registry", typeName)
+	}
+	return builder(params)
+}
+
+// BuildStageWhenRun returns a stage built from the parameters each time it's executed, once the
+// Expressions in them have been resolved against the run's model. It's used for parameters
+// referencing values only known after express, such as host IPs.
+func BuildStageWhenRun(typeName string, params StageParams) Stage {
+	return &deferredStage{typeName: typeName, params: params}
+}
+
+type deferredStage struct {
+	typeName string
+	params   StageParams
+}
+
+func (stage *deferredStage) build(run Run) (Stage, error) {
+	params, err := ResolveTypedExpressions(run.GetModel(), stage.params)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve params of stage '%s' (%w)", stage.typeName, err)
+	}
+	return BuildStage(stage.typeName, params.(StageParams))
+}
+
+func (stage *deferredStage) Execute(run Run) error {
+	built, err := stage.build(run)
+	if err != nil {
+		return err
+	}
+	return built.Execute(run)
+}
+
+// ExecuteOnHosts limits the built stage to the hosts if it's a HostStage, and runs it for the
+// whole model otherwise
+func (stage *deferredStage) ExecuteOnHosts(run Run, hosts []*Host) error {
+	built, err := stage.build(run)
+	if err != nil {
+		return err
+	}
+	if hostStage, ok := built.(HostStage); ok {
+		return hostStage.ExecuteOnHosts(run, hosts)
+	}
+	logrus.Warnf("stage '%s' can't be limited to hosts, running it for the whole model", stage.typeName)
+	return built.Execute(run)
+}
+
+// ListStageTypes returns all registered stage type names, sorted.
+func ListStageTypes() []string {
+	stageRegistryMu.RLock()
+	defer stageRegistryMu.RUnlock()
+	names := make([]string, 0, len(stageRegistry))
+	for name := range stageRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasStageType checks if a stage type is registered.
+func HasStageType(typeName string) bool {
+	stageRegistryMu.RLock()
+	defer stageRegistryMu.RUnlock()
+	_, ok := stageRegistry[typeName]
+	return ok
+}
+
+// StageSequence is an action executing its stages in order, stopping at the first failure.
+type StageSequence []Stage
+
+func (s StageSequence) Execute(run Run) error {
+	for i, stage := range s {
+		if err := stage.Execute(run); err != nil {
+			return fmt.Errorf("error executing step %d (%w)", i+1, err)
+		}
+	}
+	return nil
+}
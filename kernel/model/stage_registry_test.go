@@ -0,0 +1,86 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStageParams_Decode(t *testing.T) {
+	p := struct {
+		Hosts    string        `yaml:"hosts"`
+		Duration time.Duration `yaml:"duration"`
+	}{Hosts: "*"}
+
+	if err := (StageParams{"duration": "5s"}).Decode(&p); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if p.Hosts != "*" || p.Duration != 5*time.Second {
+		t.Errorf("unexpected params %+v", p)
+	}
+
+	err := (StageParams{"hostz": "*"}).Decode(&p)
+	if err == nil || !strings.Contains(err.Error(), "field hostz not found") || strings.Contains(err.Error(), "line ") {
+		t.Errorf("expected unknown param error without line numbers, got %v", err)
+	}
+}
+
+func TestStageParams_DecodeResolvedStrings(t *testing.T) {
+	type inner struct {
+		Enabled bool `yaml:"enabled"`
+	}
+	p := struct {
+		Version string            `yaml:"version"`
+		Count   int               `yaml:"count"`
+		Answer  string            `yaml:"answer"`
+		Labels  map[string]string `yaml:"labels"`
+		Extra   interface{}       `yaml:"extra"`
+		Inner   inner             `yaml:",inline"`
+	}{}
+
+	params := StageParams{
+		"version": ResolvedString("1.10"),
+		"count":   ResolvedString("3"),
+		"answer":  ResolvedString("yes"),
+		"labels":  map[interface{}]interface{}{"mode": ResolvedString("0755")},
+		"extra":   ResolvedString("on"),
+		"enabled": ResolvedString("yes"),
+	}
+	if err := params.Decode(&p); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if p.Version != "1.10" || p.Answer != "yes" || p.Labels["mode"] != "0755" || p.Extra != "on" {
+		t.Errorf("expected strings to be kept as resolved, got %+v", p)
+	}
+	if p.Count != 3 || !p.Inner.Enabled {
+		t.Errorf("expected typed values in non-string fields, got %+v", p)
+	}
+}
+
+func TestBuildStage_NotFound(t *testing.T) {
+	if HasStageType("nonexistent") {
+		t.Fatal("expected nonexistent stage type not to be registered")
+	}
+	if _, err := BuildStage("nonexistent", nil); err == nil {
+		t.Fatal("expected error for nonexistent stage type")
+	}
+}
+
+func TestStageSequence_StopsAtFailure(t *testing.T) {
+	var executed []int
+	step := func(i int, err error) Stage {
+		return StageActionF(func(Run) error {
+			executed = append(executed, i)
+			return err
+		})
+	}
+
+	err := StageSequence{step(1, nil), step(2, errors.New("boom")), step(3, nil)}.Execute(nil)
+	if err == nil || !strings.Contains(err.Error(), "step 2") {
+		t.Errorf("expected failure of step 2, got %v", err)
+	}
+	if len(executed) != 2 {
+		t.Errorf("expected execution to stop after step 2, got %v", executed)
+	}
+}
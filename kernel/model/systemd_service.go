@@ -0,0 +1,295 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// DefaultSystemdRestartPolicy is the Restart setting of units which don't configure one
+const DefaultSystemdRestartPolicy = "on-failure"
+
+var systemdRestartPolicies = map[string]bool{
+	"no":          true,
+	"always":      true,
+	"on-success":  true,
+	"on-failure":  true,
+	"on-abnormal": true,
+	"on-abort":    true,
+	"on-watchdog": true,
+}
+
+// SystemdServiceType implements ComponentType for a binary run as a systemd service. The binary
+// and the rendered config templates are staged into the kit, synced to the host with it, and run
+// by a generated unit which is installed when the host is initialized.
+//
+// Args, env values and config templates are Go templates, given a SystemdTemplateData.
+type SystemdServiceType struct {
+	Version string `yaml:"version,omitempty"`
+	// Unit is the name of the systemd unit, fablab-<component id> if not set
+	Unit string `yaml:"unit,omitempty"`
+	// Binary is the local path of the binary to stage into the kit's bin directory
+	Binary string            `yaml:"binary"`
+	Args   []string          `yaml:"args,omitempty"`
+	Env    map[string]string `yaml:"env,omitempty"`
+	// Configs are rendered into the component's directory under the kit's cfg directory
+	Configs []SystemdConfigTemplate `yaml:"configs,omitempty"`
+	// User runs the service, the host's ssh user if not set
+	User string `yaml:"user,omitempty"`
+	// Restart is the systemd restart policy, DefaultSystemdRestartPolicy if not set
+	Restart    string `yaml:"restart,omitempty"`
+	RestartSec uint32 `yaml:"restartSec,omitempty"`
+}
+
+// SystemdConfigTemplate is a local template rendered into a config file for the service
+type SystemdConfigTemplate struct {
+	Template string `yaml:"template"`
+	// Name is the file name in the component's config directory, the template's file name if not set
+	Name string `yaml:"name,omitempty"`
+}
+
+func (t *SystemdConfigTemplate) GetName() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return filepath.Base(t.Template)
+}
+
+// SystemdTemplateData is given to the args, env and config templates of a systemd service.
+// BinDir and PkiDir are the kit directories on the host, and ConfigDir is the directory the
+// component's configs are rendered to, cfg/<component path id> in the kit.
+type SystemdTemplateData struct {
+	Component *Component
+	Host      *Host
+	Model     *Model
+	BinDir    string
+	ConfigDir string
+	PkiDir    string
+}
+
+func (t *SystemdServiceType) Label() string {
+	return "systemd-service"
+}
+
+func (t *SystemdServiceType) GetVersion() string {
+	return t.Version
+}
+
+func (t *SystemdServiceType) SetVersion(version string) {
+	t.Version = version
+}
+
+func (t *SystemdServiceType) Dump() any {
+	return t
+}
+
+// GetUnitName returns the name of the systemd unit of the component, without the .service suffix
+func (t *SystemdServiceType) GetUnitName(c *Component) string {
+	if t.Unit != "" {
+		return strings.TrimSuffix(t.Unit, ".service")
+	}
+	return "fablab-" + c.Id
+}
+
+func (t *SystemdServiceType) GetRestartPolicy() string {
+	if t.Restart == "" {
+		return DefaultSystemdRestartPolicy
+	}
+	return t.Restart
+}
+
+// Validate checks that the service has a binary and a restart policy systemd understands
+func (t *SystemdServiceType) Validate(c *Component) []ValidationIssue {
+	var result []ValidationIssue
+	if t.Binary == "" {
+		result = append(result, ValidationErrorf("binary", "binary is required"))
+	}
+	if !systemdRestartPolicies[t.GetRestartPolicy()] {
+		result = append(result, ValidationErrorf("restart", "invalid restart policy '%s'", t.Restart))
+	}
+	for i, config := range t.Configs {
+		if config.Template == "" {
+			result = append(result, ValidationErrorf(fmt.Sprintf("configs[%d].template", i), "template is required"))
+		}
+	}
+	return result
+}
+
+func (t *SystemdServiceType) templateData(c *Component) *SystemdTemplateData {
+	kitDir := systemdRemoteKitDir(c.GetHost())
+	return &SystemdTemplateData{
+		Component: c,
+		Host:      c.GetHost(),
+		Model:     c.GetModel(),
+		BinDir:    path.Join(kitDir, BuildBinDir),
+		ConfigDir: path.Join(kitDir, BuildConfigDir, c.GetPathId()),
+		PkiDir:    path.Join(kitDir, BuildPkiDir),
+	}
+}
+
+// UnitFile returns the contents of the systemd unit running the component
+func (t *SystemdServiceType) UnitFile(c *Component) (string, error) {
+	data := t.templateData(c)
+
+	execStart := []string{systemdExecArg(path.Join(data.BinDir, filepath.Base(t.Binary)))}
+	for _, arg := range t.Args {
+		expanded, err := renderSystemdTemplate("arg", arg, data)
+		if err != nil {
+			return "", err
+		}
+		execStart = append(execStart, systemdExecArg(expanded))
+	}
+
+	user := t.User
+	if user == "" {
+		user = c.GetHost().GetSshUser()
+	}
+
+	out := &bytes.Buffer{}
+	_, _ = fmt.Fprintf(out, "[Unit]\nDescription=fablab %s\nAfter=network-online.target\nWants=network-online.target\n\n", c.GetPath())
+	_, _ = fmt.Fprintf(out, "[Service]\nType=simple\nUser=%s\nWorkingDirectory=%s\n", user, path.Dir(data.BinDir))
+
+	var keys []string
+	for key := range t.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := renderSystemdTemplate("env "+key, t.Env[key], data)
+		if err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(out, "Environment=%s\n", systemdQuote(key+"="+value))
+	}
+
+	_, _ = fmt.Fprintf(out, "ExecStart=%s\nRestart=%s\n", strings.Join(execStart, " "), t.GetRestartPolicy())
+	if t.RestartSec > 0 {
+		_, _ = fmt.Fprintf(out, "RestartSec=%d\n", t.RestartSec)
+	}
+	_, _ = fmt.Fprintf(out, "\n[Install]\nWantedBy=multi-user.target\n")
+	return out.String(), nil
+}
+
+// StageFiles copies the binary into the kit and renders the config templates into the component's
+// config directory, so that components using the same templates don't overwrite each other's configs
+func (t *SystemdServiceType) StageFiles(run Run, c *Component) error {
+	if t.Binary != "" {
+		binary, err := os.ReadFile(t.Binary)
+		if err != nil {
+			return fmt.Errorf("unable to read binary for '%s' (%w)", c.GetPath(), err)
+		}
+		dst := filepath.Join(run.GetBinDir(), filepath.Base(t.Binary))
+		if err = os.WriteFile(dst, binary, 0755); err != nil {
+			return fmt.Errorf("unable to stage binary for '%s' (%w)", c.GetPath(), err)
+		}
+	}
+
+	data := t.templateData(c)
+	for _, config := range t.Configs {
+		src, err := os.ReadFile(config.Template)
+		if err != nil {
+			return fmt.Errorf("unable to read config template for '%s' (%w)", c.GetPath(), err)
+		}
+		rendered, err := renderSystemdTemplate(config.Template, string(src), data)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(run.GetConfigDir(), c.GetPathId(), config.GetName())
+		if err = os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return err
+		}
+		if err = os.WriteFile(dst, []byte(rendered), 0600); err != nil {
+			return fmt.Errorf("unable to stage config '%s' for '%s' (%w)", config.GetName(), c.GetPath(), err)
+		}
+	}
+	return nil
+}
+
+// InitializeHost installs the unit, after the kit has been synced to the host
+func (t *SystemdServiceType) InitializeHost(run Run, c *Component) error {
+	unit, err := t.UnitFile(c)
+	if err != nil {
+		return err
+	}
+
+	host := c.GetHost()
+	name := t.GetUnitName(c) + ".service"
+	tmpPath := "/tmp/" + name
+	if err = host.SendData([]byte(unit), tmpPath); err != nil {
+		return fmt.Errorf("unable to send unit '%s' to host '%s' (%w)", name, host.GetPath(), err)
+	}
+
+	install := fmt.Sprintf("sudo mv %s /etc/systemd/system/%s && sudo systemctl daemon-reload && sudo systemctl enable %s",
+		tmpPath, name, name)
+	if output, err := host.ExecLogged(install); err != nil {
+		return fmt.Errorf("unable to install unit '%s' on host '%s' (%w): %s", name, host.GetPath(), err, output)
+	}
+	return nil
+}
+
+func (t *SystemdServiceType) IsRunning(run Run, c *Component) (bool, error) {
+	output, err := c.GetHost().ExecLogged(fmt.Sprintf("systemctl is-active %s || true", t.GetUnitName(c)))
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) == "active", nil
+}
+
+// Start restarts the unit, so that a newly synced binary or config is picked up
+func (t *SystemdServiceType) Start(run Run, c *Component) error {
+	if output, err := c.GetHost().ExecLogged("sudo systemctl restart " + t.GetUnitName(c)); err != nil {
+		return fmt.Errorf("failed to start '%s' (%w): %s", t.GetUnitName(c), err, output)
+	}
+	return nil
+}
+
+func (t *SystemdServiceType) Stop(run Run, c *Component) error {
+	if output, err := c.GetHost().ExecLogged("sudo systemctl stop " + t.GetUnitName(c)); err != nil {
+		return fmt.Errorf("failed to stop '%s' (%w): %s", t.GetUnitName(c), err, output)
+	}
+	return nil
+}
+
+// systemdRemoteKitDir is where the kit is synced to on the host
+func systemdRemoteKitDir(host *Host) string {
+	return fmt.Sprintf("/home/%s/fablab", host.GetSshUser())
+}
+
+func renderSystemdTemplate(name, text string, data *SystemdTemplateData) (string, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("error parsing template [%s] (%w)", name, err)
+	}
+	out := &bytes.Buffer{}
+	if err = t.Execute(out, data); err != nil {
+		return "", fmt.Errorf("error rendering template [%s] (%w)", name, err)
+	}
+	return out.String(), nil
+}
+
+// systemdQuote quotes a word of a unit setting if it contains whitespace or quotes, and escapes
+// % so that it isn't expanded as a specifier
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// systemdExecArg quotes a word of ExecStart, also escaping $ so that it isn't substituted from the
+// environment
+func systemdExecArg(s string) string {
+	return systemdQuote(strings.ReplaceAll(s, "$", "$$"))
+}
+
+func init() {
+	RegisterComponentType("systemd-service", func() ComponentType {
+		return &SystemdServiceType{}
+	})
+}
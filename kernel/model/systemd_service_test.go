@@ -0,0 +1,144 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newSystemdTestModel(service *SystemdServiceType) *Model {
+	m := &Model{
+		Id: "test",
+		Scope: Scope{
+			Defaults: Variables{
+				"credentials": Variables{
+					"ssh": Variables{"username": "ubuntu"},
+				},
+				"greeting": "hello",
+			},
+		},
+		Regions: Regions{
+			"us-east-1": {
+				Hosts: Hosts{
+					"host1": {
+						PublicIp: "10.0.0.1",
+						Components: Components{
+							"echo": {Type: service},
+						},
+					},
+				},
+			},
+		},
+	}
+	bindings = Variables{}
+	m.init()
+	return m
+}
+
+func TestSystemdServiceUnitFile(t *testing.T) {
+	req := require.New(t)
+
+	service := &SystemdServiceType{
+		Binary:     "build/echo-server",
+		Args:       []string{"run", "{{ .ConfigDir }}/echo.yml", "--name", "echo {{ .Component.Id }}", "--prompt", "%h$", "$HOME"},
+		Env:        map[string]string{"PORT": "8080", "HOST": "{{ .Host.PublicIp }}", "LOAD": "50%"},
+		Restart:    "always",
+		RestartSec: 5,
+	}
+	m := newSystemdTestModel(service)
+	c := m.Regions["us-east-1"].Hosts["host1"].Components["echo"]
+
+	req.Equal("fablab-echo", service.GetUnitName(c))
+	unit, err := service.UnitFile(c)
+	req.NoError(err)
+	req.Equal(`[Unit]
+Description=fablab us-east-1 > host1 > echo
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+User=ubuntu
+WorkingDirectory=/home/ubuntu/fablab
+Environment=HOST=10.0.0.1
+Environment=LOAD=50%%
+Environment=PORT=8080
+ExecStart=/home/ubuntu/fablab/bin/echo-server run /home/ubuntu/fablab/cfg/us-east-1.host1.echo/echo.yml --name "echo echo" --prompt %%h$$ $$HOME
+Restart=always
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`, unit)
+
+	service.Unit = "echo.service"
+	service.User = "echo"
+	service.Restart = ""
+	unit, err = service.UnitFile(c)
+	req.NoError(err)
+	req.Equal("echo", service.GetUnitName(c))
+	req.Contains(unit, "User=echo\n")
+	req.Contains(unit, "Restart=on-failure\n")
+
+	service.Args = []string{"{{ .Missing }}"}
+	_, err = service.UnitFile(c)
+	req.ErrorContains(err, "error rendering template [arg]")
+}
+
+func TestSystemdServiceStageFiles(t *testing.T) {
+	req := require.New(t)
+
+	src := t.TempDir()
+	binary := filepath.Join(src, "echo-server")
+	req.NoError(os.WriteFile(binary, []byte("#!/bin/sh\n"), 0755))
+	tmpl := filepath.Join(src, "echo.yml.tmpl")
+	req.NoError(os.WriteFile(tmpl, []byte("listen: {{ .Host.PublicIp }}:8080\npki: {{ .PkiDir }}\n"), 0644))
+
+	service := &SystemdServiceType{
+		Binary:  binary,
+		Configs: []SystemdConfigTemplate{{Template: tmpl, Name: "echo.yml"}, {Template: tmpl}},
+	}
+	m := newSystemdTestModel(service)
+	m.Regions["us-east-1"].Hosts["host2"] = &Host{
+		PublicIp:   "10.0.0.2",
+		Components: Components{"echo": {Type: service}},
+	}
+	m.init()
+	c := m.Regions["us-east-1"].Hosts["host1"].Components["echo"]
+	c2 := m.Regions["us-east-1"].Hosts["host2"].Components["echo"]
+
+	run, err := NewRun(m, nil, &InstanceConfig{Id: "test", WorkingDirectory: t.TempDir()})
+	req.NoError(err)
+	req.NoError(service.StageFiles(run, c))
+	req.NoError(service.StageFiles(run, c2))
+
+	info, err := os.Stat(filepath.Join(run.GetBinDir(), "echo-server"))
+	req.NoError(err)
+	req.NotZero(info.Mode().Perm() & 0100)
+
+	for _, name := range []string{"echo.yml", "echo.yml.tmpl"} {
+		data, err := os.ReadFile(filepath.Join(run.GetConfigDir(), "us-east-1.host1.echo", name))
+		req.NoError(err)
+		req.Equal("listen: 10.0.0.1:8080\npki: /home/ubuntu/fablab/pki\n", string(data))
+
+		data, err = os.ReadFile(filepath.Join(run.GetConfigDir(), "us-east-1.host2.echo", name))
+		req.NoError(err)
+		req.Equal("listen: 10.0.0.2:8080\npki: /home/ubuntu/fablab/pki\n", string(data))
+	}
+}
+
+func TestSystemdServiceValidate(t *testing.T) {
+	req := require.New(t)
+
+	service := &SystemdServiceType{Restart: "sometimes", Configs: []SystemdConfigTemplate{{Name: "a.yml"}}}
+	issues := service.Validate(nil)
+	req.Len(issues, 3)
+	req.Equal("binary", issues[0].Field)
+	req.Equal("restart", issues[1].Field)
+	req.Equal("configs[0].template", issues[2].Field)
+
+	service = &SystemdServiceType{Binary: "echo-server"}
+	req.Empty(service.Validate(nil))
+}
@@ -0,0 +1,153 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type ValidationSeverity string
+
+const (
+	ValidationSeverityError   ValidationSeverity = "error"
+	ValidationSeverityWarning ValidationSeverity = "warning"
+)
+
+// A ValidationIssue is a problem with how a component is used in its model. Field optionally
+// names the offending config field of the component type.
+type ValidationIssue struct {
+	Severity ValidationSeverity
+	Field    string
+	Message  string
+}
+
+// ValidationErrorf creates an issue which prevents the model from being used
+func ValidationErrorf(field string, format string, args ...interface{}) ValidationIssue {
+	return ValidationIssue{Severity: ValidationSeverityError, Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// ValidationWarningf creates an issue which is reported, but doesn't prevent the model from being used
+func ValidationWarningf(field string, format string, args ...interface{}) ValidationIssue {
+	return ValidationIssue{Severity: ValidationSeverityWarning, Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// A ValidatingComponent checks semantic rules which span the model, such as requiring other
+// components to be present. It is called once for each component using the type, after the
+// model has been initialized and scaled.
+type ValidatingComponent interface {
+	ComponentType
+
+	// Validate returns the problems found with the component
+	Validate(c *Component) []ValidationIssue
+}
+
+// A ListeningComponent declares the TCP ports it listens on, so components sharing a host can be
+// checked for conflicts before anything is deployed
+type ListeningComponent interface {
+	ComponentType
+
+	// GetListenPorts returns the ports the component is configured to listen on. Ports which
+	// aren't known yet, such as those of configs which haven't been rendered, are left out
+	GetListenPorts(ctx *ValidationContext, c *Component) []uint16
+}
+
+// ValidationContext holds what validations can consult besides the model itself
+type ValidationContext struct {
+	// BuildDir is the build directory of the instance the model is deployed to, whose kit holds
+	// the rendered configs. It is empty when validating a model which isn't the bound instance's.
+	BuildDir string
+}
+
+// NewValidationContext returns the context for validating m, which includes the bound instance's
+// build directory only if m is that instance's model
+func NewValidationContext(m *Model) *ValidationContext {
+	ctx := &ValidationContext{}
+	if instanceConfig != nil && instanceConfig.Model != "" && instanceConfig.Model == m.Id {
+		ctx.BuildDir = instanceConfig.WorkingDirectory
+	}
+	return ctx
+}
+
+// ComponentIssue is a ValidationIssue found on a specific component
+type ComponentIssue struct {
+	ValidationIssue
+	Component *Component
+}
+
+// ValidateComponents runs the validations of every ValidatingComponent in the model and checks
+// for ListeningComponents on the same host listening on the same port. Issues are ordered by
+// component.
+func (m *Model) ValidateComponents(ctx *ValidationContext) []ComponentIssue {
+	var components []*Component
+	m.Accept(func(entity Entity) {
+		if c, ok := entity.(*Component); ok {
+			components = append(components, c)
+		}
+	})
+	sort.Slice(components, func(i, j int) bool {
+		return components[i].GetPath() < components[j].GetPath()
+	})
+
+	var issues []ComponentIssue
+	listeners := map[*Host]map[uint16]*Component{}
+	for _, c := range components {
+		if v, ok := c.Type.(ValidatingComponent); ok {
+			for _, issue := range v.Validate(c) {
+				issues = append(issues, ComponentIssue{ValidationIssue: issue, Component: c})
+			}
+		}
+
+		if l, ok := c.Type.(ListeningComponent); ok {
+			ports := listeners[c.Host]
+			if ports == nil {
+				ports = map[uint16]*Component{}
+				listeners[c.Host] = ports
+			}
+			for _, port := range l.GetListenPorts(ctx, c) {
+				if other, found := ports[port]; found {
+					issues = append(issues, ComponentIssue{
+						ValidationIssue: ValidationErrorf("", "port %d is already used by component '%s' on host '%s'",
+							port, other.Id, c.Host.Id),
+						Component: c,
+					})
+					continue
+				}
+				ports[port] = c
+			}
+		}
+	}
+	return issues
+}
+
+// hasComponentOfType returns true if the model contains a component with the given type label
+func (m *Model) hasComponentOfType(label string) bool {
+	found := false
+	m.Accept(func(entity Entity) {
+		if c, ok := entity.(*Component); ok && c.Type != nil && c.Type.Label() == label {
+			found = true
+		}
+	})
+	return found
+}
+
+// hasPublicAddress returns true if the host has a static public IP, or is provisioned with an
+// instance type, in which case the infrastructure stage associates a public IP with it
+func (host *Host) hasPublicAddress() bool {
+	return strings.TrimSpace(host.PublicIp) != "" || host.InstanceType != ""
+}
@@ -0,0 +1,151 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// withRenderedConfigs returns the context of an instance whose kit holds the given rendered configs
+func withRenderedConfigs(t *testing.T, configs map[string]string) *ValidationContext {
+	t.Helper()
+	dir := t.TempDir()
+	cfgDir := filepath.Join(dir, BuildKitDir, BuildConfigDir)
+	if err := os.MkdirAll(cfgDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range configs {
+		if err := os.WriteFile(filepath.Join(cfgDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &ValidationContext{BuildDir: dir}
+}
+
+func TestModel_ValidateComponents_PortConflicts(t *testing.T) {
+	ctx := withRenderedConfigs(t, map[string]string{
+		"controller.yml":     "ctrl:\n  listener: tls:0.0.0.0:6262\nweb:\n  - bindPoints:\n      - interface: 0.0.0.0:1280\n        address: ctrl.example.com:1280\n",
+		"router-router.yml":  "ctrl:\n  endpoint: tls:ctrl.example.com:6262\nlisteners:\n  - binding: edge\n    address: tls:0.0.0.0:6262\n",
+		"router-router2.yml": "listeners:\n  - binding: edge\n    address: tls:0.0.0.0:6262\n",
+	})
+
+	m := &Model{
+		Id: "test",
+		Regions: Regions{
+			"region": {
+				Hosts: Hosts{
+					"host": {
+						InstanceType: "t3.micro",
+						Components: Components{
+							"ctrl":   {Type: &ZitiControllerType{}},
+							"router": {Type: &ZitiRouterType{Mode: "edge"}},
+						},
+					},
+					"other": {
+						InstanceType: "t3.micro",
+						Components: Components{
+							"router2": {Type: &ZitiRouterType{Mode: "edge"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	m.Init()
+
+	ctrl := m.Regions["region"].Hosts["host"].Components["ctrl"]
+	if ports := ctrl.Type.(ListeningComponent).GetListenPorts(ctx, ctrl); !reflect.DeepEqual(ports, []uint16{1280, 6262}) {
+		t.Errorf("expected the controller's listener and web ports, got %v", ports)
+	}
+
+	issues := m.ValidateComponents(ctx)
+	if len(issues) != 1 {
+		t.Fatalf("expected a single port conflict, got %+v", issues)
+	}
+	issue := issues[0]
+	if issue.Severity != ValidationSeverityError || issue.Component.Host.Id != "host" ||
+		!strings.Contains(issue.Message, "port 6262") {
+		t.Errorf("unexpected issue %+v", issue)
+	}
+}
+
+func TestModel_ValidateComponents_UnrenderedConfigs(t *testing.T) {
+	ctx := withRenderedConfigs(t, nil)
+
+	m := &Model{
+		Id: "test",
+		Regions: Regions{
+			"region": {
+				Hosts: Hosts{
+					"host": {
+						InstanceType: "t3.micro",
+						Components: Components{
+							"ctrl":   {Type: &ZitiControllerType{}},
+							"router": {Type: &ZitiRouterType{Mode: "edge"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	m.Init()
+
+	// without rendered configs the ports aren't known, so nothing conflicts
+	if issues := m.ValidateComponents(ctx); len(issues) != 0 {
+		t.Fatalf("expected no issues, got %+v", issues)
+	}
+}
+
+func TestModel_ValidateComponents_ControllerConfigs(t *testing.T) {
+	ctx := withRenderedConfigs(t, map[string]string{
+		"controller.yml":       "ctrl:\n  listener: tls:0.0.0.0:6262\n",
+		"controller-ctrl2.yml": "ctrl:\n  listener: tls:0.0.0.0:6363\n",
+	})
+
+	m := &Model{
+		Id: "test",
+		Regions: Regions{
+			"region": {
+				Hosts: Hosts{
+					"host": {
+						InstanceType: "t3.micro",
+						Components: Components{
+							"ctrl1": {Type: &ZitiControllerType{}},
+							"ctrl2": {Type: &ZitiControllerType{}},
+						},
+					},
+				},
+			},
+		},
+	}
+	m.Init()
+
+	// each controller reads its own config if it has one, the shared controller.yml otherwise
+	ctrl2 := m.Regions["region"].Hosts["host"].Components["ctrl2"]
+	if ports := ctrl2.Type.(ListeningComponent).GetListenPorts(ctx, ctrl2); !reflect.DeepEqual(ports, []uint16{6363}) {
+		t.Errorf("expected ctrl2's own listener port, got %v", ports)
+	}
+	if issues := m.ValidateComponents(ctx); len(issues) != 0 {
+		t.Fatalf("expected no issues, got %+v", issues)
+	}
+}
+
+func TestNewValidationContext(t *testing.T) {
+	previous := instanceConfig
+	t.Cleanup(func() { instanceConfig = previous })
+
+	instanceConfig = &InstanceConfig{Id: "test", Model: "bound", WorkingDirectory: t.TempDir()}
+	if ctx := NewValidationContext(&Model{Id: "bound"}); ctx.BuildDir != instanceConfig.WorkingDirectory {
+		t.Errorf("expected the bound instance's build dir, got '%s'", ctx.BuildDir)
+	}
+	if ctx := NewValidationContext(&Model{Id: "other"}); ctx.BuildDir != "" {
+		t.Errorf("expected no build dir for another model, got '%s'", ctx.BuildDir)
+	}
+
+	instanceConfig = nil
+	if ctx := NewValidationContext(&Model{Id: "bound"}); ctx.BuildDir != "" {
+		t.Errorf("expected no build dir without a bound instance, got '%s'", ctx.BuildDir)
+	}
+}
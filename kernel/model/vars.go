@@ -0,0 +1,256 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/openziti/foundation/v2/stringz"
+)
+
+// RedactedValue replaces the values of secret variables wherever they are shown.
+const RedactedValue = "**secret**"
+
+// VariableLookup is a single consultation of a resolver while resolving a variable, as reported
+// to VarConfig.ResolverLogger.
+type VariableLookup struct {
+	Resolver string
+	Entity   Entity
+	// Name is the name given to the resolver, including any scope prefix
+	Name   string
+	Value  interface{}
+	Found  bool
+	Detail string
+}
+
+// IsAggregate returns true for lookups reported by resolvers which only delegate to others.
+func (l *VariableLookup) IsAggregate() bool {
+	return l.Resolver == "chained" || l.Resolver == "scoped"
+}
+
+// VariableTrace records how a variable was resolved for an entity. Secret values are redacted.
+type VariableTrace struct {
+	Entity  Entity
+	Name    string
+	Value   interface{}
+	Found   bool
+	Secret  bool
+	Lookups []VariableLookup
+}
+
+// Answer returns the lookup which provided the value, or nil if none did.
+func (t *VariableTrace) Answer() *VariableLookup {
+	for i := range t.Lookups {
+		if lookup := &t.Lookups[i]; lookup.Found && !lookup.IsAggregate() {
+			return lookup
+		}
+	}
+	return nil
+}
+
+// TraceVariable resolves the variable as GetVariable does, recording each resolver consulted in
+// the order they completed. It replaces the model's ResolverLogger while it runs, so it must not
+// be used concurrently with other variable lookups.
+func (scope *Scope) TraceVariable(name string) *VariableTrace {
+	m := scope.entity.GetModel()
+	trace := &VariableTrace{
+		Entity: scope.entity,
+		Name:   name,
+		Secret: isSecretVariable(scope.entity, name),
+	}
+
+	logger := m.VarConfig.ResolverLogger
+	m.VarConfig.ResolverLogger = func(resolver string, entity Entity, name string, result interface{}, found bool, msgAndArgs ...interface{}) {
+		logger(resolver, entity, name, result, found, msgAndArgs...)
+		trace.Lookups = append(trace.Lookups, VariableLookup{
+			Resolver: resolver,
+			Entity:   entity,
+			Name:     name,
+			Value:    result,
+			Found:    found,
+			Detail:   formatResolverContext(msgAndArgs),
+		})
+	}
+	defer func() {
+		m.VarConfig.ResolverLogger = logger
+	}()
+
+	trace.Value, trace.Found = scope.GetVariable(name)
+	if answer := trace.Answer(); answer != nil && IsSecretResolver(answer.Resolver) {
+		trace.Secret = true
+	}
+	if trace.Secret {
+		if trace.Found {
+			trace.Value = RedactedValue
+		}
+		for i := range trace.Lookups {
+			if trace.Lookups[i].Found {
+				trace.Lookups[i].Value = RedactedValue
+			}
+		}
+	}
+	return trace
+}
+
+// VariableValue is a variable of the effective set of an entity.
+type VariableValue struct {
+	Name   string
+	Value  interface{}
+	Secret bool
+	Source *VariableLookup
+}
+
+// ListVariables returns the variables which resolve for the entity, sorted by name, with secret
+// values redacted. Candidates are taken from the defaults of the entity and its ancestors,
+// including those scoped to the entity, and from the label and global bindings. Variables only
+// set on the command line or in the environment are listed if they override one of these.
+func ListVariables(entity Entity) []VariableValue {
+	m := entity.GetModel()
+	entityPrefix := ""
+	if _, isModel := entity.(*Model); !isModel {
+		entityPrefix = strings.Join(GetScopedEntityPath(entity), ".") + "."
+	}
+
+	candidates := map[string]bool{}
+	for e := entity; e != nil; e = e.GetParentEntity() {
+		for _, name := range variableNames(e.GetScope().Defaults) {
+			if entityPrefix != "" && strings.HasPrefix(name, entityPrefix) {
+				candidates[strings.TrimPrefix(name, entityPrefix)] = true
+			} else if _, scopedToRegion := m.Regions[strings.SplitN(name, ".", 2)[0]]; !scopedToRegion {
+				candidates[name] = true
+			}
+		}
+	}
+	for _, resolver := range []*MapVariableResolver{m.VarConfig.LabelResolver, m.VarConfig.BindingResolver} {
+		if resolver != nil {
+			for _, name := range variableNames(resolver.variables) {
+				candidates[name] = true
+			}
+		}
+	}
+
+	var names []string
+	for name := range candidates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var result []VariableValue
+	for _, name := range names {
+		trace := entity.GetScope().TraceVariable(name)
+		if trace.Found {
+			result = append(result, VariableValue{
+				Name:   name,
+				Value:  trace.Value,
+				Secret: trace.Secret,
+				Source: trace.Answer(),
+			})
+		}
+	}
+	return result
+}
+
+// IsSecretName returns true if any part of the variable name is one of the SecretsKeys.
+func (self *VarConfig) IsSecretName(name string) bool {
+	for _, part := range self.VariableNameParser(name) {
+		if stringz.Contains(self.SecretsKeys, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSecretVariable returns true if the name is secret, or if the variable is held under a map
+// marked with __secret__ in the defaults of the entity or its ancestors, or in the label or
+// global bindings.
+func isSecretVariable(entity Entity, name string) bool {
+	config := &entity.GetModel().VarConfig
+	if config.IsSecretName(name) {
+		return true
+	}
+
+	paths := [][]string{
+		config.VariableNameParser(name),
+		config.VariableNameParser(config.VariableNamePrefixMapper(GetScopedEntityPath(entity), name)),
+	}
+	var maps []Variables
+	for e := entity; e != nil; e = e.GetParentEntity() {
+		maps = append(maps, e.GetScope().Defaults)
+	}
+	for _, resolver := range []*MapVariableResolver{config.LabelResolver, config.BindingResolver} {
+		if resolver != nil {
+			maps = append(maps, resolver.variables)
+		}
+	}
+
+	for _, vs := range maps {
+		for _, path := range paths {
+			if vs.isSecretPath(path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isSecretPath returns true if a map along the path is marked with __secret__.
+func (v Variables) isSecretPath(path []string) bool {
+	current := v
+	for _, key := range path {
+		if _, found := current["__secret__"]; found {
+			return true
+		}
+		next, ok := current[key].(Variables)
+		if !ok {
+			return false
+		}
+		current = next
+	}
+	return false
+}
+
+// variableNames returns the dotted names of the values held in the variables.
+func variableNames(vs Variables) []string {
+	var result []string
+	for k, v := range vs {
+		if k == "__secret__" {
+			continue
+		}
+		if sub, ok := v.(Variables); ok {
+			for _, name := range variableNames(sub) {
+				result = append(result, k+"."+name)
+			}
+		} else {
+			result = append(result, k)
+		}
+	}
+	return result
+}
+
+// formatResolverContext formats the optional message and arguments passed to ResolverLogger.
+func formatResolverContext(msgAndArgs []interface{}) string {
+	if len(msgAndArgs) == 0 {
+		return ""
+	}
+	msg := fmt.Sprintf("%v", msgAndArgs[0])
+	if len(msgAndArgs) > 1 {
+		msg = fmt.Sprintf(msg, msgAndArgs[1:]...)
+	}
+	return msg
+}
@@ -0,0 +1,103 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScope_TraceVariable(t *testing.T) {
+	req := require.New(t)
+	m := newTestModel()
+	m.Defaults["region1"] = Variables{"host1": Variables{"test": Variables{"key2": "host.scoped"}}}
+	m.init()
+	host := m.Regions["region1"].Hosts["host1"]
+
+	trace := host.TraceVariable("test.key2")
+	req.True(trace.Found)
+	req.Equal("host.scoped", trace.Value)
+	req.False(trace.Secret)
+
+	answer := trace.Answer()
+	req.NotNil(answer)
+	req.Equal("hierarchical", answer.Resolver)
+	req.Equal(m, answer.Entity)
+	req.Equal("region1.host1.test.key2", answer.Name)
+
+	var resolvers []string
+	for _, lookup := range trace.Lookups[:3] {
+		resolvers = append(resolvers, lookup.Resolver)
+	}
+	req.Equal([]string{"cmd-line", "env", "map"}, resolvers)
+
+	trace = host.TraceVariable("test.missing")
+	req.False(trace.Found)
+	req.Nil(trace.Answer())
+	req.NotEmpty(trace.Lookups)
+}
+
+func TestScope_TraceVariableRedactsSecrets(t *testing.T) {
+	req := require.New(t)
+	m := newTestModel()
+	m.Defaults["aws"] = Variables{"__secret__": true, "token": "abc"}
+	m.Defaults["db"] = Variables{"password": "hunter2"}
+	m.init()
+
+	for _, name := range []string{"aws.token", "db.password"} {
+		trace := m.TraceVariable(name)
+		req.True(trace.Found, name)
+		req.True(trace.Secret, name)
+		req.Equal(RedactedValue, trace.Value)
+		for _, lookup := range trace.Lookups {
+			req.NotEqual("abc", lookup.Value)
+			req.NotEqual("hunter2", lookup.Value)
+		}
+	}
+}
+
+func TestListVariables(t *testing.T) {
+	req := require.New(t)
+	m := newTestModel()
+	m.Defaults["region1"] = Variables{"host1": Variables{"test": Variables{"key3": "host.scoped"}}}
+	m.Defaults["db"] = Variables{"password": "hunter2"}
+	m.init()
+	host := m.Regions["region1"].Hosts["host1"]
+
+	values := map[string]interface{}{}
+	for _, v := range ListVariables(host) {
+		values[v.Name] = v.Value
+		req.NotNil(v.Source, v.Name)
+	}
+	req.Equal(map[string]interface{}{
+		"db.password": RedactedValue,
+		"test.key":    RedactedValue, // key is one of the default SecretsKeys, as in dumps
+		"test.key2":   "region.bye",
+		"test.key3":   "host.scoped",
+	}, values)
+
+	// scoped variables of other entities aren't listed
+	values = map[string]interface{}{}
+	for _, v := range ListVariables(m) {
+		values[v.Name] = v.Value
+	}
+	req.Equal(map[string]interface{}{
+		"db.password": RedactedValue,
+		"test.key":    RedactedValue,
+	}, values)
+}
@@ -0,0 +1,114 @@
+/*
+	(c) Copyright NetFoundry Inc. Inc.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+	https://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// vaultHeader starts the first line of a vault file, identifying the format and its version.
+const vaultHeader = "$FABLAB_VAULT;1;scrypt-aes256gcm"
+
+const (
+	vaultSaltSize = 16
+	vaultKeySize  = 32
+	vaultScryptN  = 1 << 15
+	vaultScryptR  = 8
+	vaultScryptP  = 1
+)
+
+// EncryptVault encrypts the plaintext with a key derived from the password, returning the
+// contents of a vault file.
+func EncryptVault(plaintext []byte, password string) ([]byte, error) {
+	salt := make([]byte, vaultSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "unable to generate salt")
+	}
+	gcm, err := newVaultCipher(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "unable to generate nonce")
+	}
+
+	sealed := append(append(salt, nonce...), gcm.Seal(nil, nonce, plaintext, []byte(vaultHeader))...)
+	var result bytes.Buffer
+	result.WriteString(vaultHeader)
+	result.WriteByte('\n')
+	encoded := base64.StdEncoding.EncodeToString(sealed)
+	for len(encoded) > 76 {
+		result.WriteString(encoded[:76])
+		result.WriteByte('\n')
+		encoded = encoded[76:]
+	}
+	result.WriteString(encoded)
+	result.WriteByte('\n')
+	return result.Bytes(), nil
+}
+
+// DecryptVault returns the plaintext of a vault file, failing if the password is wrong or the
+// file has been tampered with.
+func DecryptVault(data []byte, password string) ([]byte, error) {
+	header, body, _ := strings.Cut(string(data), "\n")
+	if strings.TrimSpace(header) != vaultHeader {
+		return nil, errors.New("not a fablab vault, or an unsupported version")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid vault encoding")
+	}
+	if len(sealed) < vaultSaltSize {
+		return nil, errors.New("vault is truncated")
+	}
+	salt, sealed := sealed[:vaultSaltSize], sealed[vaultSaltSize:]
+
+	gcm, err := newVaultCipher(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("vault is truncated")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(vaultHeader))
+	if err != nil {
+		return nil, errors.New("unable to decrypt vault, wrong password or corrupt file")
+	}
+	return plaintext, nil
+}
+
+func newVaultCipher(password string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(password), salt, vaultScryptN, vaultScryptR, vaultScryptP, vaultKeySize)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to derive vault key")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
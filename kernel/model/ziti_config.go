@@ -0,0 +1,96 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// renderedConfigPath returns the path of a config file rendered into the build directory, by the
+// configuration stages into the kit or the instance's cfg directory, or "" if it hasn't been
+// rendered or there's no build directory
+func renderedConfigPath(buildDir string, name string) string {
+	if buildDir == "" {
+		return ""
+	}
+	for _, dir := range []string{filepath.Join(buildDir, BuildKitDir, BuildConfigDir), filepath.Join(buildDir, BuildConfigDir)} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// zitiConfigListenPorts returns the ports a rendered ziti controller or router config listens on,
+// taken from the listener, bind and interface addresses, and the addresses of router listeners
+// (which have a binding). Missing or unreadable configs listen on no known ports.
+func zitiConfigListenPorts(ctx *ValidationContext, name string) []uint16 {
+	if ctx == nil {
+		return nil
+	}
+	path := renderedConfigPath(ctx.BuildDir, name)
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var config interface{}
+	if err = yaml.Unmarshal(data, &config); err != nil {
+		return nil
+	}
+
+	ports := map[uint16]struct{}{}
+	collectZitiListenPorts(config, ports)
+
+	var result []uint16
+	for port := range ports {
+		result = append(result, port)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+func collectZitiListenPorts(node interface{}, ports map[uint16]struct{}) {
+	switch v := node.(type) {
+	case map[interface{}]interface{}:
+		_, isListener := v["binding"]
+		for key, value := range v {
+			switch key {
+			case "listener", "bind", "interface":
+				addZitiListenPort(value, ports)
+			case "address":
+				if isListener {
+					addZitiListenPort(value, ports)
+				}
+			default:
+				collectZitiListenPorts(value, ports)
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			collectZitiListenPorts(value, ports)
+		}
+	}
+}
+
+// addZitiListenPort adds the port of an address like tls:0.0.0.0:6262 or 0.0.0.0:1280
+func addZitiListenPort(value interface{}, ports map[uint16]struct{}) {
+	address, ok := value.(string)
+	if !ok {
+		return
+	}
+	idx := strings.LastIndex(address, ":")
+	if idx < 0 {
+		return
+	}
+	if port, err := strconv.ParseUint(address[idx+1:], 10, 16); err == nil && port != 0 {
+		ports[uint16(port)] = struct{}{}
+	}
+}
@@ -18,10 +18,38 @@ func (c *ZitiControllerType) GetVersion() string {
 	return c.Version
 }
 
+func (c *ZitiControllerType) SetVersion(version string) {
+	c.Version = version
+}
+
 func (c *ZitiControllerType) Dump() any {
 	return map[string]string{"version": c.Version}
 }
 
+// GetListenPorts returns the ports of the listeners in the controller's rendered config, if the
+// instance has been built
+func (c *ZitiControllerType) GetListenPorts(ctx *ValidationContext, comp *Component) []uint16 {
+	if ctx == nil {
+		return nil
+	}
+	return zitiConfigListenPorts(ctx, zitiControllerConfigName(ctx.BuildDir, comp))
+}
+
+// Validate checks that routers will be able to reach the controller
+func (c *ZitiControllerType) Validate(comp *Component) []ValidationIssue {
+	if !comp.Host.hasPublicAddress() {
+		return []ValidationIssue{
+			ValidationWarningf("", "controller host '%s' has no public IP, set an instanceType or publicIp so routers can reach it", comp.Host.Id),
+		}
+	}
+	return nil
+}
+
+// GetLogPaths returns the log file the process output is redirected to by Start
+func (c *ZitiControllerType) GetLogPaths(run Run, comp *Component) []string {
+	return []string{fmt.Sprintf("%s/logs/controller.log", run.GetWorkingDir())}
+}
+
 func (c *ZitiControllerType) IsRunning(run Run, comp *Component) (bool, error) {
 	host := comp.GetHost()
 	output, _ := host.ExecLogged("pgrep -f ziti-controller || true")
@@ -44,8 +72,8 @@ func (c *ZitiControllerType) Start(run Run, comp *Component) error {
 
 	// Start controller
 	startCmd := fmt.Sprintf(
-		"nohup %s/bin/ziti-controller run %s/cfg/controller.yml > %s/logs/controller.log 2>&1 &",
-		run.GetWorkingDir(), run.GetWorkingDir(), run.GetWorkingDir(),
+		"nohup %s/bin/ziti-controller run %s/cfg/%s > %s 2>&1 &",
+		run.GetWorkingDir(), run.GetWorkingDir(), zitiControllerConfigName(run.GetWorkingDir(), comp), c.GetLogPaths(run, comp)[0],
 	)
 	if _, err := host.ExecLogged(startCmd); err != nil {
 		return fmt.Errorf("failed to start controller: %w", err)
@@ -54,6 +82,17 @@ func (c *ZitiControllerType) Start(run Run, comp *Component) error {
 	return nil
 }
 
+// zitiControllerConfigName returns the config file of the controller: controller-<id>.yml if one
+// has been rendered into the build directory, so that each controller can have its own, and the
+// shared controller.yml otherwise
+func zitiControllerConfigName(buildDir string, comp *Component) string {
+	name := fmt.Sprintf("controller-%s.yml", comp.Id)
+	if renderedConfigPath(buildDir, name) != "" {
+		return name
+	}
+	return "controller.yml"
+}
+
 func init() {
 	RegisterComponentType("ziti-controller", func() ComponentType {
 		return &ZitiControllerType{}
@@ -19,10 +19,37 @@ func (r *ZitiRouterType) GetVersion() string {
 	return r.Version
 }
 
+func (r *ZitiRouterType) SetVersion(version string) {
+	r.Version = version
+}
+
 func (r *ZitiRouterType) Dump() any {
 	return map[string]string{"version": r.Version, "mode": r.Mode}
 }
 
+// GetListenPorts returns the ports of the listeners in the router's rendered config, if the
+// instance has been built
+func (r *ZitiRouterType) GetListenPorts(ctx *ValidationContext, comp *Component) []uint16 {
+	return zitiConfigListenPorts(ctx, fmt.Sprintf("router-%s.yml", comp.Id))
+}
+
+// Validate checks that the router has a controller to enroll with and a known mode
+func (r *ZitiRouterType) Validate(comp *Component) []ValidationIssue {
+	var issues []ValidationIssue
+	if !comp.GetModel().hasComponentOfType("ziti-controller") {
+		issues = append(issues, ValidationErrorf("", "ziti-router requires a ziti-controller in the model"))
+	}
+	if r.Mode != "edge" && r.Mode != "fabric" {
+		issues = append(issues, ValidationErrorf("mode", "invalid mode '%s', must be 'edge' or 'fabric'", r.Mode))
+	}
+	return issues
+}
+
+// GetLogPaths returns the log file the process output is redirected to by Start
+func (r *ZitiRouterType) GetLogPaths(run Run, comp *Component) []string {
+	return []string{fmt.Sprintf("%s/logs/router-%s.log", run.GetWorkingDir(), comp.Id)}
+}
+
 func (r *ZitiRouterType) IsRunning(run Run, comp *Component) (bool, error) {
 	host := comp.GetHost()
 	output, _ := host.ExecLogged("pgrep -f ziti-router || true")
@@ -45,7 +72,7 @@ func (r *ZitiRouterType) Start(run Run, comp *Component) error {
 
 	// Start router
 	configPath := fmt.Sprintf("%s/cfg/router-%s.yml", run.GetWorkingDir(), comp.Id)
-	logPath := fmt.Sprintf("%s/logs/router-%s.log", run.GetWorkingDir(), comp.Id)
+	logPath := r.GetLogPaths(run, comp)[0]
 
 	startCmd := fmt.Sprintf(
 		"nohup %s/bin/ziti-router run %s > %s 2>&1 &",
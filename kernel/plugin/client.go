@@ -0,0 +1,327 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openziti/fablab/kernel/model"
+	"github.com/sirupsen/logrus"
+)
+
+// maxMessageSize bounds the size of a single protocol message.
+const maxMessageSize = 16 * 1024 * 1024
+
+var (
+	// CallTimeout bounds how long a plugin may take to answer a call, not counting the time spent
+	// answering its requests, such as running commands on the host. A plugin which doesn't answer
+	// in time is killed.
+	CallTimeout = 5 * time.Minute
+
+	// StopTimeout is how long a plugin has to exit once its stdin is closed before it's killed.
+	StopTimeout = 10 * time.Second
+)
+
+// A Plugin is a component type implemented by an external executable. The executable is started
+// on first use and serves one call at a time.
+type Plugin struct {
+	// Name is the component type name, taken from the executable's file name
+	Name string
+	Path string
+
+	lock      sync.Mutex
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	stdout    *bufio.Scanner
+	nextId    int64
+	handshake *HandshakeResult
+}
+
+// Handshake starts the plugin if it isn't running, returning what it reported about itself.
+func (p *Plugin) Handshake() (*HandshakeResult, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if err := p.ensureStarted(); err != nil {
+		return nil, err
+	}
+	return p.handshake, nil
+}
+
+// Close stops the plugin, if it is running.
+func (p *Plugin) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.stop()
+}
+
+// call sends a request, answering requests from the plugin for the component until the response
+// arrives.
+func (p *Plugin) call(c *model.Component, method string, params, result interface{}) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if err := p.ensureStarted(); err != nil {
+		return err
+	}
+	if err := p.roundTrip(c, method, params, result); err != nil {
+		if _, ok := err.(*Error); !ok {
+			// the conversation is out of step, start over on the next call
+			_ = p.stop()
+		}
+		return err
+	}
+	return nil
+}
+
+func (p *Plugin) ensureStarted() error {
+	if p.cmd != nil {
+		return nil
+	}
+
+	cmd := exec.Command(p.Path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr := logrus.WithField("plugin", p.Name).WriterLevel(logrus.InfoLevel)
+	cmd.Stderr = stderr
+	// don't wait on children of a killed plugin still holding its stderr
+	cmd.WaitDelay = StopTimeout
+	if err = cmd.Start(); err != nil {
+		_ = stderr.Close()
+		return fmt.Errorf("unable to start plugin '%s' (%w)", p.Path, err)
+	}
+
+	p.cmd = cmd
+	p.stdin = stdin
+	p.stdout = bufio.NewScanner(stdout)
+	p.stdout.Buffer(make([]byte, 64*1024), maxMessageSize)
+
+	handshake := &HandshakeResult{}
+	err = p.roundTrip(nil, MethodHandshake, &HandshakeParams{ProtocolVersion: ProtocolVersion, Type: p.Name}, handshake)
+	if err == nil && handshake.ProtocolVersion != ProtocolVersion {
+		err = fmt.Errorf("plugin speaks protocol version %d, fablab speaks %d", handshake.ProtocolVersion, ProtocolVersion)
+	}
+	if err != nil {
+		_ = p.stop()
+		return fmt.Errorf("handshake with plugin '%s' failed (%w)", p.Name, err)
+	}
+	p.handshake = handshake
+	logrus.Debugf("started plugin '%s' [%s]", p.Name, p.Path)
+	return nil
+}
+
+func (p *Plugin) stop() error {
+	if p.cmd == nil {
+		return nil
+	}
+	cmd := p.cmd
+	p.cmd = nil
+	p.handshake = nil
+
+	// plugins exit when their stdin is closed
+	_ = p.stdin.Close()
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	var err error
+	select {
+	case err = <-exited:
+	case <-time.After(StopTimeout):
+		logrus.Warnf("plugin '%s' didn't exit within %v, killing it", p.Name, StopTimeout)
+		_ = cmd.Process.Kill()
+		err = <-exited
+	}
+	if closer, ok := cmd.Stderr.(io.Closer); ok {
+		_ = closer.Close()
+	}
+	return err
+}
+
+func (p *Plugin) roundTrip(c *model.Component, method string, params, result interface{}) error {
+	p.nextId++
+	id := p.nextId
+	if err := p.send(&message{Id: &id, Method: method}, params); err != nil {
+		return err
+	}
+
+	for {
+		msg, err := p.receive()
+		if err != nil {
+			return err
+		}
+		if msg.Method != "" {
+			if err = p.answer(c, msg); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Id == nil || *msg.Id != id {
+			return fmt.Errorf("plugin '%s' answered an unexpected request", p.Name)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil && len(msg.Result) > 0 {
+			if err = json.Unmarshal(msg.Result, result); err != nil {
+				return fmt.Errorf("invalid %s result from plugin '%s' (%w)", method, p.Name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// answer handles a request or notification sent by the plugin during a call.
+func (p *Plugin) answer(c *model.Component, msg *message) error {
+	result, callErr := p.handleCallback(c, msg)
+	if msg.Id == nil {
+		return nil
+	}
+	response := &message{Id: msg.Id, Error: callErr}
+	if callErr != nil {
+		return p.send(response, nil)
+	}
+	return p.send(response, result)
+}
+
+func (p *Plugin) handleCallback(c *model.Component, msg *message) (interface{}, *Error) {
+	switch msg.Method {
+	case MethodLog:
+		params := &LogParams{}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return nil, &Error{Code: ErrorCodeInvalidParams, Message: err.Error()}
+		}
+		log := logrus.WithField("plugin", p.Name)
+		if c != nil {
+			log = log.WithField("component", c.GetPathId())
+		}
+		level, err := logrus.ParseLevel(params.Level)
+		if err != nil {
+			level = logrus.InfoLevel
+		}
+		log.Log(level, params.Message)
+		return nil, nil
+
+	case MethodExec:
+		if c == nil {
+			return nil, &Error{Code: ErrorCodeInternal, Message: "no component to exec on"}
+		}
+		params := &ExecParams{}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return nil, &Error{Code: ErrorCodeInvalidParams, Message: err.Error()}
+		}
+		output, err := c.GetHost().ExecLogged(params.Command)
+		if err != nil {
+			return nil, &Error{Code: ErrorCodeInternal, Message: fmt.Sprintf("%v: %s", err, output)}
+		}
+		return &ExecResult{Output: output}, nil
+
+	case MethodGetVariable:
+		if c == nil {
+			return nil, &Error{Code: ErrorCodeInternal, Message: "no component to resolve variables for"}
+		}
+		params := &GetVariableParams{}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return nil, &Error{Code: ErrorCodeInvalidParams, Message: err.Error()}
+		}
+		value, found := c.GetVariable(params.Name)
+		return &GetVariableResult{Value: normalize(value), Found: found}, nil
+
+	default:
+		return nil, &Error{Code: ErrorCodeMethodNotFound, Message: fmt.Sprintf("unknown method '%s'", msg.Method)}
+	}
+}
+
+func (p *Plugin) send(msg *message, params interface{}) error {
+	msg.JsonRpc = "2.0"
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		if msg.Method != "" {
+			msg.Params = data
+		} else {
+			msg.Result = data
+		}
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err = p.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("unable to write to plugin '%s' (%w)", p.Name, err)
+	}
+	return nil
+}
+
+// receive reads the next message, killing the plugin if none arrives within CallTimeout.
+func (p *Plugin) receive() (*message, error) {
+	var timedOut atomic.Bool
+	process := p.cmd.Process
+	timer := time.AfterFunc(CallTimeout, func() {
+		timedOut.Store(true)
+		_ = process.Kill()
+	})
+	msg, err := p.read()
+	if !timer.Stop() && timedOut.Load() {
+		return nil, fmt.Errorf("plugin '%s' didn't answer within %v", p.Name, CallTimeout)
+	}
+	return msg, err
+}
+
+func (p *Plugin) read() (*message, error) {
+	for p.stdout.Scan() {
+		line := p.stdout.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		msg := &message{}
+		if err := json.Unmarshal(line, msg); err != nil {
+			return nil, fmt.Errorf("invalid message from plugin '%s' (%w)", p.Name, err)
+		}
+		return msg, nil
+	}
+	if err := p.stdout.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read from plugin '%s' (%w)", p.Name, err)
+	}
+	return nil, fmt.Errorf("plugin '%s' exited", p.Name)
+}
+
+// normalize converts the maps decoded from YAML, which may have non-string keys, into maps
+// which can be encoded as JSON.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := map[string]interface{}{}
+		for key, val := range v {
+			result[fmt.Sprint(key)] = normalize(val)
+		}
+		return result
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, val := range v {
+			result[key] = normalize(val)
+		}
+		return result
+	case model.Variables:
+		return normalize(map[string]interface{}(v))
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, val := range v {
+			result[i] = normalize(val)
+		}
+		return result
+	default:
+		return value
+	}
+}
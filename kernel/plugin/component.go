@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/invopop/jsonschema"
+	"github.com/openziti/fablab/kernel/model"
+)
+
+// Component is the component type of a plugin. Its configuration is whatever the model gives in
+// the component's config, which is passed to the plugin on every call.
+type Component struct {
+	Plugin *Plugin
+	Config map[string]interface{}
+}
+
+func (c *Component) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	config := map[string]interface{}{}
+	if err := unmarshal(&config); err != nil {
+		return err
+	}
+	c.Config = config
+	return nil
+}
+
+// JSONSchema describes the config of a plugin component type, which only the plugin knows the
+// structure of.
+func (Component) JSONSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type:        "object",
+		Description: "Configuration passed to the component type plugin",
+	}
+}
+
+func (c *Component) Label() string {
+	if handshake, err := c.Plugin.Handshake(); err == nil && handshake.Label != "" {
+		return handshake.Label
+	}
+	return c.Plugin.Name
+}
+
+func (c *Component) GetVersion() string {
+	if version, ok := c.Config["version"]; ok {
+		return fmt.Sprint(version)
+	}
+	return ""
+}
+
+func (c *Component) SetVersion(version string) {
+	if c.Config == nil {
+		c.Config = map[string]interface{}{}
+	}
+	c.Config["version"] = version
+}
+
+// CloneType copies the config for a scaled clone, so that setting the version of one clone leaves
+// the others as they are.
+func (c *Component) CloneType() model.ComponentType {
+	config, _ := normalize(c.Config).(map[string]interface{})
+	return &Component{Plugin: c.Plugin, Config: config}
+}
+
+func (c *Component) Dump() any {
+	return map[string]interface{}{
+		"plugin": c.Plugin.Path,
+		"config": normalize(c.Config),
+	}
+}
+
+func (c *Component) IsRunning(run model.Run, component *model.Component) (bool, error) {
+	result := &IsRunningResult{}
+	if err := c.Plugin.call(component, MethodIsRunning, c.params(component), result); err != nil {
+		return false, err
+	}
+	return result.Running, nil
+}
+
+func (c *Component) Start(run model.Run, component *model.Component) error {
+	return c.Plugin.call(component, MethodStart, c.params(component), nil)
+}
+
+func (c *Component) Stop(run model.Run, component *model.Component) error {
+	return c.Plugin.call(component, MethodStop, c.params(component), nil)
+}
+
+func (c *Component) StageFiles(run model.Run, component *model.Component) error {
+	params := c.params(component)
+	params.ConfigDir = run.GetConfigDir()
+	params.BinDir = run.GetBinDir()
+	params.PkiDir = run.GetPkiDir()
+	return c.Plugin.call(component, MethodStageFiles, params, nil)
+}
+
+func (c *Component) InitializeHost(run model.Run, component *model.Component) error {
+	return c.Plugin.call(component, MethodInitializeHost, c.params(component), nil)
+}
+
+// GetActions returns the custom actions the plugin reported in its handshake.
+func (c *Component) GetActions() map[string]model.ComponentAction {
+	handshake, err := c.Plugin.Handshake()
+	if err != nil {
+		return nil
+	}
+	result := map[string]model.ComponentAction{}
+	for _, action := range handshake.Actions {
+		result[action] = model.ComponentActionF(func(run model.Run, component *model.Component) error {
+			params := c.params(component)
+			params.Action = action
+			return c.Plugin.call(component, MethodExecuteAction, params, nil)
+		})
+	}
+	return result
+}
+
+func (c *Component) params(component *model.Component) *ComponentParams {
+	config, _ := normalize(c.Config).(map[string]interface{})
+	info := ComponentInfo{
+		Id:         component.Id,
+		Path:       component.GetPath(),
+		Index:      component.Index,
+		ScaleIndex: component.ScaleIndex,
+		Tags:       component.Tags,
+		Version:    c.GetVersion(),
+		Config:     config,
+	}
+	if host := component.GetHost(); host != nil {
+		info.HostId = host.Id
+		info.PublicIp = host.PublicIp
+		info.PrivateIp = host.PrivateIp
+		if region := host.GetRegion(); region != nil {
+			info.RegionId = region.Id
+		}
+	}
+	return &ComponentParams{Component: info}
+}
@@ -0,0 +1,134 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/openziti/fablab/kernel/model"
+	"github.com/sirupsen/logrus"
+)
+
+// PluginsDirEnv overrides the directory plugins are discovered in.
+const PluginsDirEnv = "FABLAB_PLUGINS_DIR"
+
+var (
+	pluginsLock sync.Mutex
+	plugins     = map[string]*Plugin{}
+)
+
+func init() {
+	// models may be loaded before the command line is processed, as in `fablab.InitModel(
+	// loader.MustLoadModel("network.yaml"))`, so plugins are loaded when their type is looked up
+	model.RegisterComponentTypeLoader(loadDefaultPlugins)
+}
+
+func loadDefaultPlugins() {
+	if _, err := LoadDefault(); err != nil {
+		logrus.WithError(err).Warn("unable to load component type plugins")
+	}
+}
+
+// PluginsDir returns the directory plugins are discovered in, $FABLAB_PLUGINS_DIR if set,
+// otherwise the plugins directory of the fablab configuration directory.
+func PluginsDir() (string, error) {
+	if dir := os.Getenv(PluginsDirEnv); dir != "" {
+		return dir, nil
+	}
+	configDir, err := model.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "plugins"), nil
+}
+
+// Discover finds the plugins in a directory. Each executable file provides the component type
+// named after the file, without its extension. A missing directory has no plugins.
+func Discover(dir string) ([]*Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var result []*Plugin
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") || entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		result = append(result, &Plugin{Name: name, Path: path})
+	}
+	return result, nil
+}
+
+// Load discovers the plugins in a directory and registers their component types. Plugins whose
+// name is already a registered component type are skipped with a warning. Loading a directory
+// more than once registers only the plugins not seen before.
+func Load(dir string) ([]*Plugin, error) {
+	discovered, err := Discover(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pluginsLock.Lock()
+	defer pluginsLock.Unlock()
+
+	var loaded []*Plugin
+	for _, p := range discovered {
+		if existing, found := plugins[p.Name]; found && existing.Path == p.Path {
+			continue
+		}
+		if model.HasComponentType(p.Name) {
+			logrus.Warnf("plugin '%s' [%s] skipped, component type already registered", p.Name, p.Path)
+			continue
+		}
+		p := p
+		model.RegisterComponentType(p.Name, func() model.ComponentType {
+			return &Component{Plugin: p}
+		})
+		plugins[p.Name] = p
+		loaded = append(loaded, p)
+		logrus.Debugf("registered plugin component type '%s' [%s]", p.Name, p.Path)
+	}
+	return loaded, nil
+}
+
+// LoadDefault loads the plugins from PluginsDir.
+func LoadDefault() ([]*Plugin, error) {
+	dir, err := PluginsDir()
+	if err != nil {
+		return nil, err
+	}
+	return Load(dir)
+}
+
+// Loaded returns the loaded plugins, sorted by name.
+func Loaded() []*Plugin {
+	pluginsLock.Lock()
+	defer pluginsLock.Unlock()
+	var result []*Plugin
+	for _, p := range plugins {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Shutdown stops the processes of all loaded plugins.
+func Shutdown() {
+	for _, p := range Loaded() {
+		if err := p.Close(); err != nil {
+			logrus.WithError(err).Debugf("plugin '%s' exited", p.Name)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openziti/fablab/kernel/loader"
+	"github.com/openziti/fablab/kernel/model"
+)
+
+const testPluginEnv = "FABLAB_TEST_PLUGIN"
+
+// TestMain lets the test binary serve as the plugin under test, see writeTestPlugin.
+func TestMain(m *testing.M) {
+	if os.Getenv(testPluginEnv) == "1" {
+		if err := Serve(os.Stdin, os.Stdout, testHandler()); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func testHandler() *Handler {
+	running := map[string]bool{}
+	return &Handler{
+		Label: "Echo Server",
+		IsRunning: func(call *Call) (bool, error) {
+			return running[call.Component.Path], nil
+		},
+		Start: func(call *Call) error {
+			call.Logf("info", "starting %s", call.Component.Path)
+			running[call.Component.Path] = true
+			return nil
+		},
+		Stop: func(call *Call) error {
+			running[call.Component.Path] = false
+			return nil
+		},
+		StageFiles: func(call *Call) error {
+			greeting, found, err := call.GetVariable("greeting")
+			if err != nil {
+				return err
+			}
+			if !found {
+				return fmt.Errorf("greeting not set")
+			}
+			content := fmt.Sprintf("%v %v", greeting, call.Component.Config["port"])
+			return os.WriteFile(filepath.Join(call.ConfigDir, call.Component.Id+".cfg"), []byte(content), 0600)
+		},
+		Actions: map[string]HandlerF{
+			"fail": func(call *Call) error {
+				return fmt.Errorf("failed on purpose")
+			},
+		},
+	}
+}
+
+func writeTestPlugin(t *testing.T, dir, name string) {
+	t.Helper()
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := fmt.Sprintf("#!/bin/sh\n%s=1 exec '%s'\n", testPluginEnv, executable)
+	if err = os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPlugin(t, dir, "echo-server.sh")
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("not a plugin"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeTestPlugin(t, dir, ".hidden")
+
+	plugins, err := Discover(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plugins) != 1 || plugins[0].Name != "echo-server" {
+		t.Fatalf("expected only the echo-server plugin, got %v", plugins)
+	}
+
+	if plugins, err = Discover(filepath.Join(dir, "missing")); err != nil || len(plugins) != 0 {
+		t.Fatalf("expected no plugins from a missing directory, got %v, %v", plugins, err)
+	}
+}
+
+func TestLoadSkipsRegisteredTypes(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPlugin(t, dir, "generic")
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 0 {
+		t.Fatalf("expected the generic plugin to be skipped, got %v", loaded)
+	}
+}
+
+func TestPluginLoadedOnLookup(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPlugin(t, dir, "test-lazy")
+	t.Setenv(PluginsDirEnv, dir)
+	// the loader registered by init may have run already
+	model.RegisterComponentTypeLoader(loadDefaultPlugins)
+	defer Shutdown()
+
+	m, err := loader.LoadModelFromBytes([]byte(`
+model:
+  id: test-model
+regions:
+  us-east-1:
+    hosts:
+      host1:
+        components:
+          - type: test-lazy
+            id: echo
+`))
+	if err != nil {
+		t.Fatalf("expected the plugin to be loaded with the model, got %v", err)
+	}
+	if label := m.Regions["us-east-1"].Hosts["host1"].Components["echo"].Type.Label(); label != "Echo Server" {
+		t.Errorf("expected label from the handshake, got '%s'", label)
+	}
+}
+
+func TestPluginComponent(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPlugin(t, dir, "test-echo")
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 1 || !model.HasComponentType("test-echo") {
+		t.Fatalf("expected the test-echo component type to be registered")
+	}
+	defer Shutdown()
+
+	m, err := loader.LoadModelFromBytes([]byte(`
+model:
+  id: test-model
+  variables:
+    greeting: hello
+regions:
+  us-east-1:
+    hosts:
+      host1:
+        components:
+          - type: test-echo
+            id: echo
+            config:
+              version: v1.0.0
+              port: 8080
+`))
+	if err != nil {
+		t.Fatalf("unable to load model: %v", err)
+	}
+
+	c := m.Regions["us-east-1"].Hosts["host1"].Components["echo"]
+	if c.Type.Label() != "Echo Server" {
+		t.Errorf("expected label from the handshake, got '%s'", c.Type.Label())
+	}
+	if c.Type.GetVersion() != "v1.0.0" {
+		t.Errorf("expected version v1.0.0, got '%s'", c.Type.GetVersion())
+	}
+
+	run, err := model.NewRun(m, nil, &model.InstanceConfig{Id: "test", WorkingDirectory: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if running, err := c.IsRunning(run); err != nil || running {
+		t.Fatalf("expected not running, got %v, %v", running, err)
+	}
+	if err = c.Type.(model.ServerComponent).Start(run, c); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	if running, err := c.IsRunning(run); err != nil || !running {
+		t.Fatalf("expected running, got %v, %v", running, err)
+	}
+
+	if err = c.Type.(model.FileStagingComponent).StageFiles(run, c); err != nil {
+		t.Fatalf("stage files failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(run.GetConfigDir(), "echo.cfg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello 8080" {
+		t.Errorf("expected staged file to hold 'hello 8080', got '%s'", data)
+	}
+
+	actions := c.GetActions()
+	if _, found := actions["fail"]; !found {
+		t.Fatalf("expected the plugin's fail action")
+	}
+	err = actions["fail"].Execute(run, c)
+	if err == nil || !strings.Contains(err.Error(), "failed on purpose") {
+		t.Fatalf("expected the action's error, got %v", err)
+	}
+
+	// errors returned by the plugin leave it running
+	if running, err := c.IsRunning(run); err != nil || !running {
+		t.Fatalf("expected still running, got %v, %v", running, err)
+	}
+
+	if err = c.Type.Stop(run, c); err != nil {
+		t.Fatalf("stop failed: %v", err)
+	}
+	if running, err := c.IsRunning(run); err != nil || running {
+		t.Fatalf("expected stopped, got %v, %v", running, err)
+	}
+}
+
+func TestProtocolVersionMismatch(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\nread line\necho '{\"jsonrpc\":\"2.0\",\"id\":1,\"result\":{\"protocolVersion\":99}}'\nread line\n"
+	path := filepath.Join(dir, "future")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Plugin{Name: "future", Path: path}
+	defer func() { _ = p.Close() }()
+	_, err := p.Handshake()
+	if err == nil || !strings.Contains(err.Error(), "protocol version 99") {
+		t.Fatalf("expected a protocol version error, got %v", err)
+	}
+}
+
+func TestUnresponsivePlugin(t *testing.T) {
+	defer func(callTimeout, stopTimeout time.Duration) {
+		CallTimeout, StopTimeout = callTimeout, stopTimeout
+	}(CallTimeout, StopTimeout)
+	CallTimeout, StopTimeout = 200*time.Millisecond, 200*time.Millisecond
+
+	dir := t.TempDir()
+	// answers the handshake, then neither answers calls nor exits when its stdin is closed
+	script := "#!/bin/sh\nread line\necho '{\"jsonrpc\":\"2.0\",\"id\":1,\"result\":{\"protocolVersion\":1}}'\nexec sleep 60\n"
+	path := filepath.Join(dir, "stuck")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Plugin{Name: "stuck", Path: path}
+	if _, err := p.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	err := p.call(nil, "test", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "didn't answer within") {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+
+	p = &Plugin{Name: "stuck", Path: path}
+	if _, err = p.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	_ = p.Close()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the plugin to be killed, took %v", elapsed)
+	}
+}
+
+func TestPluginComponentSchema(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPlugin(t, dir, "test-schema")
+	if _, err := Load(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := loader.ComponentTypeSchema("test-schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "additionalProperties") || strings.Contains(string(data), "Plugin") {
+		t.Fatalf("expected an open schema for the plugin's config, got %s", data)
+	}
+}
+
+func TestPluginComponentClone(t *testing.T) {
+	original := &Component{Config: map[string]interface{}{
+		"version": "1.0",
+		"listen":  map[interface{}]interface{}{"port": 8080},
+	}}
+	clone := original.CloneType().(*Component)
+	clone.SetVersion("2.0")
+	clone.Config["listen"].(map[string]interface{})["port"] = 9090
+
+	if version := original.GetVersion(); version != "1.0" {
+		t.Errorf("expected original to stay at 1.0, got %s", version)
+	}
+	if port := original.Config["listen"].(map[interface{}]interface{})["port"]; port != 8080 {
+		t.Errorf("expected original config to be unchanged, got port %v", port)
+	}
+}
@@ -0,0 +1,129 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ProtocolVersion is the version of the plugin protocol spoken by this build of fablab. Plugins
+// report the version they speak in the handshake, and are refused if it differs.
+//
+// The protocol is JSON-RPC 2.0 over the plugin's stdin and stdout, one message per line. fablab
+// sends one request at a time. While it waits for the response the plugin may send its own
+// requests, exec and getVariable, which fablab answers, and log notifications. Plugins must exit
+// when their stdin is closed. Anything written to stderr is logged.
+const ProtocolVersion = 1
+
+// Methods called by fablab on the plugin.
+const (
+	MethodHandshake      = "handshake"
+	MethodIsRunning      = "isRunning"
+	MethodStart          = "start"
+	MethodStop           = "stop"
+	MethodStageFiles     = "stageFiles"
+	MethodInitializeHost = "initializeHost"
+	MethodExecuteAction  = "executeAction"
+)
+
+// Methods called by the plugin on fablab, while a call is in progress.
+const (
+	// MethodExec runs a shell command on the host of the component, returning its output
+	MethodExec = "exec"
+	// MethodGetVariable resolves a model variable for the component
+	MethodGetVariable = "getVariable"
+	// MethodLog is a notification logging a message through fablab
+	MethodLog = "log"
+)
+
+// JSON-RPC error codes.
+const (
+	ErrorCodeMethodNotFound = -32601
+	ErrorCodeInvalidParams  = -32602
+	ErrorCodeInternal       = -32603
+)
+
+// message is a JSON-RPC request, notification or response.
+type message struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Id      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error, returned by the plugin when a call fails.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+type HandshakeParams struct {
+	ProtocolVersion int `json:"protocolVersion"`
+	// Type is the component type name the plugin was discovered as
+	Type string `json:"type"`
+}
+
+type HandshakeResult struct {
+	ProtocolVersion int `json:"protocolVersion"`
+	// Label, if set, is returned by the Label of the component type, otherwise the type name is
+	Label string `json:"label,omitempty"`
+	// Actions are the names of the custom actions of the component type
+	Actions []string `json:"actions,omitempty"`
+}
+
+// ComponentInfo describes the component a call is made for.
+type ComponentInfo struct {
+	Id         string                 `json:"id"`
+	Path       string                 `json:"path"`
+	RegionId   string                 `json:"regionId"`
+	HostId     string                 `json:"hostId"`
+	PublicIp   string                 `json:"publicIp,omitempty"`
+	PrivateIp  string                 `json:"privateIp,omitempty"`
+	Index      uint32                 `json:"index"`
+	ScaleIndex uint32                 `json:"scaleIndex"`
+	Tags       []string               `json:"tags,omitempty"`
+	Version    string                 `json:"version,omitempty"`
+	Config     map[string]interface{} `json:"config,omitempty"`
+}
+
+type ComponentParams struct {
+	Component ComponentInfo `json:"component"`
+	// Action is the custom action to execute, for executeAction
+	Action string `json:"action,omitempty"`
+	// ConfigDir, BinDir and PkiDir are the local kit directories synced to the hosts, for stageFiles
+	ConfigDir string `json:"configDir,omitempty"`
+	BinDir    string `json:"binDir,omitempty"`
+	PkiDir    string `json:"pkiDir,omitempty"`
+}
+
+type IsRunningResult struct {
+	Running bool `json:"running"`
+}
+
+type ExecParams struct {
+	Command string `json:"command"`
+}
+
+type ExecResult struct {
+	Output string `json:"output"`
+}
+
+type GetVariableParams struct {
+	Name string `json:"name"`
+}
+
+type GetVariableResult struct {
+	Value interface{} `json:"value,omitempty"`
+	Found bool        `json:"found"`
+}
+
+type LogParams struct {
+	// Level is one of debug, info, warn or error
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,206 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// HandlerF handles a call from fablab for a component.
+type HandlerF func(call *Call) error
+
+// Handler implements a component type in a plugin, see Serve. Unset handlers are reported to
+// fablab as unknown methods, except IsRunning, which defaults to not running.
+type Handler struct {
+	Label          string
+	IsRunning      func(call *Call) (bool, error)
+	Start          HandlerF
+	Stop           HandlerF
+	StageFiles     HandlerF
+	InitializeHost HandlerF
+	Actions        map[string]HandlerF
+}
+
+// Call is a call from fablab, giving access to the component and to fablab's callbacks.
+type Call struct {
+	ComponentParams
+	server *server
+}
+
+// Exec runs a shell command on the host of the component, returning its output.
+func (c *Call) Exec(command string) (string, error) {
+	result := &ExecResult{}
+	if err := c.server.call(MethodExec, &ExecParams{Command: command}, result); err != nil {
+		return result.Output, err
+	}
+	return result.Output, nil
+}
+
+// GetVariable resolves a model variable for the component.
+func (c *Call) GetVariable(name string) (interface{}, bool, error) {
+	result := &GetVariableResult{}
+	if err := c.server.call(MethodGetVariable, &GetVariableParams{Name: name}, result); err != nil {
+		return nil, false, err
+	}
+	return result.Value, result.Found, nil
+}
+
+// Logf logs a message through fablab.
+func (c *Call) Logf(level string, format string, args ...interface{}) {
+	_ = c.server.send(&message{Method: MethodLog}, &LogParams{Level: level, Message: fmt.Sprintf(format, args...)})
+}
+
+// Serve implements the plugin side of the protocol, handling calls from fablab read from in,
+// usually os.Stdin, and writing to out, usually os.Stdout, until in is closed.
+func Serve(in io.Reader, out io.Writer, handler *Handler) error {
+	s := &server{
+		handler: handler,
+		in:      bufio.NewScanner(in),
+		out:     out,
+	}
+	s.in.Buffer(make([]byte, 64*1024), maxMessageSize)
+
+	for {
+		msg, err := s.receive()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "" || msg.Id == nil {
+			continue
+		}
+		result, callErr := s.handle(msg)
+		response := &message{Id: msg.Id}
+		if callErr != nil {
+			if rpcErr, ok := callErr.(*Error); ok {
+				response.Error = rpcErr
+			} else {
+				response.Error = &Error{Code: ErrorCodeInternal, Message: callErr.Error()}
+			}
+			result = nil
+		}
+		if err = s.send(response, result); err != nil {
+			return err
+		}
+	}
+}
+
+type server struct {
+	handler *Handler
+	in      *bufio.Scanner
+	out     io.Writer
+	nextId  int64
+}
+
+func (s *server) handle(msg *message) (interface{}, error) {
+	if msg.Method == MethodHandshake {
+		params := &HandshakeParams{}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return nil, &Error{Code: ErrorCodeInvalidParams, Message: err.Error()}
+		}
+		result := &HandshakeResult{ProtocolVersion: ProtocolVersion, Label: s.handler.Label}
+		for action := range s.handler.Actions {
+			result.Actions = append(result.Actions, action)
+		}
+		sort.Strings(result.Actions)
+		return result, nil
+	}
+
+	call := &Call{server: s}
+	if err := json.Unmarshal(msg.Params, &call.ComponentParams); err != nil {
+		return nil, &Error{Code: ErrorCodeInvalidParams, Message: err.Error()}
+	}
+
+	var f HandlerF
+	switch msg.Method {
+	case MethodIsRunning:
+		if s.handler.IsRunning == nil {
+			return &IsRunningResult{}, nil
+		}
+		running, err := s.handler.IsRunning(call)
+		if err != nil {
+			return nil, err
+		}
+		return &IsRunningResult{Running: running}, nil
+	case MethodStart:
+		f = s.handler.Start
+	case MethodStop:
+		f = s.handler.Stop
+	case MethodStageFiles:
+		f = s.handler.StageFiles
+	case MethodInitializeHost:
+		f = s.handler.InitializeHost
+	case MethodExecuteAction:
+		f = s.handler.Actions[call.Action]
+	}
+	if f == nil {
+		return nil, &Error{Code: ErrorCodeMethodNotFound, Message: fmt.Sprintf("unknown method '%s'", msg.Method)}
+	}
+	return struct{}{}, f(call)
+}
+
+// call sends a request to fablab, waiting for its response.
+func (s *server) call(method string, params, result interface{}) error {
+	s.nextId++
+	id := s.nextId
+	if err := s.send(&message{Id: &id, Method: method}, params); err != nil {
+		return err
+	}
+	msg, err := s.receive()
+	if err != nil {
+		return err
+	}
+	if msg.Id == nil || *msg.Id != id {
+		return fmt.Errorf("unexpected message from fablab")
+	}
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if result != nil && len(msg.Result) > 0 {
+		return json.Unmarshal(msg.Result, result)
+	}
+	return nil
+}
+
+func (s *server) send(msg *message, params interface{}) error {
+	msg.JsonRpc = "2.0"
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		if msg.Method != "" {
+			msg.Params = data
+		} else {
+			msg.Result = data
+		}
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = s.out.Write(append(data, '\n'))
+	return err
+}
+
+func (s *server) receive() (*message, error) {
+	for s.in.Scan() {
+		line := s.in.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		msg := &message{}
+		if err := json.Unmarshal(line, msg); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+	if err := s.in.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
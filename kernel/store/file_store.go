@@ -1,26 +1,32 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/openziti/fablab/kernel/model"
+	"github.com/sirupsen/logrus"
 )
 
 type FileStore struct {
-	Config *model.FablabConfig
-	mu     sync.RWMutex
+	Config        *model.FablabConfig
+	WatchInterval time.Duration
+	mu            sync.RWMutex
 }
 
 func NewFileStore(cfg *model.FablabConfig) *FileStore {
-	return &FileStore{Config: cfg}
+	return &FileStore{Config: cfg, WatchInterval: DefaultWatchInterval}
 }
 
 func (s *FileStore) GetStatus(instanceId string) (*model.Label, error) {
-	instanceCfg, ok := s.Config.Instances[instanceId]
+	instanceCfg, ok := s.instanceConfig(instanceId)
 	if !ok {
 		return nil, fmt.Errorf("instance [%s] not found in config", instanceId)
 	}
@@ -28,7 +34,7 @@ func (s *FileStore) GetStatus(instanceId string) (*model.Label, error) {
 }
 
 func (s *FileStore) SaveStatus(instanceId string, label *model.Label) error {
-	instanceCfg, ok := s.Config.Instances[instanceId]
+	instanceCfg, ok := s.instanceConfig(instanceId)
 	if !ok {
 		return fmt.Errorf("instance [%s] not found in config", instanceId)
 	}
@@ -39,6 +45,9 @@ func (s *FileStore) SaveStatus(instanceId string, label *model.Label) error {
 }
 
 func (s *FileStore) ListInstances() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	keys := make([]string, 0, len(s.Config.Instances))
 	for k := range s.Config.Instances {
 		keys = append(keys, k)
@@ -46,6 +55,13 @@ func (s *FileStore) ListInstances() ([]string, error) {
 	return keys, nil
 }
 
+func (s *FileStore) instanceConfig(instanceId string) (*model.InstanceConfig, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	instanceCfg, ok := s.Config.Instances[instanceId]
+	return instanceCfg, ok
+}
+
 // GetResources returns all resources for an instance from file.
 func (s *FileStore) GetResources(instanceId string) (map[string]ResourceState, error) {
 	s.mu.RLock()
@@ -96,6 +112,178 @@ func (s *FileStore) DeleteResource(instanceId, resourceId string) error {
 	return s.saveResourcesUnsafe(instanceId, resources)
 }
 
+// Watch watches the resources file of the instance (or of every configured instance when
+// instanceId is empty) and streams the differences it observes until ctx is done. Changes made by
+// other processes sharing the working directory are reported as well. The instance directories
+// are watched with fsnotify; instances whose directory can't be watched are polled every
+// WatchInterval instead. When watching every instance, the config is also checked for new
+// instances every WatchInterval.
+func (s *FileStore) Watch(ctx context.Context, instanceId string) (<-chan ResourceEvent, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logrus.WithError(err).Warn("unable to watch resource files, polling for changes")
+		fsWatcher = nil
+	}
+
+	// instances are watched through their directory, as the file is replaced when written and
+	// may not exist yet
+	byPath := make(map[string]string)
+	var polled []string
+	watch := func(id string) {
+		if fsWatcher != nil {
+			s.mu.RLock()
+			path := s.resourcesPath(id)
+			s.mu.RUnlock()
+			err := fsWatcher.Add(filepath.Dir(path))
+			if err == nil {
+				byPath[filepath.Clean(path)] = id
+				return
+			}
+			logrus.WithError(err).Warnf("unable to watch resources of instance [%s], polling for changes", id)
+		}
+		polled = append(polled, id)
+	}
+
+	snapshots := make(map[string]map[string]ResourceState)
+	for _, id := range s.watchedInstances(instanceId) {
+		resources, err := s.GetResources(id)
+		if err != nil {
+			if fsWatcher != nil {
+				_ = fsWatcher.Close()
+			}
+			return nil, err
+		}
+		snapshots[id] = resources
+		watch(id)
+	}
+
+	var fsEvents <-chan fsnotify.Event
+	var fsErrors <-chan error
+	if fsWatcher != nil {
+		fsEvents = fsWatcher.Events
+		fsErrors = fsWatcher.Errors
+	}
+
+	interval := s.WatchInterval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	events := make(chan ResourceEvent, watchBufferSize)
+	go func() {
+		defer close(events)
+		if fsWatcher != nil {
+			defer func() { _ = fsWatcher.Close() }()
+		}
+
+		var ticks <-chan time.Time
+		if len(polled) > 0 || instanceId == "" {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			ticks = ticker.C
+		}
+
+		refresh := func(id string) bool {
+			resources, err := s.GetResources(id)
+			if err != nil {
+				// the file may be mid-write; the write completing triggers another refresh
+				return true
+			}
+			for _, event := range diffResources(id, snapshots[id], resources) {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			snapshots[id] = resources
+			return true
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-fsEvents:
+				if !ok {
+					fsEvents = nil
+					continue
+				}
+				if id, found := byPath[filepath.Clean(event.Name)]; found && !refresh(id) {
+					return
+				}
+			case err, ok := <-fsErrors:
+				if !ok {
+					fsErrors = nil
+					continue
+				}
+				logrus.WithError(err).Warn("error watching resource files")
+			case <-ticks:
+				if instanceId == "" {
+					// the resources of new instances are reported as they are found
+					for _, id := range s.newInstances(snapshots) {
+						snapshots[id] = nil
+						watch(id)
+						if !refresh(id) {
+							return
+						}
+					}
+				}
+				for _, id := range polled {
+					if !refresh(id) {
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// newInstances returns the configured instances which aren't in watched, reloading the config
+// first so that instances created by other processes are found as well
+func (s *FileStore) newInstances(watched map[string]map[string]ResourceState) []string {
+	s.reloadInstances()
+	ids, _ := s.ListInstances()
+	var result []string
+	for _, id := range ids {
+		if _, found := watched[id]; !found {
+			result = append(result, id)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// reloadInstances adds the instances added to the config file since it was loaded
+func (s *FileStore) reloadInstances() {
+	if s.Config.ConfigPath == "" {
+		return
+	}
+	cfg, err := model.LoadConfig(s.Config.ConfigPath)
+	if err != nil {
+		logrus.WithError(err).Debug("unable to reload config, looking for new instances")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, instanceCfg := range cfg.Instances {
+		if _, found := s.Config.Instances[id]; !found {
+			s.Config.Instances[id] = instanceCfg
+		}
+	}
+}
+
+func (s *FileStore) watchedInstances(instanceId string) []string {
+	if instanceId != "" {
+		return []string{instanceId}
+	}
+	ids, _ := s.ListInstances()
+	return ids
+}
+
 func (s *FileStore) resourcesPath(instanceId string) string {
 	instanceCfg, ok := s.Config.Instances[instanceId]
 	if !ok {
@@ -1,6 +1,10 @@
 package store
 
-import "github.com/openziti/fablab/kernel/model"
+import (
+	"context"
+
+	"github.com/openziti/fablab/kernel/model"
+)
 
 // StateStore manages the persistent state of fablab instances.
 type StateStore interface {
@@ -15,6 +19,9 @@ type ResourceStore interface {
 	GetResources(instanceId string) (map[string]ResourceState, error)
 	SaveResource(instanceId string, resource ResourceState) error
 	DeleteResource(instanceId, resourceId string) error
+	// Watch streams resource changes for instanceId until ctx is done. An empty
+	// instanceId watches every instance known to the store.
+	Watch(ctx context.Context, instanceId string) (<-chan ResourceEvent, error)
 }
 
 // ResourceStatus represents the lifecycle status of a resource.
@@ -39,3 +46,22 @@ type ResourceState struct {
 	CreatedAt int64 // Unix timestamp
 	UpdatedAt int64 // Unix timestamp
 }
+
+// ResourceEventType identifies the kind of change made to a resource.
+type ResourceEventType string
+
+const (
+	EventPut    ResourceEventType = "put"
+	EventDelete ResourceEventType = "delete"
+)
+
+// ResourceEvent describes a single change to a resource. Old is nil when the
+// resource was created and New is nil when it was deleted.
+type ResourceEvent struct {
+	InstanceId string
+	ResourceId string
+	Type       ResourceEventType
+	Old        *ResourceState
+	New        *ResourceState
+	Timestamp  int64 // Unix timestamp
+}
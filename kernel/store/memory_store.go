@@ -1,8 +1,10 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/openziti/fablab/kernel/model"
 )
@@ -12,6 +14,7 @@ type MemoryStore struct {
 	mu        sync.RWMutex
 	instances map[string]*model.Label
 	resources map[string]map[string]ResourceState // instanceId -> resourceId -> state
+	watchers  watchers
 }
 
 func NewMemoryStore() *MemoryStore {
@@ -77,7 +80,20 @@ func (s *MemoryStore) SaveResource(instanceId string, resource ResourceState) er
 	if s.resources[instanceId] == nil {
 		s.resources[instanceId] = make(map[string]ResourceState)
 	}
+
+	event := ResourceEvent{
+		InstanceId: instanceId,
+		ResourceId: resource.Id,
+		Type:       EventPut,
+		New:        &resource,
+		Timestamp:  time.Now().Unix(),
+	}
+	if old, found := s.resources[instanceId][resource.Id]; found {
+		event.Old = &old
+	}
+
 	s.resources[instanceId][resource.Id] = resource
+	s.watchers.publish(event)
 	return nil
 }
 
@@ -86,8 +102,23 @@ func (s *MemoryStore) DeleteResource(instanceId, resourceId string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.resources[instanceId] != nil {
-		delete(s.resources[instanceId], resourceId)
+	old, found := s.resources[instanceId][resourceId]
+	if !found {
+		return nil
 	}
+
+	delete(s.resources[instanceId], resourceId)
+	s.watchers.publish(ResourceEvent{
+		InstanceId: instanceId,
+		ResourceId: resourceId,
+		Type:       EventDelete,
+		Old:        &old,
+		Timestamp:  time.Now().Unix(),
+	})
 	return nil
 }
+
+// Watch streams resource changes made through this store until ctx is done.
+func (s *MemoryStore) Watch(ctx context.Context, instanceId string) (<-chan ResourceEvent, error) {
+	return s.watchers.add(ctx, instanceId), nil
+}
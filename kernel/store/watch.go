@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// watchBufferSize is the number of events buffered per watcher before events are dropped.
+const watchBufferSize = 64
+
+// DefaultWatchInterval is how often FileStore polls resource files which it can't watch for changes.
+const DefaultWatchInterval = time.Second
+
+type watcher struct {
+	instanceId string
+	events     chan ResourceEvent
+}
+
+// watchers fans resource events out to subscribers registered through Watch.
+type watchers struct {
+	mu   sync.Mutex
+	subs map[*watcher]struct{}
+}
+
+func (w *watchers) add(ctx context.Context, instanceId string) <-chan ResourceEvent {
+	sub := &watcher{
+		instanceId: instanceId,
+		events:     make(chan ResourceEvent, watchBufferSize),
+	}
+
+	w.mu.Lock()
+	if w.subs == nil {
+		w.subs = make(map[*watcher]struct{})
+	}
+	w.subs[sub] = struct{}{}
+	w.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		w.mu.Lock()
+		delete(w.subs, sub)
+		close(sub.events)
+		w.mu.Unlock()
+	}()
+
+	return sub.events
+}
+
+func (w *watchers) publish(event ResourceEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for sub := range w.subs {
+		if sub.instanceId != "" && sub.instanceId != event.InstanceId {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			logrus.Warnf("dropping [%s] event for resource [%s/%s], watcher is not keeping up",
+				event.Type, event.InstanceId, event.ResourceId)
+		}
+	}
+}
+
+// diffResources returns the events needed to move from the old to the new set of resources,
+// ordered by resource id.
+func diffResources(instanceId string, old, new map[string]ResourceState) []ResourceEvent {
+	now := time.Now().Unix()
+
+	var ids []string
+	for id := range old {
+		ids = append(ids, id)
+	}
+	for id := range new {
+		if _, found := old[id]; !found {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var events []ResourceEvent
+	for _, id := range ids {
+		oldState, hadOld := old[id]
+		newState, hasNew := new[id]
+
+		switch {
+		case hadOld && !hasNew:
+			events = append(events, ResourceEvent{
+				InstanceId: instanceId,
+				ResourceId: id,
+				Type:       EventDelete,
+				Old:        &oldState,
+				Timestamp:  now,
+			})
+		case !hadOld && hasNew:
+			events = append(events, ResourceEvent{
+				InstanceId: instanceId,
+				ResourceId: id,
+				Type:       EventPut,
+				New:        &newState,
+				Timestamp:  now,
+			})
+		case !reflect.DeepEqual(oldState, newState):
+			events = append(events, ResourceEvent{
+				InstanceId: instanceId,
+				ResourceId: id,
+				Type:       EventPut,
+				Old:        &oldState,
+				New:        &newState,
+				Timestamp:  now,
+			})
+		}
+	}
+	return events
+}
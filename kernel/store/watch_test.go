@@ -0,0 +1,213 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openziti/fablab/kernel/model"
+)
+
+func nextEvent(t *testing.T, events <-chan ResourceEvent) ResourceEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for resource event")
+	}
+	return ResourceEvent{}
+}
+
+func TestMemoryStore_Watch(t *testing.T) {
+	store := NewMemoryStore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := store.Watch(ctx, "test-instance")
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	// changes to other instances are not delivered
+	_ = store.SaveResource("other-instance", ResourceState{Id: "host-0", Type: "host"})
+
+	_ = store.SaveResource("test-instance", ResourceState{Id: "host-1", Type: "host", Status: StatusCreating})
+	event := nextEvent(t, events)
+	if event.Type != EventPut || event.ResourceId != "host-1" || event.Old != nil || event.New == nil {
+		t.Fatalf("unexpected create event: %+v", event)
+	}
+
+	_ = store.SaveResource("test-instance", ResourceState{Id: "host-1", Type: "host", Status: StatusRunning})
+	event = nextEvent(t, events)
+	if event.Type != EventPut || event.Old.Status != StatusCreating || event.New.Status != StatusRunning {
+		t.Fatalf("unexpected update event: %+v", event)
+	}
+
+	_ = store.DeleteResource("test-instance", "host-1")
+	event = nextEvent(t, events)
+	if event.Type != EventDelete || event.Old == nil || event.New != nil {
+		t.Fatalf("unexpected delete event: %+v", event)
+	}
+
+	cancel()
+	for range events {
+		// drain until the store closes the channel
+	}
+}
+
+func TestFileStore_Watch(t *testing.T) {
+	tmpDir := t.TempDir()
+	instanceDir := filepath.Join(tmpDir, "test-instance")
+	if err := os.MkdirAll(instanceDir, 0755); err != nil {
+		t.Fatalf("failed to create instance dir: %v", err)
+	}
+
+	cfg := &model.FablabConfig{
+		Instances: map[string]*model.InstanceConfig{
+			"test-instance": {
+				WorkingDirectory: instanceDir,
+			},
+		},
+	}
+
+	store := NewFileStore(cfg)
+	store.WatchInterval = 10 * time.Millisecond
+
+	if err := store.SaveResource("test-instance", ResourceState{Id: "host-1", Type: "host"}); err != nil {
+		t.Fatalf("SaveResource failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := store.Watch(ctx, "")
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	// a second store simulates another process writing to the same working directory
+	writer := NewFileStore(cfg)
+	if err := writer.SaveResource("test-instance", ResourceState{Id: "host-2", Type: "host"}); err != nil {
+		t.Fatalf("SaveResource failed: %v", err)
+	}
+	event := nextEvent(t, events)
+	if event.Type != EventPut || event.InstanceId != "test-instance" || event.ResourceId != "host-2" {
+		t.Fatalf("unexpected put event: %+v", event)
+	}
+
+	if err := writer.DeleteResource("test-instance", "host-1"); err != nil {
+		t.Fatalf("DeleteResource failed: %v", err)
+	}
+	event = nextEvent(t, events)
+	if event.Type != EventDelete || event.ResourceId != "host-1" || event.Old == nil {
+		t.Fatalf("unexpected delete event: %+v", event)
+	}
+}
+
+func TestFileStore_WatchWithoutPolling(t *testing.T) {
+	instanceDir := t.TempDir()
+	cfg := &model.FablabConfig{
+		Instances: map[string]*model.InstanceConfig{
+			"test-instance": {WorkingDirectory: instanceDir},
+		},
+	}
+
+	// changes are noticed through the watched directory, long before the store would poll
+	store := NewFileStore(cfg)
+	store.WatchInterval = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := store.Watch(ctx, "test-instance")
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	writer := NewFileStore(cfg)
+	if err := writer.SaveResource("test-instance", ResourceState{Id: "host-1", Type: "host"}); err != nil {
+		t.Fatalf("SaveResource failed: %v", err)
+	}
+	event := nextEvent(t, events)
+	if event.Type != EventPut || event.ResourceId != "host-1" {
+		t.Fatalf("unexpected put event: %+v", event)
+	}
+}
+
+func TestFileStore_WatchFallsBackToPolling(t *testing.T) {
+	// a working directory which doesn't exist yet can't be watched
+	instanceDir := filepath.Join(t.TempDir(), "not-created")
+	cfg := &model.FablabConfig{
+		Instances: map[string]*model.InstanceConfig{
+			"test-instance": {WorkingDirectory: instanceDir},
+		},
+	}
+
+	store := NewFileStore(cfg)
+	store.WatchInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := store.Watch(ctx, "test-instance")
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	if err := NewFileStore(cfg).SaveResource("test-instance", ResourceState{Id: "host-1", Type: "host"}); err != nil {
+		t.Fatalf("SaveResource failed: %v", err)
+	}
+	event := nextEvent(t, events)
+	if event.Type != EventPut || event.ResourceId != "host-1" {
+		t.Fatalf("unexpected put event: %+v", event)
+	}
+}
+
+func TestFileStore_WatchNewInstances(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yml")
+	cfg, err := model.LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	store := NewFileStore(cfg)
+	store.WatchInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := store.Watch(ctx, "")
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	// another process creates an instance after the watch started
+	instanceDir := filepath.Join(tmpDir, "new-instance")
+	config := "instances:\n  new-instance:\n    name: new-instance\n    working_directory: " + instanceDir + "\n"
+	if err := os.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	writerCfg, err := model.LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if err := NewFileStore(writerCfg).SaveResource("new-instance", ResourceState{Id: "host-1", Type: "host"}); err != nil {
+		t.Fatalf("SaveResource failed: %v", err)
+	}
+
+	event := nextEvent(t, events)
+	if event.Type != EventPut || event.InstanceId != "new-instance" || event.ResourceId != "host-1" {
+		t.Fatalf("unexpected put event: %+v", event)
+	}
+
+	if err := NewFileStore(writerCfg).SaveResource("new-instance", ResourceState{Id: "host-2", Type: "host"}); err != nil {
+		t.Fatalf("SaveResource failed: %v", err)
+	}
+	event = nextEvent(t, events)
+	if event.Type != EventPut || event.InstanceId != "new-instance" || event.ResourceId != "host-2" {
+		t.Fatalf("unexpected put event: %+v", event)
+	}
+}
@@ -11,7 +11,8 @@ func InitModel(m *model.Model) {
 }
 
 func Run() {
-	if err := subcmd.RootCmd.Execute(); err != nil {
+	// Execute shuts the plugins down, which must happen before Fatal exits
+	if err := subcmd.Execute(); err != nil {
 		logrus.WithError(err).Fatal("failure")
 	}
 }
@@ -101,6 +101,9 @@ module "{{ $regionId }}_host_{{ $hostId }}" {
   subnet_id         = module.{{ $regionId }}_region.subnet_id
   spot_price        = "{{ $host.SpotPrice }}"
   spot_type         = "{{ $host.SpotType }}"
+  volume_type       = "{{ $host.EC2.Volume.Type }}"
+  volume_size       = {{ $host.EC2.Volume.SizeGB }}
+  volume_iops       = {{ $host.EC2.Volume.IOPS }}
 }
 
 output "{{ $regionId }}_host_{{ $hostId }}_public_ip" { value = module.{{ $regionId }}_host_{{ $hostId }}.public_ip }
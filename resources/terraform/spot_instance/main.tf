@@ -11,6 +11,9 @@ variable "ssh_user" { default = "ubuntu" }
 variable "subnet_id" {}
 variable "spot_price" {}
 variable "spot_type" {}
+variable "volume_type" { default = "" }
+variable "volume_size" { default = 0 }
+variable "volume_iops" { default = 0 }
 
 output "public_ip" { value = aws_spot_instance_request.fablab.public_ip }
 output "private_ip" { value = aws_spot_instance_request.fablab.private_ip }
@@ -29,6 +32,12 @@ resource "aws_spot_instance_request" "fablab" {
   subnet_id                   = var.subnet_id
   associate_public_ip_address = true
 
+  root_block_device {
+    volume_type = var.volume_type != "" ? var.volume_type : null
+    volume_size = var.volume_size > 0 ? var.volume_size : null
+    iops        = var.volume_iops > 0 ? var.volume_iops : null
+  }
+
   // spot instance-specific args
   spot_price                  = var.spot_price
   wait_for_fulfillment        = true